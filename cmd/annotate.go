@@ -0,0 +1,308 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aure/syntrack/internal/db"
+	"github.com/spf13/cobra"
+)
+
+// maxAnnotationLength bounds annotation text, which is shown on charts.
+const maxAnnotationLength = 500
+
+var annotateDays int
+var annotateJSON bool
+
+var annotateCmd = &cobra.Command{
+	Use:   "annotate",
+	Short: "Record why usage changed",
+	Long: `Annotations mark a point on the usage timeline with a short note, such as
+"started nightly eval" or "new agent rollout". They are shown on the
+dashboard chart, the ASCII chart and in history tables.
+
+CI jobs can create them through the HTTP API of 'syntrack serve':
+
+  curl -X POST -H "X-Auth-Token: $TOKEN" \
+    -d '{"text": "deploy 1.4.2"}' http://localhost:8080/api/annotations`,
+}
+
+var annotateAddCmd = &cobra.Command{
+	Use:   "add <time> <text>...",
+	Short: "Add an annotation",
+	Long: `Add an annotation at a time given as "now", an offset such as -2h or
+-30m, RFC 3339, or local "2006-01-02 15:04" or "2006-01-02". Put offsets
+after -- so they are not read as flags.
+
+Examples:
+  syntrack annotate add now started nightly eval
+  syntrack annotate add -- -3h "new agent rollout"
+  syntrack annotate add "2025-01-15 09:30" switched to the larger model`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		at, err := parseAnnotationTime(args[0], time.Now())
+		if err != nil {
+			return err
+		}
+		text, err := annotationText(strings.Join(args[1:], " "))
+		if err != nil {
+			return err
+		}
+
+		database, err := db.New(dbPath)
+		if err != nil {
+			return fmt.Errorf("opening database: %w", err)
+		}
+		defer database.Close()
+
+		a, err := database.InsertAnnotation(at, text)
+		if err != nil {
+			return fmt.Errorf("adding annotation: %w", err)
+		}
+		fmt.Printf("✓ Added annotation %d at %s\n", a.ID, a.At.Local().Format("2006-01-02 15:04"))
+		return nil
+	},
+}
+
+var annotateListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List annotations",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		database, err := db.New(dbPath)
+		if err != nil {
+			return fmt.Errorf("opening database: %w", err)
+		}
+		defer database.Close()
+
+		annotations, err := database.GetAnnotations(time.Now().AddDate(0, 0, -annotateDays))
+		if err != nil {
+			return fmt.Errorf("getting annotations: %w", err)
+		}
+
+		if annotateJSON {
+			output, err := json.MarshalIndent(annotationEntries(annotations), "", "  ")
+			if err != nil {
+				return fmt.Errorf("encoding JSON: %w", err)
+			}
+			fmt.Println(string(output))
+			return nil
+		}
+		if len(annotations) == 0 {
+			fmt.Printf("No annotations in the last %d days.\n", annotateDays)
+			return nil
+		}
+
+		fmt.Printf("Annotations (last %d days)\n", annotateDays)
+		fmt.Println("──────────────────────────────────────────────────────────────────")
+		fmt.Printf("%6s  %-16s  %s\n", "ID", "Time", "Text")
+		fmt.Println("──────────────────────────────────────────────────────────────────")
+		for i := len(annotations) - 1; i >= 0; i-- {
+			a := annotations[i]
+			fmt.Printf("%6d  %-16s  %s\n", a.ID, a.At.Local().Format("2006-01-02 15:04"), a.Text)
+		}
+		return nil
+	},
+}
+
+var annotateRmCmd = &cobra.Command{
+	Use:   "rm <id>...",
+	Short: "Remove annotations",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ids := make([]int64, len(args))
+		for i, arg := range args {
+			id, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid annotation ID %q", arg)
+			}
+			ids[i] = id
+		}
+
+		database, err := db.New(dbPath)
+		if err != nil {
+			return fmt.Errorf("opening database: %w", err)
+		}
+		defer database.Close()
+
+		var errs []error
+		for _, id := range ids {
+			deleted, err := database.DeleteAnnotation(id)
+			switch {
+			case err != nil:
+				errs = append(errs, fmt.Errorf("removing annotation %d: %w", id, err))
+			case !deleted:
+				errs = append(errs, fmt.Errorf("no annotation with ID %d", id))
+			default:
+				fmt.Printf("✓ Removed annotation %d\n", id)
+			}
+		}
+		return errors.Join(errs...)
+	},
+}
+
+// parseAnnotationTime accepts "now", a signed offset from now such as
+// -2h, RFC 3339, or a local date with an optional time of day.
+func parseAnnotationTime(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if strings.EqualFold(s, "now") {
+		return now, nil
+	}
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid offset %q: %w", s, err)
+		}
+		return now.Add(d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q; use now, an offset like -2h, RFC 3339 or \"2006-01-02 15:04\"", s)
+}
+
+// annotationText trims text and checks it fits on a chart.
+func annotationText(text string) (string, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", fmt.Errorf("annotation text is required")
+	}
+	if n := len([]rune(text)); n > maxAnnotationLength {
+		return "", fmt.Errorf("annotation text is %d characters; the maximum is %d", n, maxAnnotationLength)
+	}
+	return text, nil
+}
+
+type AnnotationEntry struct {
+	ID        int64  `json:"id"`
+	At        string `json:"at"`
+	Text      string `json:"text"`
+	CreatedAt string `json:"created_at"`
+}
+
+func annotationEntry(a db.Annotation) AnnotationEntry {
+	return AnnotationEntry{
+		ID:        a.ID,
+		At:        a.At.Format(time.RFC3339),
+		Text:      a.Text,
+		CreatedAt: a.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func annotationEntries(annotations []db.Annotation) []AnnotationEntry {
+	entries := make([]AnnotationEntry, len(annotations))
+	for i, a := range annotations {
+		entries[i] = annotationEntry(a)
+	}
+	return entries
+}
+
+// handleAnnotationsAPI lists annotations on GET, optionally limited with
+// ?days=, and creates one on POST from {"at": ..., "text": ...}, where at
+// is optional and takes the same forms as 'annotate add'.
+func handleAnnotationsAPI(database *db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			days := 30
+			if s := r.URL.Query().Get("days"); s != "" {
+				var err error
+				if days, err = strconv.Atoi(s); err != nil || days <= 0 {
+					http.Error(w, "days must be a positive integer", http.StatusBadRequest)
+					return
+				}
+			}
+			annotations, err := database.GetAnnotations(time.Now().AddDate(0, 0, -days))
+			if err != nil {
+				slog.Error("getting annotations", "err", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(annotationEntries(annotations))
+
+		case http.MethodPost:
+			var req struct {
+				At   string `json:"at"`
+				Text string `json:"text"`
+			}
+			dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096))
+			if err := dec.Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+				http.Error(w, "invalid JSON body", http.StatusBadRequest)
+				return
+			}
+			at := time.Now()
+			if req.At != "" {
+				var err error
+				if at, err = parseAnnotationTime(req.At, at); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+			text, err := annotationText(req.Text)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			a, err := database.InsertAnnotation(at, text)
+			if err != nil {
+				slog.Error("adding annotation", "err", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			slog.Info("annotation added", "id", a.ID, "at", a.At.Format(time.RFC3339))
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(annotationEntry(a))
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// handleAnnotationAPI deletes the annotation /api/annotations/{id}.
+func handleAnnotationAPI(database *db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid annotation ID", http.StatusBadRequest)
+			return
+		}
+		deleted, err := database.DeleteAnnotation(id)
+		if err != nil {
+			slog.Error("removing annotation", "err", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !deleted {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func init() {
+	annotateListCmd.Flags().IntVarP(&annotateDays, "days", "d", 30, "Number of days to show")
+	annotateListCmd.Flags().BoolVar(&annotateJSON, "json", false, "Print annotations as JSON")
+	annotateCmd.AddCommand(annotateAddCmd)
+	annotateCmd.AddCommand(annotateListCmd)
+	annotateCmd.AddCommand(annotateRmCmd)
+	rootCmd.AddCommand(annotateCmd)
+}
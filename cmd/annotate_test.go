@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aure/syntrack/internal/db"
+)
+
+func TestParseAnnotationTime(t *testing.T) {
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"now", now},
+		{"-2h", now.Add(-2 * time.Hour)},
+		{"-90m", now.Add(-90 * time.Minute)},
+		{"2025-01-14T08:30:00Z", time.Date(2025, 1, 14, 8, 30, 0, 0, time.UTC)},
+		{"2025-01-14 08:30", time.Date(2025, 1, 14, 8, 30, 0, 0, time.Local)},
+		{"2025-01-14", time.Date(2025, 1, 14, 0, 0, 0, 0, time.Local)},
+	}
+	for _, tt := range tests {
+		got, err := parseAnnotationTime(tt.in, now)
+		if err != nil {
+			t.Fatalf("parseAnnotationTime(%q): %v", tt.in, err)
+		}
+		if !got.Equal(tt.want) {
+			t.Fatalf("parseAnnotationTime(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"yesterday", "-2x", "2025-13-01"} {
+		if _, err := parseAnnotationTime(in, now); err == nil {
+			t.Fatalf("parseAnnotationTime(%q) succeeded, want error", in)
+		}
+	}
+}
+
+func TestHistoryRows_InterleavesAnnotations(t *testing.T) {
+	base := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	snapshots := []db.UsageSnapshot{
+		{CollectedAt: base},
+		{CollectedAt: base.Add(time.Hour)},
+		{CollectedAt: base.Add(2 * time.Hour)},
+	}
+	annotations := []db.Annotation{
+		{Text: "before", At: base.Add(-time.Minute)},
+		{Text: "same time", At: base.Add(time.Hour)},
+		{Text: "latest", At: base.Add(3 * time.Hour)},
+	}
+
+	var got []string
+	for _, row := range historyRows(snapshots, annotations) {
+		if row.Annotation != nil {
+			got = append(got, row.Annotation.Text)
+		} else {
+			got = append(got, row.Snapshot.CollectedAt.Format("15:04"))
+		}
+	}
+	want := "latest 14:00 same time 13:00 12:00 before"
+	if strings.Join(got, " ") != want {
+		t.Fatalf("rows = %q, want %q", strings.Join(got, " "), want)
+	}
+}
+
+func TestAnnotationsAPI(t *testing.T) {
+	database, err := db.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/annotations", handleAnnotationsAPI(database))
+	mux.HandleFunc("/api/annotations/{id}", handleAnnotationAPI(database))
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := do(http.MethodPost, "/api/annotations", `{"at": "-1h", "text": "  started nightly eval "}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST status = %d, body %s", rec.Code, rec.Body)
+	}
+	var created AnnotationEntry
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.ID == 0 || created.Text != "started nightly eval" {
+		t.Fatalf("created = %+v", created)
+	}
+
+	for _, body := range []string{`{"text": ""}`, `{"text": "x", "at": "soon"}`, `not json`, `{"text": "` + strings.Repeat("x", maxAnnotationLength+1) + `"}`} {
+		if rec := do(http.MethodPost, "/api/annotations", body); rec.Code != http.StatusBadRequest {
+			t.Fatalf("POST %s status = %d, want 400", body, rec.Code)
+		}
+	}
+
+	rec = do(http.MethodGet, "/api/annotations?days=1", "")
+	var listed []AnnotationEntry
+	if err := json.Unmarshal(rec.Body.Bytes(), &listed); err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 1 || listed[0].ID != created.ID {
+		t.Fatalf("listed = %+v", listed)
+	}
+
+	path := "/api/annotations/" + strconv.FormatInt(created.ID, 10)
+	if rec := do(http.MethodDelete, path, ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("DELETE status = %d", rec.Code)
+	}
+	if rec := do(http.MethodDelete, path, ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("second DELETE status = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/aure/syntrack/internal/anomaly"
+	"github.com/aure/syntrack/internal/db"
+	"github.com/spf13/cobra"
+)
+
+// anomalyHistory is how far back intervals are compared.
+const anomalyHistory = 28 * 24 * time.Hour
+
+var anomaliesDays int
+var anomaliesJSON bool
+var anomaliesScanDays int
+var anomaliesThreshold float64
+
+var anomaliesCmd = &cobra.Command{
+	Use:   "anomalies",
+	Short: "List consumption spikes",
+	Long: `List intervals whose consumption was far above the usual rate for the
+same hour of the week.
+
+Each collect compares the newest interval with the intervals of the same
+hour of the week (±1 hour) over the last 28 days, using the median and
+median absolute deviation. Intervals scoring 3.5 or more are recorded.
+Use 'anomalies scan' to check data collected before this existed.
+
+Examples:
+  syntrack anomalies
+  syntrack anomalies --days 90 --json
+  syntrack anomalies scan --days 90`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		database, err := db.New(dbPath)
+		if err != nil {
+			return fmt.Errorf("opening database: %w", err)
+		}
+		defer database.Close()
+
+		anomalies, err := database.GetAnomalies(time.Now().AddDate(0, 0, -anomaliesDays))
+		if err != nil {
+			return fmt.Errorf("getting anomalies: %w", err)
+		}
+
+		if anomaliesJSON {
+			return printAnomaliesJSON(anomalies)
+		}
+		if len(anomalies) == 0 {
+			fmt.Printf("No anomalies in the last %d days.\n", anomaliesDays)
+			return nil
+		}
+
+		fmt.Printf("Anomalies (last %d days)\n", anomaliesDays)
+		fmt.Println("──────────────────────────────────────────────────────────────────")
+		fmt.Printf("%-16s %-13s %8s %10s %10s %6s\n", "Ended", "Duration", "Requests", "Rate/h", "Usual/h", "Score")
+		fmt.Println("──────────────────────────────────────────────────────────────────")
+		for i := len(anomalies) - 1; i >= 0; i-- {
+			a := anomalies[i]
+			fmt.Printf("%-16s %-13s %8d %10.1f %10.1f %6.1f\n",
+				a.IntervalEnd.Local().Format("2006-01-02 15:04"),
+				formatDurationShort(a.IntervalEnd.Sub(a.IntervalStart)),
+				a.Consumed, a.Rate, a.BaselineRate, a.Score)
+		}
+		return nil
+	},
+}
+
+var anomaliesScanCmd = &cobra.Command{
+	Use:   "scan",
+	Short: "Detect anomalies in already collected data",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		database, err := db.New(dbPath)
+		if err != nil {
+			return fmt.Errorf("opening database: %w", err)
+		}
+		defer database.Close()
+
+		detector := anomaly.Default
+		detector.Threshold = anomaliesThreshold
+
+		from := time.Now().AddDate(0, 0, -anomaliesScanDays)
+		intervals, err := database.GetIntervals(from.Add(-anomalyHistory))
+		if err != nil {
+			return fmt.Errorf("getting intervals: %w", err)
+		}
+
+		found, added := 0, 0
+		start := 0
+		for i, iv := range intervals {
+			for intervals[start].End.Before(iv.Start.Add(-anomalyHistory)) {
+				start++
+			}
+			if iv.End.Before(from) {
+				continue
+			}
+			r := detector.Evaluate(intervals[start:i], iv)
+			if !r.Anomalous {
+				continue
+			}
+			found++
+			inserted, err := database.InsertAnomaly(newAnomaly(iv, r))
+			if err != nil {
+				return fmt.Errorf("recording anomaly: %w", err)
+			}
+			if inserted {
+				added++
+			}
+		}
+		fmt.Printf("Found %d anomalies in the last %d days (%d new).\n", found, anomaliesScanDays, added)
+		return nil
+	},
+}
+
+func newAnomaly(iv db.Interval, r anomaly.Result) db.Anomaly {
+	return db.Anomaly{
+		IntervalStart: iv.Start,
+		IntervalEnd:   iv.End,
+		Consumed:      iv.Consumed,
+		Rate:          r.Rate,
+		BaselineRate:  r.Median,
+		Score:         r.Score,
+	}
+}
+
+// detectLatestAnomaly checks the interval ending at the newest snapshot
+// and records it if it is anomalous.
+func detectLatestAnomaly(database *db.DB) error {
+	intervals, err := database.GetIntervals(time.Now().Add(-anomalyHistory))
+	if err != nil {
+		return fmt.Errorf("getting intervals: %w", err)
+	}
+	if len(intervals) < 2 {
+		return nil
+	}
+
+	latest := intervals[len(intervals)-1]
+	r := anomaly.Default.Evaluate(intervals[:len(intervals)-1], latest)
+	if !r.Anomalous {
+		return nil
+	}
+	inserted, err := database.InsertAnomaly(newAnomaly(latest, r))
+	if err != nil {
+		return fmt.Errorf("recording anomaly: %w", err)
+	}
+	if inserted {
+		slog.Warn("consumption anomaly",
+			"consumed", latest.Consumed,
+			"rate", fmt.Sprintf("%.1f/h", r.Rate),
+			"usual", fmt.Sprintf("%.1f/h", r.Median),
+			"score", fmt.Sprintf("%.1f", r.Score))
+	}
+	return nil
+}
+
+type AnomalyEntry struct {
+	IntervalStart string  `json:"interval_start"`
+	IntervalEnd   string  `json:"interval_end"`
+	Consumed      int     `json:"consumed"`
+	RatePerHour   float64 `json:"rate_per_hour"`
+	UsualPerHour  float64 `json:"usual_per_hour"`
+	Score         float64 `json:"score"`
+	DetectedAt    string  `json:"detected_at"`
+}
+
+func printAnomaliesJSON(anomalies []db.Anomaly) error {
+	entries := make([]AnomalyEntry, len(anomalies))
+	for i, a := range anomalies {
+		entries[i] = AnomalyEntry{
+			IntervalStart: a.IntervalStart.Format(time.RFC3339),
+			IntervalEnd:   a.IntervalEnd.Format(time.RFC3339),
+			Consumed:      a.Consumed,
+			RatePerHour:   a.Rate,
+			UsualPerHour:  a.BaselineRate,
+			Score:         a.Score,
+			DetectedAt:    a.DetectedAt.Format(time.RFC3339),
+		}
+	}
+	output, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding JSON: %w", err)
+	}
+	fmt.Println(string(output))
+	return nil
+}
+
+func init() {
+	anomaliesCmd.Flags().IntVarP(&anomaliesDays, "days", "d", 30, "Number of days to show")
+	anomaliesCmd.Flags().BoolVar(&anomaliesJSON, "json", false, "Print anomalies as JSON")
+	anomaliesScanCmd.Flags().IntVarP(&anomaliesScanDays, "days", "d", 30, "Number of days to scan")
+	anomaliesScanCmd.Flags().Float64Var(&anomaliesThreshold, "threshold", anomaly.Default.Threshold, "Score at or above which an interval is anomalous")
+	anomaliesCmd.AddCommand(anomaliesScanCmd)
+	rootCmd.AddCommand(anomaliesCmd)
+}
@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aure/syntrack/internal/db"
+	"github.com/spf13/cobra"
+)
+
+var budgetNeed int
+var budgetReserve int
+var budgetJSON bool
+
+// Budget verdicts and their exit codes.
+const (
+	budgetOK           = "ok"
+	budgetRisky        = "risky"
+	budgetInsufficient = "insufficient"
+	budgetUnknown      = "unknown"
+)
+
+var budgetExitCodes = map[string]int{
+	budgetOK:           0,
+	budgetRisky:        1,
+	budgetInsufficient: 2,
+	budgetUnknown:      3,
+}
+
+var budgetCmd = &cobra.Command{
+	Use:   "budget",
+	Short: "Plan request spending against the quota",
+}
+
+var budgetCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check whether a job fits in the remaining quota",
+	Long: `Check whether a job needing --need requests can run now without running
+out before renewal, keeping --reserve requests untouched.
+
+The verdict considers the leftover of the latest snapshot and the usage
+expected from the 24h burn rate until renews_at:
+
+  ok            fits, including the expected usage       (exit 0)
+  risky         fits now, but the expected usage would   (exit 1)
+                exhaust the quota before renewal
+  insufficient  does not fit until renewal, or never     (exit 2)
+  unknown       no data, or the snapshot predates the    (exit 3)
+                last renewal
+
+The output includes earliest_fit: now if the job fits, otherwise the
+renewal time if the job fits in a fresh quota.
+
+Examples:
+  syntrack budget check --need 300
+  syntrack budget check --need 300 --reserve 50 --json
+  syntrack budget check --need 300 && ./run-batch.sh`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if budgetNeed <= 0 {
+			return fmt.Errorf("--need must be positive")
+		}
+		if budgetReserve < 0 {
+			return fmt.Errorf("--reserve must not be negative")
+		}
+
+		// Failures are reported as unknown so schedulers can tell them
+		// apart from a full quota
+		database, err := db.New(dbPath)
+		if err != nil {
+			return exitWithCode(cmd, budgetExitCodes[budgetUnknown], fmt.Errorf("opening database: %w", err))
+		}
+		defer database.Close()
+
+		v, err := loadBudgetVerdict(database, budgetNeed, budgetReserve)
+		if err != nil {
+			return exitWithCode(cmd, budgetExitCodes[budgetUnknown], err)
+		}
+
+		if budgetJSON {
+			output, err := json.MarshalIndent(v, "", "  ")
+			if err != nil {
+				return fmt.Errorf("encoding JSON: %w", err)
+			}
+			fmt.Println(string(output))
+		} else {
+			fmt.Printf("%s: %s\n", v.Verdict, v.Reason)
+			if v.EarliestFit != "" {
+				fmt.Printf("Earliest fit: %s\n", v.EarliestFit)
+			}
+		}
+		return exitWithCode(cmd, budgetExitCodes[v.Verdict], nil)
+	},
+}
+
+// BudgetVerdict is the result of a budget check, shared by the CLI and
+// /api/budget.
+type BudgetVerdict struct {
+	CheckedAt    string  `json:"checked_at"`
+	Verdict      string  `json:"verdict"`
+	Reason       string  `json:"reason"`
+	Need         int     `json:"need"`
+	Reserve      int     `json:"reserve"`
+	Leftover     int     `json:"leftover"`
+	Available    int     `json:"available"`
+	BurnRate     float64 `json:"burn_rate_per_hour"`
+	ProjectedUse float64 `json:"projected_use"`
+	SnapshotAt   string  `json:"snapshot_at,omitempty"`
+	RenewsAt     string  `json:"renews_at,omitempty"`
+	EarliestFit  string  `json:"earliest_fit,omitempty"`
+}
+
+func loadBudgetVerdict(database *db.DB, need, reserve int) (BudgetVerdict, error) {
+	latest, err := database.GetLatestSnapshot()
+	if err != nil {
+		return BudgetVerdict{}, fmt.Errorf("getting latest snapshot: %w", err)
+	}
+	burnRate, err := database.GetBurnRate(24)
+	if err != nil {
+		return BudgetVerdict{}, fmt.Errorf("calculating burn rate: %w", err)
+	}
+	return evaluateBudget(latest, burnRate, need, reserve, time.Now()), nil
+}
+
+// evaluateBudget decides whether need requests fit in the quota at now,
+// keeping reserve requests and the usage projected from burnRate (per
+// hour) until renewal.
+func evaluateBudget(latest *db.UsageSnapshot, burnRate float64, need, reserve int, now time.Time) BudgetVerdict {
+	v := BudgetVerdict{
+		CheckedAt: now.Format(time.RFC3339),
+		Need:      need,
+		Reserve:   reserve,
+		BurnRate:  burnRate,
+	}
+	if latest == nil {
+		v.Verdict = budgetUnknown
+		v.Reason = "no data collected yet"
+		return v
+	}
+
+	v.SnapshotAt = latest.CollectedAt.Format(time.RFC3339)
+	v.Leftover = latest.Leftover
+	v.Available = latest.Leftover - reserve
+	if latest.RenewsAt != nil {
+		v.RenewsAt = latest.RenewsAt.Format(time.RFC3339)
+		if !latest.RenewsAt.After(now) {
+			v.Verdict = budgetUnknown
+			v.Reason = "the quota has renewed since the latest snapshot; collect a fresh one"
+			return v
+		}
+		v.ProjectedUse = burnRate * latest.RenewsAt.Sub(now).Hours()
+	}
+
+	if need > v.Available {
+		v.Verdict = budgetInsufficient
+		if latest.RenewsAt != nil && need <= latest.SubscriptionLimit-reserve {
+			v.EarliestFit = v.RenewsAt
+			v.Reason = fmt.Sprintf("needs %d but only %d available until renewal", need, max(v.Available, 0))
+		} else {
+			v.Reason = fmt.Sprintf("needs %d but a cycle allows at most %d", need, latest.SubscriptionLimit-reserve)
+		}
+		return v
+	}
+
+	v.EarliestFit = now.Format(time.RFC3339)
+	if float64(need)+v.ProjectedUse > float64(v.Available) {
+		v.Verdict = budgetRisky
+		v.Reason = fmt.Sprintf("fits now (%d available), but %.0f more requests are expected at %.1f/h before renewal", v.Available, v.ProjectedUse, burnRate)
+		return v
+	}
+	v.Verdict = budgetOK
+	v.Reason = fmt.Sprintf("fits: %d available, %.0f expected before renewal", v.Available, v.ProjectedUse)
+	return v
+}
+
+// handleBudgetAPI serves GET /api/budget?need=N[&reserve=R].
+func handleBudgetAPI(database *db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		need, err := strconv.Atoi(r.URL.Query().Get("need"))
+		if err != nil || need <= 0 {
+			http.Error(w, "need must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		reserve := 0
+		if s := r.URL.Query().Get("reserve"); s != "" {
+			if reserve, err = strconv.Atoi(s); err != nil || reserve < 0 {
+				http.Error(w, "reserve must be a non-negative integer", http.StatusBadRequest)
+				return
+			}
+		}
+
+		v, err := loadBudgetVerdict(database, need, reserve)
+		if err != nil {
+			slog.Error("evaluating budget", "err", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(v)
+	}
+}
+
+func init() {
+	budgetCheckCmd.Flags().IntVarP(&budgetNeed, "need", "n", 0, "Requests the job needs (required)")
+	budgetCheckCmd.Flags().IntVarP(&budgetReserve, "reserve", "r", 0, "Requests to keep untouched")
+	budgetCheckCmd.Flags().BoolVar(&budgetJSON, "json", false, "Print the verdict as JSON")
+	budgetCheckCmd.MarkFlagRequired("need")
+	budgetCmd.AddCommand(budgetCheckCmd)
+	rootCmd.AddCommand(budgetCmd)
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aure/syntrack/internal/db"
+)
+
+func TestEvaluateBudget(t *testing.T) {
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	renews := now.Add(10 * time.Hour)
+	latest := &db.UsageSnapshot{
+		CollectedAt:       now.Add(-5 * time.Minute),
+		SubscriptionLimit: 1000,
+		RequestsUsed:      400,
+		Leftover:          600,
+		RenewsAt:          &renews,
+	}
+
+	tests := []struct {
+		name        string
+		latest      *db.UsageSnapshot
+		burnRate    float64
+		need        int
+		reserve     int
+		verdict     string
+		earliestFit string
+	}{
+		{"fits with projected use", latest, 10, 300, 100, budgetOK, now.Format(time.RFC3339)},
+		{"projected use exhausts quota", latest, 30, 300, 100, budgetRisky, now.Format(time.RFC3339)},
+		{"waits for renewal", latest, 0, 550, 100, budgetInsufficient, renews.Format(time.RFC3339)},
+		{"larger than a cycle", latest, 0, 950, 100, budgetInsufficient, ""},
+		{"no data", nil, 0, 1, 0, budgetUnknown, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := evaluateBudget(tt.latest, tt.burnRate, tt.need, tt.reserve, now)
+			if v.Verdict != tt.verdict {
+				t.Fatalf("verdict = %s (%s), want %s", v.Verdict, v.Reason, tt.verdict)
+			}
+			if v.EarliestFit != tt.earliestFit {
+				t.Fatalf("earliest fit = %q, want %q", v.EarliestFit, tt.earliestFit)
+			}
+		})
+	}
+}
+
+func TestEvaluateBudget_RenewedSinceSnapshot(t *testing.T) {
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	renews := now.Add(-time.Hour)
+	latest := &db.UsageSnapshot{CollectedAt: now.Add(-2 * time.Hour), SubscriptionLimit: 100, Leftover: 0, RequestsUsed: 100, RenewsAt: &renews}
+
+	if v := evaluateBudget(latest, 0, 10, 0, now); v.Verdict != budgetUnknown {
+		t.Fatalf("verdict = %s, want unknown", v.Verdict)
+	}
+}
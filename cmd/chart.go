@@ -21,11 +21,13 @@ Chart types:
   usage     - Used vs leftover over time (default)
   daily     - Daily consumption bar chart
   weekly    - Weekly consumption bar chart
+  heatmap   - Consumption by weekday and hour (default 28 days)
 
 Examples:
   syntrack chart
   syntrack chart --type daily
-  syntrack chart --days 14 --type usage`,
+  syntrack chart --days 14 --type usage
+  syntrack chart -t heatmap -d 56`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		database, err := db.New(dbPath)
 		if err != nil {
@@ -39,8 +41,20 @@ Examples:
 			return printDailyChart(database, chartDays)
 		case "weekly":
 			return printWeeklyChart(database)
+		case "heatmap":
+			// One week per cell is too noisy to show a pattern
+			days := chartDays
+			if !cmd.Flags().Changed("days") {
+				days = 28
+			}
+			h, err := loadHeatmap(database, days)
+			if err != nil {
+				return err
+			}
+			printHeatmap(h)
+			return nil
 		default:
-			return fmt.Errorf("unknown chart type: %s (valid: usage, daily, weekly)", chartType)
+			return fmt.Errorf("unknown chart type: %s (valid: usage, daily, weekly, heatmap)", chartType)
 		}
 	},
 }
@@ -57,10 +71,89 @@ func printUsageChart(database *db.DB, days int) error {
 		return nil
 	}
 
-	printASCIIChart(snapshots)
+	overlays, err := loadChartOverlays(database, since)
+	if err != nil {
+		return err
+	}
+	printASCIIChart(snapshots, overlays)
 	return nil
 }
 
+// chartOverlays are drawn on top of the used and leftover series.
+type chartOverlays struct {
+	// Pace adds the ideal consumption line for snapshots in its cycle.
+	Pace *Pace
+	// Anomalies are marked at the snapshot ending each interval.
+	Anomalies []db.Anomaly
+	// Annotations are marked at their time, between snapshots.
+	Annotations []db.Annotation
+}
+
+func loadChartOverlays(database *db.DB, since time.Time) (chartOverlays, error) {
+	var o chartOverlays
+	var err error
+	if o.Pace, err = loadPace(database); err != nil {
+		return o, err
+	}
+	if o.Anomalies, err = database.GetAnomalies(since); err != nil {
+		return o, fmt.Errorf("getting anomalies: %w", err)
+	}
+	if o.Annotations, err = database.GetAnnotations(since); err != nil {
+		return o, fmt.Errorf("getting annotations: %w", err)
+	}
+	return o, nil
+}
+
+// snapshotIndex returns the index of the snapshot collected at t, or -1.
+func snapshotIndex(snapshots []db.UsageSnapshot, t time.Time) int {
+	for i, s := range snapshots {
+		if s.CollectedAt.Sub(t).Abs() < time.Second {
+			return i
+		}
+	}
+	return -1
+}
+
+// paceValue returns the ideal used count at t, or false if t lies
+// outside the pace line.
+func (o chartOverlays) paceValue(t time.Time) (float64, bool) {
+	if o.Pace == nil || t.Before(o.Pace.Start) || t.After(o.Pace.RenewsAt) {
+		return 0, false
+	}
+	return o.Pace.IdealUsed(t), true
+}
+
+// snapshotPosition returns the fractional index of t between the
+// snapshots, the inverse of snapshotTimeAt, or false if t lies outside
+// them.
+func snapshotPosition(snapshots []db.UsageSnapshot, t time.Time) (float64, bool) {
+	if len(snapshots) == 0 || t.Before(snapshots[0].CollectedAt) || t.After(snapshots[len(snapshots)-1].CollectedAt) {
+		return 0, false
+	}
+	for i := 1; i < len(snapshots); i++ {
+		a, b := snapshots[i-1].CollectedAt, snapshots[i].CollectedAt
+		if t.After(b) {
+			continue
+		}
+		if span := b.Sub(a); span > 0 {
+			return float64(i-1) + float64(t.Sub(a))/float64(span), true
+		}
+		return float64(i), true
+	}
+	return float64(len(snapshots) - 1), true
+}
+
+// snapshotTimeAt interpolates the collection time at a fractional index,
+// matching charts that space snapshots evenly.
+func snapshotTimeAt(snapshots []db.UsageSnapshot, pos float64) time.Time {
+	i := int(pos)
+	if i >= len(snapshots)-1 {
+		return snapshots[len(snapshots)-1].CollectedAt
+	}
+	a, b := snapshots[i].CollectedAt, snapshots[i+1].CollectedAt
+	return a.Add(time.Duration(float64(b.Sub(a)) * (pos - float64(i))))
+}
+
 func printDailyChart(database *db.DB, days int) error {
 	daily, err := database.GetDailyUsage(days)
 	if err != nil {
@@ -139,6 +232,6 @@ func printWeeklyChart(database *db.DB) error {
 
 func init() {
 	chartCmd.Flags().IntVarP(&chartDays, "days", "d", 7, "Number of days to display")
-	chartCmd.Flags().StringVarP(&chartType, "type", "t", "usage", "Chart type (usage, daily, weekly)")
+	chartCmd.Flags().StringVarP(&chartType, "type", "t", "usage", "Chart type (usage, daily, weekly, heatmap)")
 	rootCmd.AddCommand(chartCmd)
 }
@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aure/syntrack/internal/db"
+	"github.com/spf13/cobra"
+)
+
+// Monitoring plugin states, in increasing severity except unknown.
+const (
+	checkOK       = 0
+	checkWarning  = 1
+	checkCritical = 2
+	checkUnknown  = 3
+)
+
+var checkStateNames = []string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+// checkThresholds trigger a state when reached; zero disables a threshold.
+type checkThresholds struct {
+	WarnLeftover int
+	CritLeftover int
+	WarnUsage    float64
+	CritUsage    float64
+	WarnExhaust  time.Duration
+	CritExhaust  time.Duration
+	WarnStale    time.Duration
+	CritStale    time.Duration
+}
+
+var checkFlags checkThresholds
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Nagios/Icinga-compatible quota check",
+	Long: `Check the quota against thresholds and exit with a monitoring plugin
+status: 0 OK, 1 WARNING, 2 CRITICAL, 3 UNKNOWN.
+
+One line is printed in plugin format with performance data:
+
+  SYNTRACK WARNING - 82.2% used, 24 left, ... | 'used'=111;;;0;135 ...
+
+Thresholds (0 disables):
+  --warn-leftover / --crit-leftover   leftover at or below
+  --warn-usage / --crit-usage         percent used at or above
+  --warn-exhaust / --crit-exhaust     quota projected to run out within
+                                      this time, before renewal
+  --warn-stale / --crit-stale         latest snapshot older than this
+
+Examples:
+  syntrack check
+  syntrack check --warn-usage 70 --crit-leftover 10 --crit-stale 3h`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		database, err := db.New(dbPath)
+		if err != nil {
+			fmt.Printf("SYNTRACK UNKNOWN - opening database: %v\n", err)
+			return exitWithCode(cmd, checkUnknown, nil)
+		}
+		defer database.Close()
+
+		latest, err := database.GetLatestSnapshot()
+		if err != nil {
+			fmt.Printf("SYNTRACK UNKNOWN - getting latest snapshot: %v\n", err)
+			return exitWithCode(cmd, checkUnknown, nil)
+		}
+		burnRate, err := database.GetBurnRate(24)
+		if err != nil {
+			fmt.Printf("SYNTRACK UNKNOWN - calculating burn rate: %v\n", err)
+			return exitWithCode(cmd, checkUnknown, nil)
+		}
+
+		state, output := evaluateCheck(latest, burnRate, checkFlags, time.Now())
+		fmt.Println(output)
+		return exitWithCode(cmd, state, nil)
+	},
+}
+
+// evaluateCheck returns the plugin state and its output line.
+func evaluateCheck(latest *db.UsageSnapshot, burnRate float64, t checkThresholds, now time.Time) (int, string) {
+	if latest == nil {
+		return checkUnknown, "SYNTRACK UNKNOWN - no data collected yet"
+	}
+
+	state := checkOK
+	var problems []string
+	raise := func(s int, problem string) {
+		state = max(state, s)
+		problems = append(problems, problem)
+	}
+
+	pct := 0.0
+	if latest.SubscriptionLimit > 0 {
+		pct = float64(latest.RequestsUsed) / float64(latest.SubscriptionLimit) * 100
+	}
+	switch {
+	case t.CritUsage > 0 && pct >= t.CritUsage:
+		raise(checkCritical, fmt.Sprintf("usage %.1f%% >= %.0f%%", pct, t.CritUsage))
+	case t.WarnUsage > 0 && pct >= t.WarnUsage:
+		raise(checkWarning, fmt.Sprintf("usage %.1f%% >= %.0f%%", pct, t.WarnUsage))
+	}
+
+	switch {
+	case t.CritLeftover > 0 && latest.Leftover <= t.CritLeftover:
+		raise(checkCritical, fmt.Sprintf("leftover %d <= %d", latest.Leftover, t.CritLeftover))
+	case t.WarnLeftover > 0 && latest.Leftover <= t.WarnLeftover:
+		raise(checkWarning, fmt.Sprintf("leftover %d <= %d", latest.Leftover, t.WarnLeftover))
+	}
+
+	// Running out only matters if it happens before the quota renews
+	var toEmpty time.Duration
+	if burnRate > 0 {
+		toEmpty = time.Duration(float64(latest.Leftover) / burnRate * float64(time.Hour))
+		beforeRenewal := latest.RenewsAt == nil || now.Add(toEmpty).Before(*latest.RenewsAt)
+		switch {
+		case !beforeRenewal:
+		case t.CritExhaust > 0 && toEmpty <= t.CritExhaust:
+			raise(checkCritical, "exhausted in "+formatDurationShort(toEmpty))
+		case t.WarnExhaust > 0 && toEmpty <= t.WarnExhaust:
+			raise(checkWarning, "exhausted in "+formatDurationShort(toEmpty))
+		}
+	}
+
+	age := max(now.Sub(latest.CollectedAt), 0)
+	switch {
+	case t.CritStale > 0 && age > t.CritStale:
+		raise(checkCritical, "last snapshot "+formatDurationShort(age)+" old")
+	case t.WarnStale > 0 && age > t.WarnStale:
+		raise(checkWarning, "last snapshot "+formatDurationShort(age)+" old")
+	}
+
+	summary := fmt.Sprintf("%.1f%% used, %d left", pct, latest.Leftover)
+	if latest.RenewsAt != nil {
+		summary += ", renews in " + formatDurationShort(latest.RenewsAt.Sub(now))
+	}
+	if len(problems) > 0 {
+		summary = strings.Join(problems, ", ") + "; " + summary
+	}
+
+	limit := latest.SubscriptionLimit
+	perfdata := []string{
+		fmt.Sprintf("'used'=%d;;;0;%d", latest.RequestsUsed, limit),
+		fmt.Sprintf("'leftover'=%d;%s;%s;0;%d", latest.Leftover, perfLowThreshold(t.WarnLeftover), perfLowThreshold(t.CritLeftover), limit),
+		fmt.Sprintf("'usage'=%.1f%%;%s;%s;0;100", pct, perfThreshold(t.WarnUsage), perfThreshold(t.CritUsage)),
+		fmt.Sprintf("'burn_rate'=%.2f;;;0", burnRate),
+		fmt.Sprintf("'age'=%.0fs;%s;%s;0", age.Seconds(), perfThreshold(t.WarnStale.Seconds()), perfThreshold(t.CritStale.Seconds())),
+	}
+	if burnRate > 0 {
+		perfdata = append(perfdata, fmt.Sprintf("'time_to_empty'=%.0fs;%s;%s;0", toEmpty.Seconds(), perfLowThreshold(t.WarnExhaust.Seconds()), perfLowThreshold(t.CritExhaust.Seconds())))
+	}
+
+	return state, fmt.Sprintf("SYNTRACK %s - %s | %s", checkStateNames[state], summary, strings.Join(perfdata, " "))
+}
+
+// perfThreshold formats a threshold for perfdata; disabled thresholds are
+// left empty.
+func perfThreshold[T int | float64](v T) string {
+	if v <= 0 {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// perfLowThreshold formats a threshold for values that alert when low,
+// using the plugin range syntax "N:".
+func perfLowThreshold[T int | float64](v T) string {
+	if v <= 0 {
+		return ""
+	}
+	return fmt.Sprint(v) + ":"
+}
+
+func init() {
+	checkCmd.Flags().IntVar(&checkFlags.WarnLeftover, "warn-leftover", 0, "Warning when leftover is at or below this")
+	checkCmd.Flags().IntVar(&checkFlags.CritLeftover, "crit-leftover", 0, "Critical when leftover is at or below this")
+	checkCmd.Flags().Float64Var(&checkFlags.WarnUsage, "warn-usage", 80, "Warning when this percent of the limit is used")
+	checkCmd.Flags().Float64Var(&checkFlags.CritUsage, "crit-usage", 95, "Critical when this percent of the limit is used")
+	checkCmd.Flags().DurationVar(&checkFlags.WarnExhaust, "warn-exhaust", 12*time.Hour, "Warning when the quota runs out within this time, before renewal")
+	checkCmd.Flags().DurationVar(&checkFlags.CritExhaust, "crit-exhaust", 3*time.Hour, "Critical when the quota runs out within this time, before renewal")
+	checkCmd.Flags().DurationVar(&checkFlags.WarnStale, "warn-stale", 2*time.Hour, "Warning when the latest snapshot is older than this")
+	checkCmd.Flags().DurationVar(&checkFlags.CritStale, "crit-stale", 6*time.Hour, "Critical when the latest snapshot is older than this")
+	rootCmd.AddCommand(checkCmd)
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aure/syntrack/internal/db"
+)
+
+func TestEvaluateCheck(t *testing.T) {
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	renews := now.Add(48 * time.Hour)
+	snapshot := func(used int, age time.Duration) *db.UsageSnapshot {
+		return &db.UsageSnapshot{
+			CollectedAt:       now.Add(-age),
+			SubscriptionLimit: 100,
+			RequestsUsed:      used,
+			Leftover:          100 - used,
+			RenewsAt:          &renews,
+		}
+	}
+	thresholds := checkThresholds{WarnUsage: 80, CritUsage: 95, WarnExhaust: 12 * time.Hour, CritExhaust: 3 * time.Hour, WarnStale: 2 * time.Hour, CritStale: 6 * time.Hour}
+
+	tests := []struct {
+		name     string
+		latest   *db.UsageSnapshot
+		burnRate float64
+		state    int
+		contains string
+	}{
+		{"ok", snapshot(10, time.Minute), 0, checkOK, "SYNTRACK OK - 10.0% used, 90 left"},
+		{"usage warning", snapshot(85, time.Minute), 0, checkWarning, "usage 85.0% >= 80%"},
+		{"usage critical", snapshot(96, time.Minute), 0, checkCritical, "usage 96.0% >= 95%"},
+		{"exhausts before renewal", snapshot(50, time.Minute), 10, checkWarning, "exhausted in 5h00m"},
+		{"stale", snapshot(10, 7*time.Hour), 0, checkCritical, "last snapshot 7h00m old"},
+		{"no data", nil, 0, checkUnknown, "SYNTRACK UNKNOWN"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, out := evaluateCheck(tt.latest, tt.burnRate, thresholds, now)
+			if state != tt.state {
+				t.Fatalf("state = %d, want %d: %s", state, tt.state, out)
+			}
+			if !strings.Contains(out, tt.contains) {
+				t.Fatalf("output missing %q: %s", tt.contains, out)
+			}
+		})
+	}
+}
+
+func TestEvaluateCheck_ExhaustionAfterRenewal(t *testing.T) {
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	renews := now.Add(time.Hour)
+	latest := &db.UsageSnapshot{CollectedAt: now, SubscriptionLimit: 100, RequestsUsed: 50, Leftover: 50, RenewsAt: &renews}
+
+	state, out := evaluateCheck(latest, 10, checkThresholds{CritExhaust: 6 * time.Hour}, now)
+	if state != checkOK {
+		t.Fatalf("state = %d, want OK: %s", state, out)
+	}
+	if !strings.Contains(out, "'time_to_empty'=18000s;;21600:;0") {
+		t.Fatalf("unexpected perfdata: %s", out)
+	}
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"time"
 
 	"github.com/aure/syntrack/internal/api"
@@ -10,6 +11,8 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var collectTimeout time.Duration
+
 var collectCmd = &cobra.Command{
 	Use:   "collect",
 	Short: "Collect usage data from Synthetic API",
@@ -24,7 +27,7 @@ var collectCmd = &cobra.Command{
 		}
 
 		client := api.NewClient(apiKey)
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
 		defer cancel()
 
 		quota, err := client.GetQuotas(ctx)
@@ -41,12 +44,21 @@ var collectCmd = &cobra.Command{
 			return fmt.Errorf("inserting snapshot: %w", err)
 		}
 
-		leftover := quota.Subscription.Limit - quota.Subscription.Requests
-		fmt.Printf("Collected: %d/%d used (%d leftover)\n", quota.Subscription.Requests, quota.Subscription.Limit, leftover)
+		slog.Info("collected snapshot",
+			"used", quota.Subscription.Requests,
+			"limit", quota.Subscription.Limit,
+			"leftover", quota.Subscription.Limit-quota.Subscription.Requests)
+
+		// The snapshot is stored; a failed check must not fail the collect
+		if err := detectLatestAnomaly(database); err != nil {
+			slog.Warn("anomaly detection failed", "err", err)
+		}
 		return nil
 	},
 }
 
 func init() {
+	collectCmd.Flags().DurationVar(&collectTimeout, "timeout", 30*time.Second, "Timeout for the quota request")
+	bindConfigFlag("collect.timeout", collectCmd.Flags(), "timeout")
 	rootCmd.AddCommand(collectCmd)
 }
@@ -0,0 +1,504 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aure/syntrack/internal/db"
+	"github.com/spf13/cobra"
+)
+
+var compareJSON bool
+
+var compareCmd = &cobra.Command{
+	Use:   "compare [day|week|cycle | FROM..TO FROM..TO]",
+	Short: "Compare consumption between two periods",
+	Long: `Compare consumption between two periods: today vs yesterday, this week
+vs last week (the default), the current quota cycle vs the previous one,
+or two arbitrary ranges.
+
+The first period is compared with the second at the same elapsed time, so
+a week in progress is measured against the same number of hours of last
+week. Totals, peak hour and average per day are shown for both, with the
+cumulative consumption of both periods in one chart.
+
+Ranges are FROM..TO with dates (YYYY-MM-DD, TO inclusive) or RFC 3339
+times.
+
+Examples:
+  syntrack compare
+  syntrack compare cycle
+  syntrack compare 2025-01-13..2025-01-19 2025-01-06..2025-01-12
+  syntrack compare week --json`,
+	Args: cobra.RangeArgs(0, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		database, err := db.New(dbPath)
+		if err != nil {
+			return fmt.Errorf("opening database: %w", err)
+		}
+		defer database.Close()
+
+		c, err := loadComparison(database, args, time.Now())
+		if err != nil {
+			return err
+		}
+
+		if compareJSON {
+			output, err := json.MarshalIndent(c.Result(), "", "  ")
+			if err != nil {
+				return fmt.Errorf("encoding JSON: %w", err)
+			}
+			fmt.Println(string(output))
+			return nil
+		}
+		printComparison(c)
+		return nil
+	},
+}
+
+// comparePeriod is one side of a comparison.
+type comparePeriod struct {
+	Label string
+	From  time.Time
+	To    time.Time
+	// Hourly is the consumption in each hour since From, up to To or now.
+	Hourly []float64
+}
+
+func (p comparePeriod) Total() float64 {
+	return p.CumulativeAt(len(p.Hourly))
+}
+
+// CumulativeAt returns the consumption in the first hours of the period.
+func (p comparePeriod) CumulativeAt(hours int) float64 {
+	total := 0.0
+	for _, v := range p.Hourly[:min(hours, len(p.Hourly))] {
+		total += v
+	}
+	return total
+}
+
+func (p comparePeriod) Peak() float64 {
+	peak := 0.0
+	for _, v := range p.Hourly {
+		peak = max(peak, v)
+	}
+	return peak
+}
+
+func (p comparePeriod) PerDay() float64 {
+	if len(p.Hourly) == 0 {
+		return 0
+	}
+	return p.Total() / (float64(len(p.Hourly)) / 24)
+}
+
+// comparison holds the current (or first) period A and the period B it is
+// measured against.
+type comparison struct {
+	A, B comparePeriod
+}
+
+// Elapsed is the number of hours of B that A is compared with.
+func (c comparison) Elapsed() int {
+	return len(c.A.Hourly)
+}
+
+func loadComparison(database *db.DB, args []string, now time.Time) (comparison, error) {
+	var c comparison
+	mode := "week"
+	if len(args) == 1 {
+		mode = args[0]
+	}
+
+	var aFrom, aTo, bFrom, bTo time.Time
+	switch {
+	case len(args) == 2:
+		var err error
+		if aFrom, aTo, err = parseCompareRange(args[0]); err != nil {
+			return c, err
+		}
+		if bFrom, bTo, err = parseCompareRange(args[1]); err != nil {
+			return c, err
+		}
+		c.A.Label, c.B.Label = formatCompareRange(aFrom, aTo), formatCompareRange(bFrom, bTo)
+	case mode == "day":
+		y, m, d := now.Date()
+		aFrom = time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+		aTo, bFrom, bTo = aFrom.AddDate(0, 0, 1), aFrom.AddDate(0, 0, -1), aFrom
+		c.A.Label, c.B.Label = "Today", "Yesterday"
+	case mode == "week":
+		y, m, d := now.Date()
+		offset := (int(now.Weekday()) + 6) % 7
+		aFrom = time.Date(y, m, d-offset, 0, 0, 0, 0, now.Location())
+		aTo, bFrom, bTo = aFrom.AddDate(0, 0, 7), aFrom.AddDate(0, 0, -7), aFrom
+		c.A.Label, c.B.Label = "This week", "Last week"
+	case mode == "cycle":
+		cycles, err := database.GetCycles(2)
+		if err != nil {
+			return c, fmt.Errorf("getting cycles: %w", err)
+		}
+		if len(cycles) < 2 {
+			return c, fmt.Errorf("need two recorded quota cycles to compare; only %d found", len(cycles))
+		}
+		aFrom, aTo, bFrom, bTo = cycles[0].Start, cycles[0].RenewsAt, cycles[1].Start, cycles[1].RenewsAt
+		c.A.Label, c.B.Label = "This cycle", "Last cycle"
+	default:
+		return c, fmt.Errorf("unknown period: %s (valid: day, week, cycle, or two FROM..TO ranges)", mode)
+	}
+
+	var err error
+	if c.A, err = loadComparePeriod(database, c.A.Label, aFrom, aTo, now); err != nil {
+		return c, err
+	}
+	if c.B, err = loadComparePeriod(database, c.B.Label, bFrom, bTo, now); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+// parseCompareRange parses FROM..TO; a date as TO includes the whole day.
+func parseCompareRange(s string) (time.Time, time.Time, error) {
+	fromStr, toStr, ok := strings.Cut(s, "..")
+	if !ok {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid range %q: expected FROM..TO", s)
+	}
+	from, _, err := parseCompareTime(fromStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	to, isDate, err := parseCompareTime(toStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if isDate {
+		to = to.AddDate(0, 0, 1)
+	}
+	if !to.After(from) {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid range %q: end is not after start", s)
+	}
+	return from, to, nil
+}
+
+func parseCompareTime(s string) (time.Time, bool, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid time %q: use YYYY-MM-DD or RFC 3339", s)
+	}
+	return t, false, nil
+}
+
+// formatCompareRange shows whole days as inclusive dates and anything
+// else with times.
+func formatCompareRange(from, to time.Time) string {
+	from, to = from.Local(), to.Local()
+	if isMidnight(from) && isMidnight(to) {
+		last := to.AddDate(0, 0, -1)
+		if !last.After(from) {
+			return from.Format("2006-01-02")
+		}
+		return from.Format("01-02") + " → " + last.Format("01-02")
+	}
+	return from.Format("01-02 15:04") + " → " + to.Format("01-02 15:04")
+}
+
+func isMidnight(t time.Time) bool {
+	return t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0
+}
+
+// loadComparePeriod bins the consumption between from and the earlier of
+// to and now by hour since from.
+func loadComparePeriod(database *db.DB, label string, from, to, now time.Time) (comparePeriod, error) {
+	p := comparePeriod{Label: label, From: from, To: to}
+	end := to
+	if now.Before(end) {
+		end = now
+	}
+	if !end.After(from) {
+		return p, nil
+	}
+
+	// Start a day early to include the interval crossing from
+	intervals, err := database.GetIntervals(from.Add(-24 * time.Hour))
+	if err != nil {
+		return p, fmt.Errorf("getting intervals: %w", err)
+	}
+
+	p.Hourly = make([]float64, int(math.Ceil(end.Sub(from).Hours())))
+	spreadHourly(intervals, func(t time.Time, v float64) {
+		if t.Before(from) || !t.Before(end) {
+			return
+		}
+		p.Hourly[int(t.Sub(from)/time.Hour)] += v
+	})
+	return p, nil
+}
+
+type CompareSide struct {
+	Label      string    `json:"label"`
+	From       string    `json:"from"`
+	To         string    `json:"to"`
+	Consumed   float64   `json:"consumed"`
+	PeakHour   float64   `json:"peak_hour"`
+	PerDay     float64   `json:"per_day"`
+	Cumulative []float64 `json:"cumulative"`
+}
+
+type CompareResult struct {
+	Current      CompareSide `json:"current"`
+	Previous     CompareSide `json:"previous"`
+	ElapsedHours int         `json:"elapsed_hours"`
+	// PreviousAtElapsed is the previous period's consumption after the
+	// same number of hours as the current one.
+	PreviousAtElapsed float64  `json:"previous_at_elapsed"`
+	Delta             float64  `json:"delta"`
+	ChangePercent     *float64 `json:"change_percent,omitempty"`
+}
+
+func newCompareSide(p comparePeriod) CompareSide {
+	side := CompareSide{
+		Label:      p.Label,
+		From:       p.From.Format(time.RFC3339),
+		To:         p.To.Format(time.RFC3339),
+		Consumed:   round1(p.Total()),
+		PeakHour:   round1(p.Peak()),
+		PerDay:     round1(p.PerDay()),
+		Cumulative: make([]float64, len(p.Hourly)),
+	}
+	total := 0.0
+	for i, v := range p.Hourly {
+		total += v
+		side.Cumulative[i] = round1(total)
+	}
+	return side
+}
+
+func (c comparison) Result() CompareResult {
+	r := CompareResult{
+		Current:           newCompareSide(c.A),
+		Previous:          newCompareSide(c.B),
+		ElapsedHours:      c.Elapsed(),
+		PreviousAtElapsed: c.B.CumulativeAt(c.Elapsed()),
+	}
+	r.PreviousAtElapsed = round1(r.PreviousAtElapsed)
+	r.Delta = round1(r.Current.Consumed - r.PreviousAtElapsed)
+	if r.PreviousAtElapsed > 0 {
+		pct := round1(r.Delta / r.PreviousAtElapsed * 100)
+		r.ChangePercent = &pct
+	}
+	return r
+}
+
+// round1 keeps JSON output free of float noise from spreading intervals.
+func round1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
+
+// formatChange renders a delta with its percentage, e.g. "+40 (+10.5%)".
+func formatChange(r CompareResult) string {
+	s := fmt.Sprintf("%+.0f", r.Delta)
+	if r.ChangePercent != nil {
+		s += fmt.Sprintf(" (%+.1f%%)", *r.ChangePercent)
+	}
+	return s
+}
+
+func printComparison(c comparison) {
+	r := c.Result()
+
+	fmt.Printf("%s vs %s\n", c.A.Label, c.B.Label)
+	fmt.Println("──────────────────────────────────────────────────────────────────────")
+	fmt.Printf("%-16s %26s %26s\n", "", c.A.Label, c.B.Label)
+	fmt.Printf("%-16s %26s %26s\n", "Period", formatCompareRange(c.A.From, c.A.To), formatCompareRange(c.B.From, c.B.To))
+	fmt.Printf("%-16s %26.0f %26.0f\n", "Consumed", r.Current.Consumed, r.Previous.Consumed)
+	fmt.Printf("%-16s %26.0f %26.0f   %s\n", "After "+formatDurationShort(time.Duration(r.ElapsedHours)*time.Hour), r.Current.Consumed, r.PreviousAtElapsed, formatChange(r))
+	fmt.Printf("%-16s %26.0f %26.0f\n", "Peak hour", r.Current.PeakHour, r.Previous.PeakHour)
+	fmt.Printf("%-16s %26.1f %26.1f\n", "Avg per day", r.Current.PerDay, r.Previous.PerDay)
+
+	if len(c.A.Hourly) >= 2 || len(c.B.Hourly) >= 2 {
+		printCompareChart(c)
+	}
+}
+
+// printCompareChart overlays the cumulative consumption of both periods,
+// aligned by hours since their start.
+func printCompareChart(c comparison) {
+	const width, height = 60, 12
+
+	hours := max(len(c.A.Hourly), len(c.B.Hourly))
+	maxVal := max(c.A.Total(), c.B.Total(), 1)
+
+	grid := make([][]rune, height)
+	for i := range grid {
+		grid[i] = []rune(strings.Repeat(" ", width))
+	}
+	plot := func(p comparePeriod, mark rune) {
+		for x := 0; x < width; x++ {
+			h := int(math.Ceil(float64(x+1) / float64(width) * float64(hours)))
+			if h > len(p.Hourly) {
+				return
+			}
+			y := int((1 - p.CumulativeAt(h)/maxVal) * float64(height-1))
+			grid[max(0, min(y, height-1))][x] = mark
+		}
+	}
+	plot(c.B, '.')
+	plot(c.A, '#')
+
+	fmt.Println()
+	fmt.Println("     Cumulative consumption by elapsed time")
+	fmt.Println("     " + strings.Repeat("─", width))
+	for y := 0; y < height; y++ {
+		label := "    "
+		if y == 0 {
+			label = fmt.Sprintf("%4.0f", maxVal)
+		} else if y == height-1 {
+			label = "   0"
+		}
+		fmt.Printf("%s │%s\n", label, string(grid[y]))
+	}
+	fmt.Println("     └" + strings.Repeat("─", width))
+	fmt.Println(padBetween("      0h", formatDurationShort(time.Duration(hours)*time.Hour), width+6))
+	fmt.Println()
+	fmt.Printf("Legend: # = %s  . = %s\n", c.A.Label, c.B.Label)
+}
+
+// generateCompareSVG overlays the cumulative consumption of both periods
+// for the compare page.
+func generateCompareSVG(c comparison) string {
+	hours := max(len(c.A.Hourly), len(c.B.Hourly))
+	if hours < 2 {
+		return "<text x='400' y='200' text-anchor='middle' fill='#71767b'>Need more data points</text>"
+	}
+
+	width := 800.0
+	height := 400.0
+	padding := 60.0
+	chartWidth := width - 2*padding
+	chartHeight := height - 2*padding
+	maxVal := max(c.A.Total(), c.B.Total(), 1)
+
+	points := func(p comparePeriod) string {
+		pts := []string{fmt.Sprintf("%.1f,%.1f", padding, padding+chartHeight)}
+		total := 0.0
+		for i, v := range p.Hourly {
+			total += v
+			x := padding + float64(i+1)/float64(hours)*chartWidth
+			y := padding + chartHeight - total/maxVal*chartHeight
+			pts = append(pts, fmt.Sprintf("%.1f,%.1f", x, y))
+		}
+		return strings.Join(pts, " ")
+	}
+
+	var svg strings.Builder
+	svg.WriteString(fmt.Sprintf(`<svg viewBox="0 0 %.0f %.0f" xmlns="http://www.w3.org/2000/svg">`, width, height))
+	svg.WriteString(`<rect width="100%" height="100%" fill="#0f1419"/>`)
+	svg.WriteString(fmt.Sprintf(`<line x1="%.0f" y1="%.0f" x2="%.0f" y2="%.0f" stroke="#2f3336" stroke-width="1"/>`, padding, padding, padding, height-padding))
+	svg.WriteString(fmt.Sprintf(`<line x1="%.0f" y1="%.0f" x2="%.0f" y2="%.0f" stroke="#2f3336" stroke-width="1"/>`, padding, height-padding, width-padding, height-padding))
+
+	svg.WriteString(fmt.Sprintf(`<text x="%.0f" y="%.0f" fill="#1d9bf0" font-size="12">%s</text>`, padding, padding-20, template.HTMLEscapeString(c.A.Label)))
+	svg.WriteString(fmt.Sprintf(`<text x="%.0f" y="%.0f" fill="#71767b" font-size="12">%s</text>`, padding+220, padding-20, template.HTMLEscapeString(c.B.Label)))
+	svg.WriteString(fmt.Sprintf(`<text x="%.0f" y="%.0f" fill="#71767b" font-size="10" text-anchor="end">%.0f</text>`, padding-6, padding+4, maxVal))
+	svg.WriteString(fmt.Sprintf(`<text x="%.0f" y="%.0f" fill="#71767b" font-size="10" text-anchor="end">0</text>`, padding-6, height-padding+4))
+
+	svg.WriteString(fmt.Sprintf(`<polyline points="%s" fill="none" stroke="#71767b" stroke-width="2" stroke-dasharray="6 4"/>`, points(c.B)))
+	svg.WriteString(fmt.Sprintf(`<polyline points="%s" fill="none" stroke="#1d9bf0" stroke-width="2"/>`, points(c.A)))
+
+	for i := 0; i <= 4; i++ {
+		x := padding + float64(i)/4*chartWidth
+		label := formatDurationShort(time.Duration(float64(hours)*float64(i)/4) * time.Hour)
+		if i == 0 {
+			label = "0h"
+		}
+		svg.WriteString(fmt.Sprintf(`<text x="%.0f" y="%.0f" fill="#71767b" font-size="10" text-anchor="middle">%s</text>`, x, height-padding+20, label))
+	}
+
+	svg.WriteString(`</svg>`)
+	return svg.String()
+}
+
+// compareArgs maps the query of /compare and /partials/comparison to
+// command arguments.
+func compareArgs(q url.Values) []string {
+	if a, b := q.Get("a"), q.Get("b"); a != "" || b != "" {
+		return []string{a, b}
+	}
+	if p := q.Get("period"); p != "" {
+		return []string{p}
+	}
+	return nil
+}
+
+// handleComparePage renders the compare page, which loads the comparison
+// for the same query as a partial.
+func handleComparePage(render pageRenderer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := url.Values{}
+		for _, key := range []string{"period", "a", "b"} {
+			if v := r.URL.Query().Get(key); v != "" {
+				q.Set(key, v)
+			}
+		}
+		render(w, r, "compare.html", http.StatusOK, ComparePageData{
+			Query: template.URL(q.Encode()),
+			A:     q.Get("a"),
+			B:     q.Get("b"),
+		})
+	}
+}
+
+type ComparePageData struct {
+	// Query is passed on to /partials/comparison; it only contains
+	// encoded values of known keys.
+	Query template.URL
+	A     string
+	B     string
+}
+
+type ComparisonData struct {
+	Error      string
+	Result     CompareResult
+	Change     string
+	Elapsed    string
+	SVGContent template.HTML
+}
+
+func handleComparisonPartial(database *db.DB, tmpl *template.Template) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data := getComparisonData(database, compareArgs(r.URL.Query()))
+		w.Header().Set("Content-Type", "text/html")
+		if err := tmpl.ExecuteTemplate(w, "comparison.html", data); err != nil {
+			slog.Error("rendering partial", "partial", "comparison.html", "err", err)
+		}
+	}
+}
+
+func getComparisonData(database *db.DB, args []string) ComparisonData {
+	var data ComparisonData
+	c, err := loadComparison(database, args, time.Now())
+	if err != nil {
+		// Shown in place; htmx does not swap error responses
+		data.Error = err.Error()
+		return data
+	}
+	data.Result = c.Result()
+	data.Change = formatChange(data.Result)
+	data.Elapsed = formatDurationShort(time.Duration(data.Result.ElapsedHours) * time.Hour)
+	data.SVGContent = template.HTML(generateCompareSVG(c))
+	return data
+}
+
+func init() {
+	compareCmd.Flags().BoolVar(&compareJSON, "json", false, "Print the comparison as JSON")
+	rootCmd.AddCommand(compareCmd)
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCompareRange(t *testing.T) {
+	old := time.Local
+	time.Local = time.UTC
+	defer func() { time.Local = old }()
+
+	from, to, err := parseCompareRange("2025-01-13..2025-01-19")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !from.Equal(time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC)) || !to.Equal(time.Date(2025, 1, 20, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("got %v..%v, want the end date included", from, to)
+	}
+	if got := formatCompareRange(from, to); got != "01-13 → 01-19" {
+		t.Fatalf("label %q", got)
+	}
+
+	from, to, err = parseCompareRange("2025-01-13T08:00:00Z..2025-01-13T20:00:00Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if to.Sub(from) != 12*time.Hour {
+		t.Fatalf("got %v..%v, want times used as given", from, to)
+	}
+
+	for _, s := range []string{"2025-01-13", "2025-01-19..2025-01-13", "monday..friday"} {
+		if _, _, err := parseCompareRange(s); err == nil {
+			t.Errorf("%q: expected an error", s)
+		}
+	}
+}
+
+func TestComparisonResult_AlignsByElapsed(t *testing.T) {
+	c := comparison{
+		A: comparePeriod{Label: "This week", Hourly: []float64{10, 30}},
+		B: comparePeriod{Label: "Last week", Hourly: []float64{5, 15, 100}},
+	}
+	r := c.Result()
+	if r.ElapsedHours != 2 || r.PreviousAtElapsed != 20 || r.Delta != 20 {
+		t.Fatalf("elapsed %d, previous %v, delta %v; want 2, 20, 20", r.ElapsedHours, r.PreviousAtElapsed, r.Delta)
+	}
+	if r.ChangePercent == nil || *r.ChangePercent != 100 {
+		t.Fatalf("change %v, want 100%%", r.ChangePercent)
+	}
+	if r.Previous.Consumed != 120 || r.Previous.PeakHour != 100 {
+		t.Fatalf("previous consumed %v, peak %v; want 120, 100", r.Previous.Consumed, r.Previous.PeakHour)
+	}
+}
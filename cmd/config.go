@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aure/syntrack/internal/config"
+	"github.com/spf13/cobra"
+	"go.yaml.in/yaml/v3"
+)
+
+var configInitForce bool
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Show, check or create the configuration",
+	Long: `Syntrack reads its settings from, in increasing precedence: built-in
+defaults, the YAML config file (--config, or ~/.syntrack.yaml / ./.syntrack.yaml),
+environment variables (SYNTRACK_SERVE_PORT, SYNTRACK_LOG_LEVEL, ...) and flags.
+
+Secrets can also be read from files: SYNTHETIC_API_KEY_FILE and
+SYNTRACK_AUTH_TOKENS_FILE, or the systemd credentials synthetic_api_key and
+syntrack_auth_tokens in $CREDENTIALS_DIRECTORY.`,
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the effective configuration with secrets redacted",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		source := "none (defaults, environment and flags only)"
+		if cfg.File != "" {
+			source = cfg.File
+		}
+		fmt.Printf("# Config file: %s\n", source)
+
+		out, err := yaml.Marshal(cfg.Redacted())
+		if err != nil {
+			return fmt.Errorf("encoding config: %w", err)
+		}
+		fmt.Print(string(out))
+		return nil
+	},
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the configuration for errors",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var problems []string
+		if err := cfg.Validate(); err != nil {
+			problems = append(problems, strings.Split(err.Error(), "\n")...)
+		}
+		if _, err := cfg.LoadAuthTokens(); err != nil {
+			problems = append(problems, fmt.Sprintf("token file %s: %v", cfg.TokenFile, err))
+		}
+
+		if cfg.File != "" {
+			fmt.Printf("Config file: %s\n", cfg.File)
+		}
+		if cfg.APIKey == "" {
+			fmt.Println("Warning: no API key set; 'collect' will fail (set SYNTHETIC_API_KEY or SYNTHETIC_API_KEY_FILE)")
+		}
+
+		if len(problems) == 0 {
+			fmt.Println("✓ Configuration is valid")
+			return nil
+		}
+		for _, p := range problems {
+			fmt.Printf("✗ %s\n", p)
+		}
+		return fmt.Errorf("configuration has %d problem(s)", len(problems))
+	},
+}
+
+var configInitCmd = &cobra.Command{
+	Use:   "init [file]",
+	Short: "Write a commented config file with the defaults",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := cfgFile
+		if len(args) == 1 {
+			path = args[0]
+		}
+		if path == "" {
+			var err error
+			path, err = config.DefaultFile()
+			if err != nil {
+				return fmt.Errorf("getting home directory: %w", err)
+			}
+		}
+
+		if _, err := os.Stat(path); err == nil && !configInitForce {
+			return fmt.Errorf("%s already exists (use --force to overwrite)", path)
+		} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(configTemplate), 0600); err != nil {
+			return fmt.Errorf("writing config file: %w", err)
+		}
+		fmt.Printf("✓ Wrote %s\n", path)
+		return nil
+	},
+}
+
+const configTemplate = `# Syntrack configuration. Environment variables and flags override these
+# values; run 'syntrack config show' to see the effective settings.
+
+# Synthetic API key. Prefer api_key_file (or SYNTHETIC_API_KEY_FILE) so the
+# key is not stored here; the file may contain just the key or a .env line.
+# api_key: ""
+# api_key_file: /var/lib/syntrack/.env
+
+database_path: usage.db
+
+# Timezone for displayed times and day boundaries (default: system).
+# timezone: Europe/Berlin
+
+# Dashboard tokens in addition to the token file ('syntrack token generate').
+# auth_tokens: []
+# auth_tokens_file: ""
+# token_file: ~/.syntrack/tokens
+
+log:
+  level: info        # debug, info, warn, error
+  format: text       # text, json
+  # file: ~/.syntrack/logs/syntrack.log
+  max_size: 10       # megabytes before rotation
+  max_backups: 5
+
+serve:
+  port: 8080
+  auth: false
+  bind_all: false
+  tailscale: false
+  # tailscale_ip: 100.64.0.1
+  # trusted_proxy: [127.0.0.1]
+  no_localhost_bypass: false
+  session_ttl: 12h
+  refresh_interval: 5m
+  # tls_cert: /etc/syntrack/cert.pem
+  # tls_key: /etc/syntrack/key.pem
+  tls_self_signed: false
+  # http_port: 8081
+  # pid_file: ~/.syntrack/syntrack.pid
+
+collect:
+  timeout: 30s
+
+# Daily or weekly digest ('syntrack digest send' from cron, or 'digest run').
+# A channel is enabled by setting smtp.host or webhook.url.
+digest:
+  period: daily      # daily, weekly
+  time: "08:00"      # local time the digest covers up to
+  weekday: monday    # for weekly digests
+  smtp:
+    # host: smtp.example.com
+    port: 587
+    # username: syntrack
+    # password: ""   # prefer SYNTRACK_DIGEST_SMTP_PASSWORD
+    # from: syntrack@example.com
+    # to: [team@example.com]
+  webhook:
+    # url: https://hooks.example.com/...   # or SYNTRACK_DIGEST_WEBHOOK_URL
+    # template: '{"text": {{json .Text}}}'
+
+# Metering proxy in front of the Synthetic API ('syntrack proxy').
+proxy:
+  listen: 127.0.0.1:8090
+  upstream: https://api.synthetic.new
+  # labels:                  # client names by key ID ('syntrack proxy key-id')
+  #   "3f2a9c0b1d4e": alice
+
+# Quota guard ('syntrack guard', or 'syntrack proxy --guard'): refuses
+# requests with 429 once the leftover reaches the client's reserve.
+guard:
+  listen: 127.0.0.1:8091
+  upstream: https://api.synthetic.new
+  reserve: 0                 # normal clients
+  # low_reserve: 500         # low priority clients
+  default_priority: normal   # critical, normal, low
+  # priorities:              # by key label (proxy.labels), in lower case
+  #   deploy-bot: critical
+  #   nightly-eval: low
+`
+
+func init() {
+	configInitCmd.Flags().BoolVarP(&configInitForce, "force", "f", false, "Overwrite an existing config file")
+	configCmd.AddCommand(configShowCmd)
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(configInitCmd)
+	rootCmd.AddCommand(configCmd)
+}
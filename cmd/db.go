@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aure/syntrack/internal/config"
+	"github.com/aure/syntrack/internal/db"
+	"github.com/spf13/cobra"
+)
+
+var backupKeep int
+var restoreForce bool
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Back up and restore the usage database",
+}
+
+var dbBackupCmd = &cobra.Command{
+	Use:   "backup [file]",
+	Short: "Write a consistent copy of the database",
+	Long: `Write a consistent copy of the usage database while other syntrack
+processes (serve, collect) keep running.
+
+Without a file argument the backup is written to ~/.syntrack/backups with a
+dated name. Use --keep to retain only the N most recent dated backups; it
+cannot be combined with a file argument.
+
+Examples:
+  syntrack db backup
+  syntrack db backup --keep 7
+  syntrack db backup /mnt/share/usage.db`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 1 && backupKeep > 0 {
+			return fmt.Errorf("--keep only rotates dated backups in ~/.syntrack/backups and cannot be used with a file argument")
+		}
+
+		database, err := db.New(dbPath)
+		if err != nil {
+			return fmt.Errorf("opening database: %w", err)
+		}
+		defer database.Close()
+
+		backupDir, err := backupsDir()
+		if err != nil {
+			return err
+		}
+
+		dest := filepath.Join(backupDir, backupFileName("usage", time.Now()))
+		if len(args) == 1 {
+			dest = args[0]
+		}
+
+		if err := database.Backup(dest); err != nil {
+			return fmt.Errorf("writing backup: %w", err)
+		}
+
+		info, err := db.Inspect(dest)
+		if err != nil {
+			return fmt.Errorf("verifying backup: %w", err)
+		}
+		fmt.Printf("Backup written to %s (%d snapshots)\n", dest, info.Snapshots)
+
+		if backupKeep > 0 {
+			removed, err := pruneBackups(backupDir, "usage", backupKeep)
+			if err != nil {
+				return fmt.Errorf("rotating backups: %w", err)
+			}
+			for _, path := range removed {
+				fmt.Printf("Removed old backup %s\n", path)
+			}
+		}
+
+		return nil
+	},
+}
+
+var dbRestoreCmd = &cobra.Command{
+	Use:   "restore <file>",
+	Short: "Replace the database with a backup",
+	Long: `Replace the usage database with a backup file.
+
+The backup is checked for integrity and for the usage_snapshots schema before
+anything is touched. A restore that would lose snapshots (the backup holds
+fewer rows than the live database) is refused unless --force is given. The
+current database is saved to ~/.syntrack/backups before being replaced.
+
+The database must not be in use: the restore is refused while 'syntrack serve'
+runs, so stop it first with 'syntrack serve stop' and start it again afterwards.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		src := args[0]
+		if db.SameFile(src, dbPath) {
+			return fmt.Errorf("%s is the current database", src)
+		}
+		// The server would keep writing to the replaced file
+		if pid, running, err := runningServerPID(); err != nil {
+			return err
+		} else if running {
+			return fmt.Errorf("syntrack serve (PID %d) has the database open; stop it with 'syntrack serve stop' before restoring", pid)
+		}
+
+		info, err := db.Inspect(src)
+		if err != nil {
+			return fmt.Errorf("validating %s: %w", src, err)
+		}
+		if info.Snapshots == 0 && !restoreForce {
+			return fmt.Errorf("%s contains no snapshots; use --force to restore it anyway", src)
+		}
+
+		if _, err := os.Stat(dbPath); err == nil {
+			live, err := db.Inspect(dbPath)
+			if err != nil {
+				return fmt.Errorf("inspecting current database: %w", err)
+			}
+			if info.Snapshots < live.Snapshots && !restoreForce {
+				return fmt.Errorf("backup has %d snapshots but the current database has %d; use --force to restore anyway", info.Snapshots, live.Snapshots)
+			}
+
+			backupDir, err := backupsDir()
+			if err != nil {
+				return err
+			}
+			database, err := db.New(dbPath)
+			if err != nil {
+				return fmt.Errorf("opening database: %w", err)
+			}
+			safety := filepath.Join(backupDir, backupFileName("pre-restore", time.Now()))
+			err = database.Backup(safety)
+			database.Close()
+			if err != nil {
+				return fmt.Errorf("saving current database: %w", err)
+			}
+			fmt.Printf("Current database saved to %s\n", safety)
+		}
+
+		if err := db.Restore(dbPath, src); err != nil {
+			return fmt.Errorf("restoring database: %w", err)
+		}
+
+		fmt.Printf("Restored %s from %s (%d snapshots", dbPath, src, info.Snapshots)
+		if info.LatestSnapshot != nil {
+			fmt.Printf(", latest %s", info.LatestSnapshot.Format("2006-01-02 15:04"))
+		}
+		fmt.Println(")")
+		return nil
+	},
+}
+
+func backupsDir() (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", fmt.Errorf("getting home directory: %w", err)
+	}
+	return filepath.Join(dir, "backups"), nil
+}
+
+func backupFileName(prefix string, t time.Time) string {
+	return fmt.Sprintf("%s-%s.db", prefix, t.Format("20060102-150405"))
+}
+
+// pruneBackups deletes all but the newest keep dated backups with the given
+// prefix. The timestamp in the name sorts chronologically.
+func pruneBackups(dir, prefix string, keep int) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, prefix+"-*.db"))
+	if err != nil {
+		return nil, err
+	}
+
+	var dated []string
+	for _, path := range matches {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), prefix+"-"), ".db")
+		if _, err := time.Parse("20060102-150405", stamp); err == nil {
+			dated = append(dated, path)
+		}
+	}
+	if len(dated) <= keep {
+		return nil, nil
+	}
+
+	sort.Strings(dated)
+	var removed []string
+	for _, path := range dated[:len(dated)-keep] {
+		if err := os.Remove(path); err != nil {
+			return removed, err
+		}
+		removed = append(removed, path)
+	}
+	return removed, nil
+}
+
+func init() {
+	dbBackupCmd.Flags().IntVarP(&backupKeep, "keep", "k", 0, "Keep only the N most recent dated backups in ~/.syntrack/backups (0 keeps all)")
+	dbRestoreCmd.Flags().BoolVarP(&restoreForce, "force", "f", false, "Restore even if the backup has fewer snapshots than the current database")
+	dbCmd.AddCommand(dbBackupCmd)
+	dbCmd.AddCommand(dbRestoreCmd)
+	rootCmd.AddCommand(dbCmd)
+}
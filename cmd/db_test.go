@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aure/syntrack/internal/db"
+)
+
+// useTestDB points the commands at a fresh database holding n snapshots
+// and a temporary home directory.
+func useTestDB(t *testing.T, n int) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	path := filepath.Join(home, "usage.db")
+	writeTestDB(t, path, n)
+
+	old := dbPath
+	dbPath = path
+	t.Cleanup(func() { dbPath = old })
+	return path
+}
+
+func writeTestDB(t *testing.T, path string, n int) {
+	t.Helper()
+	database, err := db.New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	renews := time.Now().Add(time.Hour)
+	for i := range n {
+		if err := database.InsertSnapshot(100, i+1, &renews); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDBRestore(t *testing.T) {
+	tests := []struct {
+		name    string
+		live    int
+		backup  int
+		force   bool
+		wantErr string
+		want    int
+	}{
+		{"more snapshots", 2, 3, false, "", 3},
+		{"fewer snapshots", 3, 2, false, "use --force", 3},
+		{"fewer snapshots forced", 3, 2, true, "", 2},
+		{"empty backup", 1, 0, false, "no snapshots", 1},
+		{"empty backup forced", 1, 0, true, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			live := useTestDB(t, tt.live)
+			src := filepath.Join(t.TempDir(), "backup.db")
+			writeTestDB(t, src, tt.backup)
+
+			restoreForce = tt.force
+			defer func() { restoreForce = false }()
+			err := dbRestoreCmd.RunE(dbRestoreCmd, []string{src})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			info, err := db.Inspect(live)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Snapshots != tt.want {
+				t.Fatalf("live database has %d snapshots, want %d", info.Snapshots, tt.want)
+			}
+		})
+	}
+}
+
+func TestDBRestore_RefusesForeignSchemaAndItself(t *testing.T) {
+	live := useTestDB(t, 1)
+
+	foreign := filepath.Join(t.TempDir(), "foreign.db")
+	if err := os.WriteFile(foreign, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := dbRestoreCmd.RunE(dbRestoreCmd, []string{foreign}); err == nil || !strings.Contains(err.Error(), "usage_snapshots") {
+		t.Fatalf("foreign schema: err = %v", err)
+	}
+
+	restoreForce = true
+	defer func() { restoreForce = false }()
+	if err := dbRestoreCmd.RunE(dbRestoreCmd, []string{live}); err == nil || !strings.Contains(err.Error(), "current database") {
+		t.Fatalf("restoring the live database: err = %v", err)
+	}
+	backups, _ := filepath.Glob(filepath.Join(filepath.Dir(live), ".syntrack", "backups", "*"))
+	if len(backups) != 0 {
+		t.Fatalf("refused restores left backups: %v", backups)
+	}
+}
+
+func TestDBRestore_RefusesWhileServerRuns(t *testing.T) {
+	live := useTestDB(t, 1)
+	src := filepath.Join(t.TempDir(), "backup.db")
+	writeTestDB(t, src, 3)
+
+	usePIDFile(t)
+	lock, err := writePIDFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer removePIDFile(lock)
+
+	restoreForce = true
+	defer func() { restoreForce = false }()
+	if err := dbRestoreCmd.RunE(dbRestoreCmd, []string{src}); err == nil || !strings.Contains(err.Error(), "syntrack serve stop") {
+		t.Fatalf("restore while serving: err = %v", err)
+	}
+	if info, err := db.Inspect(live); err != nil || info.Snapshots != 1 {
+		t.Fatalf("live database after refused restore = %+v, %v", info, err)
+	}
+}
+
+func TestPruneBackups(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{
+		"usage-20250101-120000.db",
+		"usage-20250103-120000.db",
+		"usage-20250102-120000.db",
+		"usage-20250104-120000.db",
+		"usage-manual.db",
+		"pre-restore-20250101-120000.db",
+	}
+	for _, name := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := pruneBackups(dir, "usage", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(dir, "usage-20250101-120000.db"), filepath.Join(dir, "usage-20250102-120000.db")}
+	if !slices.Equal(removed, want) {
+		t.Fatalf("removed %v, want %v", removed, want)
+	}
+
+	left, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(left) != len(files)-2 {
+		t.Fatalf("left %v", left)
+	}
+	if removed, err := pruneBackups(dir, "usage", 2); err != nil || len(removed) != 0 {
+		t.Fatalf("second prune removed %v, %v", removed, err)
+	}
+}
+
+func TestDBBackup_KeepWithFileArgument(t *testing.T) {
+	live := useTestDB(t, 1)
+	backupDir := filepath.Join(filepath.Dir(live), ".syntrack", "backups")
+	if err := os.MkdirAll(backupDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	old := filepath.Join(backupDir, "usage-20250101-120000.db")
+	if err := os.WriteFile(old, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	backupKeep = 1
+	defer func() { backupKeep = 0 }()
+	dest := filepath.Join(t.TempDir(), "copy.db")
+	if err := dbBackupCmd.RunE(dbBackupCmd, []string{dest}); err == nil {
+		t.Fatal("--keep with a file argument was accepted")
+	}
+	if _, err := os.Stat(old); err != nil {
+		t.Fatalf("dated backup was pruned: %v", err)
+	}
+
+	// Without a file argument the dated backups rotate
+	if err := dbBackupCmd.RunE(dbBackupCmd, nil); err != nil {
+		t.Fatal(err)
+	}
+	left, _ := filepath.Glob(filepath.Join(backupDir, "usage-*.db"))
+	if len(left) != 1 || left[0] == old {
+		t.Fatalf("backups after rotation: %v", left)
+	}
+}
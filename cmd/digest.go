@@ -0,0 +1,336 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/aure/syntrack/internal/config"
+	"github.com/aure/syntrack/internal/db"
+	"github.com/aure/syntrack/internal/digest"
+	"github.com/spf13/cobra"
+)
+
+// digestRetry is how soon 'digest run' retries a failed delivery.
+const digestRetry = 5 * time.Minute
+
+// digestTimeout bounds a single delivery through all channels.
+const digestTimeout = time.Minute
+
+var digestForce bool
+var digestDryRun bool
+
+var digestCmd = &cobra.Command{
+	Use:   "digest",
+	Short: "Send a daily or weekly usage digest",
+	Long: `Send a summary of consumption, leftover, projected exhaustion and notable
+events (renewals, exhaustion, anomalies) by email and/or webhook.
+
+The digest is configured in the digest section of the config file:
+
+  digest:
+    period: daily          # or weekly
+    time: "08:00"          # local time of day
+    weekday: monday        # for weekly digests
+    smtp:
+      host: smtp.example.com
+      port: 587
+      username: syntrack
+      password: ...        # or SYNTRACK_DIGEST_SMTP_PASSWORD
+      from: syntrack@example.com
+      to: [team@example.com]
+    webhook:
+      url: https://hooks.example.com/...
+      template: '{"text": {{json .Text}}}'
+
+Each delivery is recorded per channel, so a digest is sent at most once
+per scheduled time, even across restarts.`,
+}
+
+var digestSendCmd = &cobra.Command{
+	Use:   "send",
+	Short: "Send the digest for the latest scheduled time, unless already sent",
+	Long: `Send the digest for the latest scheduled time through every configured
+channel that has not delivered it yet. Safe to run from cron more often
+than the schedule.
+
+Examples:
+  syntrack digest send
+  syntrack digest send --dry-run
+  syntrack digest send --force`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sched, err := digestSchedule(cfg.Digest)
+		if err != nil {
+			return err
+		}
+		senders, err := digestSenders(cfg.Digest)
+		if err != nil {
+			return err
+		}
+		if len(senders) == 0 && !digestDryRun {
+			return fmt.Errorf("no digest channel configured; set digest.smtp.host or digest.webhook.url")
+		}
+
+		database, err := db.New(dbPath)
+		if err != nil {
+			return fmt.Errorf("opening database: %w", err)
+		}
+		defer database.Close()
+
+		if digestDryRun {
+			d, err := buildDigest(database, sched, sched.Slot(time.Now()))
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Subject: %s\n\n%s", d.Subject(), d.Text())
+			for _, s := range senders {
+				if w, ok := s.(*digest.WebhookSender); ok {
+					body, err := w.Body(d)
+					if err != nil {
+						return err
+					}
+					fmt.Printf("\nWebhook body:\n%s\n", body)
+				}
+			}
+			return nil
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), digestTimeout)
+		defer cancel()
+		return deliverDigest(ctx, database, sched, senders, time.Now(), digestForce)
+	},
+}
+
+var digestRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Send digests on schedule until interrupted",
+	Long: `Stay in the foreground and send each digest at its scheduled time. A
+digest missed while not running is sent on start; failed deliveries are
+retried every 5 minutes.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sched, err := digestSchedule(cfg.Digest)
+		if err != nil {
+			return err
+		}
+		senders, err := digestSenders(cfg.Digest)
+		if err != nil {
+			return err
+		}
+		if len(senders) == 0 {
+			return fmt.Errorf("no digest channel configured; set digest.smtp.host or digest.webhook.url")
+		}
+
+		database, err := db.New(dbPath)
+		if err != nil {
+			return fmt.Errorf("opening database: %w", err)
+		}
+		defer database.Close()
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		for {
+			sendCtx, cancel := context.WithTimeout(ctx, digestTimeout)
+			err := deliverDigest(sendCtx, database, sched, senders, time.Now(), false)
+			cancel()
+
+			next := sched.Next(time.Now())
+			wait := time.Until(next)
+			if err != nil && ctx.Err() == nil {
+				slog.Error("sending digest", "err", err)
+				wait = min(wait, digestRetry)
+			}
+			slog.Debug("next digest", "at", next.Format(time.RFC3339), "wait", wait.Round(time.Second).String())
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(wait):
+			}
+		}
+	},
+}
+
+func digestSchedule(c config.DigestConfig) (digest.Schedule, error) {
+	weekday, err := config.ParseWeekday(c.Weekday)
+	if err != nil {
+		return digest.Schedule{}, err
+	}
+	return digest.NewSchedule(c.Period, c.Time, weekday, time.Local)
+}
+
+func digestSenders(c config.DigestConfig) ([]digest.Sender, error) {
+	var senders []digest.Sender
+	if c.SMTP.Host != "" {
+		if c.SMTP.From == "" || len(c.SMTP.To) == 0 {
+			return nil, fmt.Errorf("digest.smtp.from and digest.smtp.to are required with digest.smtp.host")
+		}
+		senders = append(senders, &digest.SMTPSender{
+			Host:     c.SMTP.Host,
+			Port:     c.SMTP.Port,
+			Username: c.SMTP.Username,
+			Password: c.SMTP.Password,
+			From:     c.SMTP.From,
+			To:       c.SMTP.To,
+		})
+	}
+	if c.Webhook.URL != "" {
+		w, err := digest.NewWebhookSender(c.Webhook.URL, c.Webhook.Template)
+		if err != nil {
+			return nil, err
+		}
+		senders = append(senders, w)
+	}
+	return senders, nil
+}
+
+// deliverDigest sends the digest for the latest slot through each sender
+// that has not delivered it yet, or through all of them with force.
+func deliverDigest(ctx context.Context, database *db.DB, sched digest.Schedule, senders []digest.Sender, now time.Time, force bool) error {
+	slot := sched.Slot(now)
+	var pending []digest.Sender
+	for _, s := range senders {
+		sent, err := database.DigestSent(s.Name(), sched.Period, slot)
+		if err != nil {
+			return fmt.Errorf("reading digest log: %w", err)
+		}
+		if sent && !force {
+			slog.Debug("digest already sent", "channel", s.Name(), "slot", slot.Format(time.RFC3339))
+			continue
+		}
+		pending = append(pending, s)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	d, err := buildDigest(database, sched, slot)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, s := range pending {
+		if err := s.Send(ctx, d); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.Name(), err))
+			continue
+		}
+		if err := database.RecordDigest(s.Name(), sched.Period, slot); err != nil {
+			errs = append(errs, fmt.Errorf("recording %s digest: %w", s.Name(), err))
+			continue
+		}
+		slog.Info("digest sent", "channel", s.Name(), "period", sched.Period, "slot", slot.Format(time.RFC3339))
+	}
+	return errors.Join(errs...)
+}
+
+// buildDigest summarizes the period ending at slot. The quota figures are
+// the latest known, which may be newer than slot when sending late.
+func buildDigest(database *db.DB, sched digest.Schedule, slot time.Time) (digest.Digest, error) {
+	d := digest.Digest{Period: sched.Period, From: sched.Start(slot), To: slot, Events: []digest.Event{}}
+
+	latest, err := database.GetLatestSnapshot()
+	if err != nil {
+		return d, fmt.Errorf("getting latest snapshot: %w", err)
+	}
+	if d.BurnRate, err = database.GetBurnRate(24); err != nil {
+		return d, fmt.Errorf("calculating burn rate: %w", err)
+	}
+	d.BurnRate = math.Round(d.BurnRate*100) / 100
+	if latest != nil {
+		d.Used, d.Limit, d.Leftover, d.RenewsAt = latest.RequestsUsed, latest.SubscriptionLimit, latest.Leftover, latest.RenewsAt
+		if d.BurnRate > 0 {
+			exhausts := time.Now().Add(time.Duration(float64(latest.Leftover) / d.BurnRate * float64(time.Hour)))
+			if latest.RenewsAt == nil || exhausts.Before(*latest.RenewsAt) {
+				d.ExhaustsAt = &exhausts
+			}
+		}
+	}
+	pace, err := loadPace(database)
+	if err != nil {
+		return d, err
+	}
+	if pace != nil {
+		d.Pace = fmt.Sprintf("%s (%.2fx)", pace.Status(), pace.Ratio())
+	}
+
+	// Start a day early to include the interval crossing From
+	intervals, err := database.GetIntervals(d.From.Add(-24 * time.Hour))
+	if err != nil {
+		return d, fmt.Errorf("getting intervals: %w", err)
+	}
+	consumed := 0.0
+	spreadHourly(intervals, func(t time.Time, v float64) {
+		if !t.Before(d.From) && t.Before(d.To) {
+			consumed += v
+		}
+	})
+	d.Consumed = int(consumed + 0.5)
+
+	events, err := digestEvents(database, d.From, d.To)
+	if err != nil {
+		return d, err
+	}
+	d.Events = append(d.Events, events...)
+	return d, nil
+}
+
+// digestEvents lists renewals, exhaustion and anomalies between from and
+// to, oldest first.
+func digestEvents(database *db.DB, from, to time.Time) ([]digest.Event, error) {
+	var events []digest.Event
+	in := func(t time.Time) bool { return !t.Before(from) && t.Before(to) }
+
+	cycles, err := database.GetCycles(8)
+	if err != nil {
+		return nil, fmt.Errorf("getting cycles: %w", err)
+	}
+	for i := len(cycles) - 1; i >= 0; i-- {
+		if c := cycles[i]; c.StartKnown && in(c.Start) {
+			events = append(events, digest.Event{At: c.Start, Kind: "renewal", Message: "Quota renewed"})
+		}
+	}
+
+	snapshots, err := database.GetSnapshots(from)
+	if err != nil {
+		return nil, fmt.Errorf("getting snapshots: %w", err)
+	}
+	exhausted := false
+	for _, s := range snapshots {
+		if !in(s.CollectedAt) {
+			continue
+		}
+		if s.Leftover <= 0 && !exhausted {
+			events = append(events, digest.Event{At: s.CollectedAt, Kind: "exhausted", Message: fmt.Sprintf("Quota exhausted (%d of %d used)", s.RequestsUsed, s.SubscriptionLimit)})
+		}
+		exhausted = s.Leftover <= 0
+	}
+
+	anomalies, err := database.GetAnomalies(from)
+	if err != nil {
+		return nil, fmt.Errorf("getting anomalies: %w", err)
+	}
+	for _, a := range anomalies {
+		if in(a.IntervalEnd) {
+			events = append(events, digest.Event{At: a.IntervalEnd, Kind: "anomaly", Message: fmt.Sprintf("Spike: %d requests at %.1f/h (usually %.1f/h)", a.Consumed, a.Rate, a.BaselineRate)})
+		}
+	}
+
+	slices.SortStableFunc(events, func(a, b digest.Event) int { return a.At.Compare(b.At) })
+	return events, nil
+}
+
+func init() {
+	digestSendCmd.Flags().BoolVar(&digestForce, "force", false, "Send even if the digest was already delivered")
+	digestSendCmd.Flags().BoolVar(&digestDryRun, "dry-run", false, "Print the digest instead of sending it")
+	digestCmd.AddCommand(digestSendCmd)
+	digestCmd.AddCommand(digestRunCmd)
+	rootCmd.AddCommand(digestCmd)
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aure/syntrack/internal/db"
+	"github.com/spf13/cobra"
+)
+
+// exportPages are the dashboard pages written by export-site.
+var exportPages = []string{"index.html", "history.html", "stats.html", "compare.html"}
+
+var exportSiteCmd = &cobra.Command{
+	Use:   "export-site <dir>",
+	Short: "Export the dashboard as static HTML",
+	Long: `Render the dashboard pages with their current data into plain HTML files,
+with charts pre-rendered and no htmx calls, so the dashboard can be
+published by copying the directory, e.g. to a file share.
+
+The directory gets index.html, history.html, stats.html, compare.html
+(this week vs last week) and static/style.css. Existing files are
+overwritten. Run it from cron after collect to keep the export current.
+
+Like serve, it reads the templates from web/ in the current directory.
+
+Examples:
+  syntrack export-site ./site
+  syntrack collect && syntrack export-site /mnt/share/syntrack`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		database, err := db.New(dbPath)
+		if err != nil {
+			return fmt.Errorf("opening database: %w", err)
+		}
+		defer database.Close()
+
+		dir := args[0]
+		if err := exportSite(database, dir, time.Now()); err != nil {
+			return err
+		}
+		fmt.Printf("✓ Exported dashboard to %s\n", dir)
+		return nil
+	},
+}
+
+func exportSite(database *db.DB, dir string, now time.Time) error {
+	partials, err := template.ParseGlob("web/templates/partials/*.html")
+	if err != nil {
+		return fmt.Errorf("loading templates: %w", err)
+	}
+
+	rendered := make(map[string]template.HTML)
+	render := func(name string, data any) error {
+		var buf bytes.Buffer
+		if err := partials.ExecuteTemplate(&buf, name+".html", data); err != nil {
+			return fmt.Errorf("rendering %s: %w", name, err)
+		}
+		rendered[name] = template.HTML(buf.String())
+		return nil
+	}
+	for _, p := range dashboardPartials {
+		data, err := p.Provider(database)
+		if err != nil {
+			return fmt.Errorf("loading %s: %w", p.Name, err)
+		}
+		if err := render(p.Name, data); err != nil {
+			return err
+		}
+	}
+	if err := render("comparison", getComparisonData(database, nil)); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, "static"), 0755); err != nil {
+		return fmt.Errorf("creating directory: %w", err)
+	}
+	pd := pageData{Static: true, Partials: rendered, Exported: now.Format("2006-01-02 15:04")}
+	for _, page := range exportPages {
+		var buf bytes.Buffer
+		if err := renderPage(&buf, page, pd); err != nil {
+			return fmt.Errorf("rendering %s: %w", page, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, page), buf.Bytes(), 0644); err != nil {
+			return fmt.Errorf("writing %s: %w", page, err)
+		}
+	}
+
+	entries, err := os.ReadDir("web/static")
+	if err != nil {
+		return fmt.Errorf("reading static files: %w", err)
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join("web/static", e.Name()))
+		if err != nil {
+			return fmt.Errorf("reading static files: %w", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "static", e.Name()), data, 0644); err != nil {
+			return fmt.Errorf("writing %s: %w", e.Name(), err)
+		}
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(exportSiteCmd)
+}
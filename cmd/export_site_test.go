@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aure/syntrack/internal/db"
+)
+
+func TestExportSite_StaticPages(t *testing.T) {
+	database, err := db.New(filepath.Join(t.TempDir(), "usage.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	renews := time.Now().Add(24 * time.Hour)
+	for _, used := range []int{10, 20, 30} {
+		if err := database.InsertSnapshot(135, used, &renews); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dir := t.TempDir()
+	// Templates are read relative to the repository root
+	t.Chdir("..")
+	if err := exportSite(database, dir, time.Now()); err != nil {
+		t.Fatalf("exportSite: %v", err)
+	}
+
+	for _, page := range append(exportPages, "static/style.css") {
+		if _, err := os.Stat(filepath.Join(dir, page)); err != nil {
+			t.Fatalf("missing %s: %v", page, err)
+		}
+	}
+	for _, page := range exportPages {
+		data, _ := os.ReadFile(filepath.Join(dir, page))
+		html := string(data)
+		for _, banned := range []string{"hx-get", "htmx.org", "<script", "Loading...", `href="/`} {
+			if strings.Contains(html, banned) {
+				t.Errorf("%s contains %q", page, banned)
+			}
+		}
+	}
+	index, _ := os.ReadFile(filepath.Join(dir, "index.html"))
+	if !strings.Contains(string(index), `<span class="value used">30</span>`) {
+		t.Error("index.html lacks the rendered status")
+	}
+}
@@ -0,0 +1,247 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/aure/syntrack/internal/config"
+	"github.com/aure/syntrack/internal/db"
+	"github.com/aure/syntrack/internal/proxy"
+	"github.com/spf13/cobra"
+)
+
+// guardSnapshotTTL is how long the guard reuses the latest snapshot
+// before reading it again.
+const guardSnapshotTTL = 10 * time.Second
+
+var guardListen string
+var guardUpstream string
+
+var guardCmd = &cobra.Command{
+	Use:   "guard",
+	Short: "Refuse non-critical API requests when the quota is nearly gone",
+	Long: `Run a gatekeeper in front of the Synthetic API that refuses requests with
+429 Too Many Requests and a JSON explanation when forwarding them would
+eat into the reserve. Use 'syntrack proxy --guard' to meter requests too.
+
+The leftover is that of the latest snapshot, less the requests the guard
+let through since. Keep 'syntrack collect' running. Clients are named as
+by 'syntrack proxy'. The priority comes from the label of the API key
+(proxy.labels); the self-declared X-Syntrack-Client header can only lower
+it, so a job cannot make itself critical:
+
+  critical  never refused
+  normal    refused once the leftover reaches guard.reserve
+  low       refused once the leftover reaches guard.low_reserve
+
+Requests are let through when there is no snapshot or the quota renewed
+since the latest one.
+
+  proxy:
+    labels:
+      "3f2a9c0b1d4e": deploy-bot
+  guard:
+    listen: 127.0.0.1:8091
+    reserve: 100
+    low_reserve: 500
+    default_priority: normal
+    priorities:
+      deploy-bot: critical
+      nightly-eval: low
+
+Examples:
+  syntrack guard
+  syntrack proxy --guard`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		upstream, err := url.Parse(guardUpstream)
+		if err != nil || upstream.Host == "" {
+			return fmt.Errorf("invalid upstream URL %q", guardUpstream)
+		}
+
+		database, err := db.New(dbPath)
+		if err != nil {
+			return fmt.Errorf("opening database: %w", err)
+		}
+		defer database.Close()
+
+		guard := newQuotaGuard(database, cfg.Guard, cfg.Proxy.Labels, proxy.New(upstream))
+		slog.Info("guard listening", "addr", guardListen, "upstream", upstream.String(), "reserve", guard.reserve, "low_reserve", guard.lowReserve)
+		return listenUntilSignal(guardListen, guard)
+	},
+}
+
+// quotaGuard forwards requests to next unless they would eat into the
+// reserve of the client's priority.
+type quotaGuard struct {
+	database        *db.DB
+	next            http.Handler
+	labels          map[string]string
+	reserve         int
+	lowReserve      int
+	priorities      map[string]string
+	defaultPriority string
+	now             func() time.Time
+
+	mu sync.Mutex
+	// latest is the snapshot read at checked; forwarded counts the
+	// requests let through since it was collected.
+	latest    *db.UsageSnapshot
+	checked   time.Time
+	forwarded int
+}
+
+func newQuotaGuard(database *db.DB, c config.GuardConfig, labels map[string]string, next http.Handler) *quotaGuard {
+	return &quotaGuard{
+		database:        database,
+		next:            next,
+		labels:          labels,
+		reserve:         c.Reserve,
+		lowReserve:      max(c.LowReserve, c.Reserve),
+		priorities:      c.Priorities,
+		defaultPriority: c.DefaultPriority,
+		now:             time.Now,
+	}
+}
+
+// GuardRejection is the body of a refused request. Error follows the
+// OpenAI error format so API clients show the message.
+type GuardRejection struct {
+	Error struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+		Code    string `json:"code"`
+	} `json:"error"`
+	Client   string        `json:"client"`
+	Priority string        `json:"priority"`
+	Budget   BudgetVerdict `json:"budget"`
+}
+
+func (g *quotaGuard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	client := proxy.ClientName(r, g.labels)
+	priority := g.priority(r)
+
+	v, allowed := g.admit(priority)
+	if !allowed {
+		var rej GuardRejection
+		rej.Error.Message = fmt.Sprintf("syntrack guard refused %s priority client %q to keep the quota reserve: %s", priority, client, v.Reason)
+		rej.Error.Type = "insufficient_quota"
+		rej.Error.Code = "quota_reserve"
+		rej.Client = client
+		rej.Priority = priority
+		rej.Budget = v
+		slog.Warn("request refused", "client", client, "priority", priority, "leftover", v.Leftover, "reserve", v.Reserve, "path", r.URL.Path)
+
+		// Retrying only helps if the request fits after renewal
+		if fit, err := time.Parse(time.RFC3339, v.EarliestFit); err == nil {
+			w.Header().Set("Retry-After", strconv.Itoa(max(int(math.Ceil(fit.Sub(g.now()).Seconds())), 1)))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		json.NewEncoder(w).Encode(rej)
+		return
+	}
+	g.next.ServeHTTP(w, r)
+}
+
+// priorityRank orders priorities from least to most protected.
+var priorityRank = map[string]int{config.PriorityLow: 0, config.PriorityNormal: 1, config.PriorityCritical: 2}
+
+// priority returns the priority of the label of r's API key, or the
+// default. A priority for the name in proxy.ClientHeader applies only if
+// it is lower: anyone can send the header.
+func (g *quotaGuard) priority(r *http.Request) string {
+	p := g.defaultPriority
+	if label := proxy.KeyLabel(r, g.labels); label != "" {
+		if keyPriority, ok := g.priorities[strings.ToLower(label)]; ok {
+			p = keyPriority
+		}
+	}
+	if name := strings.TrimSpace(r.Header.Get(proxy.ClientHeader)); name != "" {
+		if declared, ok := g.priorities[strings.ToLower(name)]; ok && priorityRank[declared] < priorityRank[p] {
+			p = declared
+		}
+	}
+	return p
+}
+
+// admit decides whether a request of the given priority may go through
+// and, if so, counts it against the leftover.
+func (g *quotaGuard) admit(priority string) (BudgetVerdict, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	if priority == config.PriorityCritical {
+		g.forwarded++
+		return BudgetVerdict{}, true
+	}
+	if now.Sub(g.checked) >= guardSnapshotTTL {
+		latest, err := g.database.GetLatestSnapshot()
+		if err != nil {
+			// Keep the previous snapshot rather than blocking everyone
+			slog.Error("guard: getting latest snapshot", "err", err)
+		} else {
+			if latest == nil || g.latest == nil || !latest.CollectedAt.Equal(g.latest.CollectedAt) {
+				g.forwarded = 0
+			}
+			g.latest = latest
+			g.checked = now
+		}
+	}
+
+	reserve := g.reserve
+	if priority == config.PriorityLow {
+		reserve = g.lowReserve
+	}
+	var estimate *db.UsageSnapshot
+	if g.latest != nil {
+		s := *g.latest
+		s.Leftover -= g.forwarded
+		estimate = &s
+	}
+	v := evaluateBudget(estimate, 0, 1, reserve, now)
+	if v.Verdict == budgetInsufficient {
+		return v, false
+	}
+	g.forwarded++
+	return v, true
+}
+
+// listenUntilSignal serves handler on addr until interrupted.
+func listenUntilSignal(addr string, handler http.Handler) error {
+	server := &http.Server{Addr: addr, Handler: handler}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("listening on %s: %w", addr, err)
+	}
+	return nil
+}
+
+func init() {
+	guardCmd.Flags().StringVar(&guardListen, "listen", "127.0.0.1:8091", "Address to listen on")
+	guardCmd.Flags().StringVar(&guardUpstream, "upstream", "https://api.synthetic.new", "API to forward requests to")
+	bindConfigFlag("guard.listen", guardCmd.Flags(), "listen")
+	bindConfigFlag("guard.upstream", guardCmd.Flags(), "upstream")
+	rootCmd.AddCommand(guardCmd)
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aure/syntrack/internal/config"
+	"github.com/aure/syntrack/internal/db"
+	"github.com/aure/syntrack/internal/proxy"
+)
+
+func TestQuotaGuard(t *testing.T) {
+	database, err := db.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	forwarded := 0
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { forwarded++ })
+	labels := map[string]string{proxy.KeyID("sk-deploy"): "deploy-bot", proxy.KeyID("sk-shared"): "ci"}
+	g := newQuotaGuard(database, config.GuardConfig{
+		Reserve:         3,
+		LowReserve:      5,
+		DefaultPriority: config.PriorityNormal,
+		Priorities:      map[string]string{"deploy-bot": config.PriorityCritical, "nightly-eval": config.PriorityLow},
+	}, labels, next)
+
+	do := func(key, client string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/openai/v1/chat/completions", nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		if client != "" {
+			req.Header.Set(proxy.ClientHeader, client)
+		}
+		rec := httptest.NewRecorder()
+		g.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Without data nothing is refused
+	if rec := do("sk-shared", "alice"); rec.Code != http.StatusOK || forwarded != 1 {
+		t.Fatalf("without data: status %d, forwarded %d", rec.Code, forwarded)
+	}
+
+	renews := time.Now().Add(2 * time.Hour)
+	if err := database.InsertSnapshot(100, 95, &renews); err != nil {
+		t.Fatal(err)
+	}
+	g.checked = time.Time{}
+	forwarded = 0
+
+	// The header may lower the priority of a shared key
+	rec := do("sk-shared", "Nightly-Eval")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("low priority at leftover 5: status %d, want 429", rec.Code)
+	}
+	var rej GuardRejection
+	if err := json.Unmarshal(rec.Body.Bytes(), &rej); err != nil {
+		t.Fatal(err)
+	}
+	if rej.Priority != config.PriorityLow || rej.Budget.Reserve != 5 || rej.Budget.Leftover != 5 || rej.Error.Code != "quota_reserve" {
+		t.Fatalf("rejection = %+v", rej)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Fatal("missing Retry-After")
+	}
+
+	// Normal clients get the 2 requests above the reserve of 3
+	for i := range 2 {
+		if rec := do("sk-shared", "alice"); rec.Code != http.StatusOK {
+			t.Fatalf("normal request %d: status %d", i+1, rec.Code)
+		}
+	}
+	if rec := do("sk-other", ""); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("normal request 3: status %d, want 429", rec.Code)
+	}
+
+	// Claiming to be a critical client must not get past the reserve
+	rec = do("sk-shared", "deploy-bot")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("spoofed critical client: status %d, want 429", rec.Code)
+	}
+	json.Unmarshal(rec.Body.Bytes(), &rej)
+	if rej.Client != "deploy-bot" || rej.Priority != config.PriorityNormal {
+		t.Fatalf("spoofed rejection = %+v", rej)
+	}
+
+	// The key's priority protects it, unless the header lowers it
+	if rec := do("sk-deploy", ""); rec.Code != http.StatusOK {
+		t.Fatalf("critical request: status %d", rec.Code)
+	}
+	if rec := do("sk-deploy", "nightly-eval"); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("critical key declared low: status %d, want 429", rec.Code)
+	}
+	if forwarded != 3 {
+		t.Fatalf("forwarded %d requests, want 3", forwarded)
+	}
+}
+
+func TestQuotaGuard_Priority(t *testing.T) {
+	labels := map[string]string{proxy.KeyID("sk-deploy"): "Deploy-Bot", proxy.KeyID("sk-shared"): "ci"}
+	g := newQuotaGuard(nil, config.GuardConfig{
+		DefaultPriority: config.PriorityNormal,
+		Priorities:      map[string]string{"deploy-bot": config.PriorityCritical, "nightly-eval": config.PriorityLow},
+	}, labels, nil)
+
+	tests := []struct {
+		name   string
+		key    string
+		client string
+		want   string
+	}{
+		{"labelled critical key", "sk-deploy", "", config.PriorityCritical},
+		{"labelled key without priority", "sk-shared", "", config.PriorityNormal},
+		{"unknown key", "sk-other", "", config.PriorityNormal},
+		{"no key", "", "", config.PriorityNormal},
+		{"spoofed critical header", "sk-shared", "deploy-bot", config.PriorityNormal},
+		{"spoofed critical header without key", "", "Deploy-Bot", config.PriorityNormal},
+		{"header lowers shared key", "sk-shared", "nightly-eval", config.PriorityLow},
+		{"header lowers critical key", "sk-deploy", "nightly-eval", config.PriorityLow},
+		{"unknown header keeps key priority", "sk-deploy", "alice", config.PriorityCritical},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/openai/v1/chat/completions", nil)
+			if tt.key != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.key)
+			}
+			if tt.client != "" {
+				req.Header.Set(proxy.ClientHeader, tt.client)
+			}
+			if got := g.priority(req); got != tt.want {
+				t.Fatalf("priority = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
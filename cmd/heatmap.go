@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aure/syntrack/internal/db"
+	"golang.org/x/term"
+)
+
+var heatmapWeekdays = []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+
+// Heatmap bins consumption by weekday and hour in the local timezone.
+type Heatmap struct {
+	Since time.Time
+	Until time.Time
+	// Cells is indexed by weekday (0 = Monday) and hour.
+	Cells [7][24]float64
+	Max   float64
+	Total float64
+}
+
+func loadHeatmap(database *db.DB, days int) (Heatmap, error) {
+	now := time.Now()
+	since := now.AddDate(0, 0, -days)
+	intervals, err := database.GetIntervals(since)
+	if err != nil {
+		return Heatmap{}, fmt.Errorf("getting intervals: %w", err)
+	}
+	return buildHeatmap(intervals, since, now), nil
+}
+
+// buildHeatmap bins the consumption of intervals by weekday and hour.
+func buildHeatmap(intervals []db.Interval, since, until time.Time) Heatmap {
+	h := Heatmap{Since: since, Until: until}
+	spreadHourly(intervals, h.add)
+	return h
+}
+
+// spreadHourly spreads each interval's consumption evenly over the clock
+// hours it covers, so a gap in collection does not pile up in a single
+// hour. fn is called with the start of each piece in local time.
+func spreadHourly(intervals []db.Interval, fn func(t time.Time, v float64)) {
+	for _, iv := range intervals {
+		if iv.Consumed <= 0 {
+			continue
+		}
+		start, end := iv.Start.Local(), iv.End.Local()
+		span := end.Sub(start)
+		if span <= 0 {
+			fn(end, float64(iv.Consumed))
+			continue
+		}
+		for t := start; t.Before(end); {
+			y, m, d := t.Date()
+			next := time.Date(y, m, d, t.Hour()+1, 0, 0, 0, t.Location())
+			if next.After(end) {
+				next = end
+			}
+			fn(t, float64(iv.Consumed)*float64(next.Sub(t))/float64(span))
+			t = next
+		}
+	}
+}
+
+func (h *Heatmap) add(t time.Time, v float64) {
+	day := (int(t.Weekday()) + 6) % 7
+	h.Cells[day][t.Hour()] += v
+	h.Max = max(h.Max, h.Cells[day][t.Hour()])
+	h.Total += v
+}
+
+// level maps a cell to 0 (nothing) through 4 (busiest).
+func (h *Heatmap) level(v float64) int {
+	if v <= 0 || h.Max <= 0 {
+		return 0
+	}
+	return min(1+int(v/h.Max*4), 4)
+}
+
+var heatmapShades = []string{"··", "░░", "▒▒", "▓▓", "██"}
+
+// ANSI 256-color greens, from empty to busiest.
+var heatmapColors = []int{237, 22, 28, 34, 46}
+
+func printHeatmap(h Heatmap) {
+	color := term.IsTerminal(int(os.Stdout.Fd())) && os.Getenv("NO_COLOR") == ""
+
+	fmt.Println()
+	fmt.Printf("  Requests by hour of week (%s to %s, %s)\n", h.Since.Local().Format("2006-01-02"), h.Until.Local().Format("2006-01-02"), localZoneName())
+	fmt.Println("  " + strings.Repeat("─", 60))
+	fmt.Print("       ")
+	for hour := 0; hour < 24; hour += 3 {
+		fmt.Printf("%02d    ", hour)
+	}
+	fmt.Println(" Total")
+
+	for day, name := range heatmapWeekdays {
+		var b strings.Builder
+		total := 0.0
+		for hour := 0; hour < 24; hour++ {
+			v := h.Cells[day][hour]
+			total += v
+			lvl := h.level(v)
+			if color {
+				fmt.Fprintf(&b, "\033[38;5;%dm██\033[0m", heatmapColors[lvl])
+			} else {
+				b.WriteString(heatmapShades[lvl])
+			}
+		}
+		fmt.Printf("  %s  %s %6.0f\n", name, b.String(), total)
+	}
+
+	fmt.Println()
+	legend := make([]string, len(heatmapShades))
+	for i := range heatmapShades {
+		if color {
+			legend[i] = fmt.Sprintf("\033[38;5;%dm██\033[0m", heatmapColors[i])
+		} else {
+			legend[i] = heatmapShades[i]
+		}
+	}
+	fmt.Printf("  Scale: %s  (busiest hour: %.0f requests, total: %.0f)\n", strings.Join(legend, " "), h.Max, h.Total)
+}
+
+// localZoneName names the timezone used for binning: the configured IANA
+// name, or the system zone's abbreviation.
+func localZoneName() string {
+	if name := time.Local.String(); name != "Local" {
+		return name
+	}
+	name, _ := time.Now().Zone()
+	return name
+}
+
+// generateHeatmapSVG renders the heatmap for the stats page, with the
+// consumption of each cell in its tooltip.
+func generateHeatmapSVG(h Heatmap) string {
+	const (
+		cellW  = 28.0
+		cellH  = 22.0
+		left   = 44.0
+		top    = 24.0
+		width  = left + 24*cellW + 10
+		height = top + 7*cellH + 10
+	)
+
+	var svg strings.Builder
+	svg.WriteString(fmt.Sprintf(`<svg viewBox="0 0 %.0f %.0f" xmlns="http://www.w3.org/2000/svg">`, width, height))
+	svg.WriteString(`<rect width="100%" height="100%" fill="#0f1419"/>`)
+
+	for hour := 0; hour < 24; hour += 3 {
+		x := left + float64(hour)*cellW + cellW/2
+		svg.WriteString(fmt.Sprintf(`<text x="%.0f" y="%.0f" fill="#71767b" font-size="10" text-anchor="middle">%02d</text>`, x, top-8, hour))
+	}
+	for day, name := range heatmapWeekdays {
+		y := top + float64(day)*cellH
+		svg.WriteString(fmt.Sprintf(`<text x="%.0f" y="%.0f" fill="#71767b" font-size="11" text-anchor="end">%s</text>`, left-8, y+cellH/2+4, name))
+		for hour := 0; hour < 24; hour++ {
+			v := h.Cells[day][hour]
+			fill := "#16181c"
+			if v > 0 && h.Max > 0 {
+				// Fade from the background to the leftover green
+				fill = fmt.Sprintf("rgba(0,186,124,%.2f)", 0.15+0.85*v/h.Max)
+			}
+			x := left + float64(hour)*cellW
+			svg.WriteString(fmt.Sprintf(`<rect x="%.0f" y="%.0f" width="%.0f" height="%.0f" rx="3" fill="%s"><title>%s %02d:00: %.1f requests</title></rect>`,
+				x+1, y+1, cellW-2, cellH-2, fill, name, hour, v))
+		}
+	}
+
+	svg.WriteString(`</svg>`)
+	return svg.String()
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aure/syntrack/internal/db"
+)
+
+func TestBuildHeatmap_SpreadsIntervals(t *testing.T) {
+	old := time.Local
+	time.Local = time.UTC
+	defer func() { time.Local = old }()
+
+	// Monday 2025-01-13, 10:30 to 12:30
+	start := time.Date(2025, 1, 13, 10, 30, 0, 0, time.UTC)
+	intervals := []db.Interval{
+		{Start: start, End: start.Add(2 * time.Hour), Consumed: 40},
+		{Start: start.Add(2 * time.Hour), End: start.Add(3 * time.Hour), Consumed: 0},
+	}
+
+	h := buildHeatmap(intervals, start, start.Add(3*time.Hour))
+	want := map[int]float64{10: 10, 11: 20, 12: 10}
+	for hour := 0; hour < 24; hour++ {
+		if got := h.Cells[0][hour]; got != want[hour] {
+			t.Fatalf("Mon %02d:00 = %v, want %v", hour, got, want[hour])
+		}
+	}
+	if h.Total != 40 || h.Max != 20 {
+		t.Fatalf("total %v, max %v; want 40, 20", h.Total, h.Max)
+	}
+}
@@ -34,17 +34,30 @@ var historyCmd = &cobra.Command{
 		}
 
 		if historyChart {
-			printASCIIChart(snapshots)
+			overlays, err := loadChartOverlays(database, since)
+			if err != nil {
+				return err
+			}
+			printASCIIChart(snapshots, overlays)
 			return nil
 		}
 
+		annotations, err := database.GetAnnotations(since)
+		if err != nil {
+			return fmt.Errorf("getting annotations: %w", err)
+		}
+
 		fmt.Printf("Usage History (last %d days)\n", historyDays)
 		fmt.Println("─────────────────────────────────────────────────────────────────")
 		fmt.Printf("%-20s %8s %8s %8s %8s\n", "Time", "Limit", "Used", "Left", "%")
 		fmt.Println("─────────────────────────────────────────────────────────────────")
 
-		for i := len(snapshots) - 1; i >= 0; i-- {
-			s := snapshots[i]
+		for _, row := range historyRows(snapshots, annotations) {
+			if a := row.Annotation; a != nil {
+				fmt.Printf("%-20s ✎ %s\n", a.At.Format("2006-01-02 15:04"), a.Text)
+				continue
+			}
+			s := row.Snapshot
 			pct := float64(s.RequestsUsed) / float64(s.SubscriptionLimit) * 100
 			fmt.Printf("%-20s %8d %8d %8d %7.1f%%\n",
 				s.CollectedAt.Format("2006-01-02 15:04"),
@@ -59,7 +72,31 @@ var historyCmd = &cobra.Command{
 	},
 }
 
-func printASCIIChart(snapshots []db.UsageSnapshot) {
+// historyRow is a line of a history table: a snapshot or an annotation.
+type historyRow struct {
+	Snapshot   *db.UsageSnapshot
+	Annotation *db.Annotation
+}
+
+// historyRows merges snapshots and annotations, both oldest first, into
+// table rows newest first. An annotation comes before the snapshots
+// collected at or before its time.
+func historyRows(snapshots []db.UsageSnapshot, annotations []db.Annotation) []historyRow {
+	rows := make([]historyRow, 0, len(snapshots)+len(annotations))
+	i, j := len(snapshots)-1, len(annotations)-1
+	for i >= 0 || j >= 0 {
+		if j >= 0 && (i < 0 || !annotations[j].At.Before(snapshots[i].CollectedAt)) {
+			rows = append(rows, historyRow{Annotation: &annotations[j]})
+			j--
+			continue
+		}
+		rows = append(rows, historyRow{Snapshot: &snapshots[i]})
+		i--
+	}
+	return rows
+}
+
+func printASCIIChart(snapshots []db.UsageSnapshot, overlays chartOverlays) {
 	if len(snapshots) < 2 {
 		fmt.Println("Need at least 2 data points for a chart")
 		return
@@ -110,6 +147,34 @@ func printASCIIChart(snapshots []db.UsageSnapshot) {
 		}
 	}
 
+	// The pace line only fills cells not taken by the data
+	hasPace := false
+	for x := 0; x < width; x++ {
+		t := snapshotTimeAt(snapshots, float64(x)/float64(width-1)*float64(len(snapshots)-1))
+		ideal, ok := overlays.paceValue(t)
+		if !ok {
+			continue
+		}
+		y := int((1 - ideal/maxVal) * float64(height-1))
+		y = max(0, min(y, height-1))
+		if grid[y][x] == ' ' {
+			grid[y][x] = '-'
+		}
+		hasPace = true
+	}
+
+	hasAnomalies := false
+	for _, a := range overlays.Anomalies {
+		i := snapshotIndex(snapshots, a.IntervalEnd)
+		if i < 0 {
+			continue
+		}
+		x := int(float64(i) / float64(len(snapshots)-1) * float64(width-1))
+		y := int((1 - float64(snapshots[i].RequestsUsed)/maxVal) * float64(height-1))
+		grid[max(0, min(y, height-1))][min(x, width-1)] = '!'
+		hasAnomalies = true
+	}
+
 	fmt.Println()
 	fmt.Printf("     Usage Chart (last %d data points)\n", len(snapshots))
 	fmt.Println("     " + strings.Repeat("─", width))
@@ -126,9 +191,25 @@ func printASCIIChart(snapshots []db.UsageSnapshot) {
 		}
 		fmt.Println()
 	}
-	fmt.Printf("     └" + strings.Repeat("─", width))
+	fmt.Print("     └" + strings.Repeat("─", width))
 	fmt.Println()
 
+	// Annotations are numbered in a row under the axis
+	var annotated []db.Annotation
+	markers := []rune(strings.Repeat(" ", width))
+	for _, a := range overlays.Annotations {
+		pos, ok := snapshotPosition(snapshots, a.At)
+		if !ok {
+			continue
+		}
+		annotated = append(annotated, a)
+		x := int(pos / float64(len(snapshots)-1) * float64(width-1))
+		markers[min(x, width-1)] = annotationMarker(len(annotated))
+	}
+	if len(annotated) > 0 {
+		fmt.Println("      " + string(markers))
+	}
+
 	labels := 5
 	step := len(snapshots) / labels
 	if step < 1 {
@@ -146,12 +227,34 @@ func printASCIIChart(snapshots []db.UsageSnapshot) {
 	fmt.Println()
 
 	fmt.Println()
-	fmt.Println("Legend: # = Used  . = Leftover")
+	legend := "Legend: # = Used  . = Leftover"
+	if hasPace {
+		legend += "  - = Pace"
+	}
+	if hasAnomalies {
+		legend += "  ! = Anomaly"
+	}
+	fmt.Println(legend)
+	for i, a := range annotated {
+		fmt.Printf("  %c %s  %s\n", annotationMarker(i+1), a.At.Local().Format("2006-01-02 15:04"), a.Text)
+	}
 	fmt.Printf("Data range: %s to %s\n",
 		snapshots[0].CollectedAt.Format("2006-01-02 15:04"),
 		snapshots[len(snapshots)-1].CollectedAt.Format("2006-01-02 15:04"))
 }
 
+// annotationMarker labels the nth annotation on the ASCII chart: 1-9,
+// then letters.
+func annotationMarker(n int) rune {
+	if n <= 9 {
+		return rune('0' + n)
+	}
+	if n <= 9+26 {
+		return rune('a' + n - 10)
+	}
+	return '*'
+}
+
 func init() {
 	historyCmd.Flags().IntVarP(&historyDays, "days", "d", 7, "Number of days to show")
 	historyCmd.Flags().BoolVarP(&historyChart, "chart", "c", false, "Show ASCII chart instead of table")
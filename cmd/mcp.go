@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime/debug"
+	"syscall"
+
+	"github.com/aure/syntrack/internal/db"
+	"github.com/aure/syntrack/internal/mcp"
+	"github.com/spf13/cobra"
+)
+
+// mcpMaxHistoryDays bounds get_history, whose result goes into the
+// model's context.
+const mcpMaxHistoryDays = 31
+
+var mcpCmd = &cobra.Command{
+	Use:   "mcp",
+	Short: "Serve usage tools to coding agents over MCP (stdio)",
+	Long: `Speak the Model Context Protocol on stdin/stdout so coding agents can
+check the quota themselves and decide whether to throttle. The tools
+return the same JSON as 'syntrack query':
+
+  get_current_quota   current status            (query current)
+  get_burn_rate       burn rate and predictions (query burn-rate)
+  get_pace            pace against renewal      (query pace)
+  get_history         recent snapshots          (query history --days N)
+  check_budget        whether N requests fit    (budget check --need N)
+
+Register it with the agent, e.g. in an MCP client config:
+
+  {"mcpServers": {"syntrack": {"command": "syntrack", "args": ["mcp"]}}}
+
+Logs go to stderr or the configured log file.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		database, err := db.New(dbPath)
+		if err != nil {
+			return fmt.Errorf("opening database: %w", err)
+		}
+		defer database.Close()
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		server := &mcp.Server{Name: "syntrack", Version: buildVersion(), Tools: mcpTools(database)}
+		return server.Serve(ctx, os.Stdin, os.Stdout)
+	},
+}
+
+func mcpTools(database *db.DB) []mcp.Tool {
+	noArgs := json.RawMessage(`{"type": "object", "properties": {}}`)
+	return []mcp.Tool{
+		{
+			Name:        "get_current_quota",
+			Description: "Current subscription quota: limit, requests used, leftover and when it renews.",
+			InputSchema: noArgs,
+			Call: func(ctx context.Context, args json.RawMessage) (any, error) {
+				return queryCurrent(database)
+			},
+		},
+		{
+			Name:        "get_burn_rate",
+			Description: "Requests per hour and per day over the last 24 hours, and when the quota runs out at that rate.",
+			InputSchema: noArgs,
+			Call: func(ctx context.Context, args json.RawMessage) (any, error) {
+				return queryBurnRate(database)
+			},
+		},
+		{
+			Name:        "get_pace",
+			Description: "Consumption compared with an even spread until renewal, and how many requests are left today to stay on pace.",
+			InputSchema: noArgs,
+			Call: func(ctx context.Context, args json.RawMessage) (any, error) {
+				return queryPace(database)
+			},
+		},
+		{
+			Name:        "get_history",
+			Description: "Quota snapshots (used, leftover, limit), oldest first, for the last days.",
+			InputSchema: json.RawMessage(fmt.Sprintf(`{
+				"type": "object",
+				"properties": {"days": {"type": "integer", "minimum": 1, "maximum": %d, "default": 1, "description": "Number of days to return"}}
+			}`, mcpMaxHistoryDays)),
+			Call: func(ctx context.Context, args json.RawMessage) (any, error) {
+				params := struct {
+					Days int `json:"days"`
+				}{Days: 1}
+				if err := json.Unmarshal(args, &params); err != nil {
+					return nil, fmt.Errorf("invalid arguments: %w", err)
+				}
+				if params.Days < 1 || params.Days > mcpMaxHistoryDays {
+					return nil, fmt.Errorf("days must be between 1 and %d", mcpMaxHistoryDays)
+				}
+				return queryHistory(database, params.Days)
+			},
+		},
+		{
+			Name:        "check_budget",
+			Description: "Whether a job needing some requests fits in the remaining quota before renewal, keeping a reserve. The verdict is ok, risky (fits but the expected usage would exhaust the quota), insufficient or unknown.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"need": {"type": "integer", "minimum": 1, "description": "Requests the job needs"},
+					"reserve": {"type": "integer", "minimum": 0, "default": 0, "description": "Requests to keep untouched"}
+				},
+				"required": ["need"]
+			}`),
+			Call: func(ctx context.Context, args json.RawMessage) (any, error) {
+				var params struct {
+					Need    int `json:"need"`
+					Reserve int `json:"reserve"`
+				}
+				if err := json.Unmarshal(args, &params); err != nil {
+					return nil, fmt.Errorf("invalid arguments: %w", err)
+				}
+				if params.Need <= 0 {
+					return nil, fmt.Errorf("need must be positive")
+				}
+				if params.Reserve < 0 {
+					return nil, fmt.Errorf("reserve must not be negative")
+				}
+				return loadBudgetVerdict(database, params.Need, params.Reserve)
+			},
+		},
+	}
+}
+
+// buildVersion returns the module version syntrack was built from.
+func buildVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "dev"
+}
+
+func init() {
+	rootCmd.AddCommand(mcpCmd)
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aure/syntrack/internal/db"
+)
+
+// Pace compares consumption in the current cycle with spending the quota
+// evenly up to the renewal date.
+type Pace struct {
+	// Start and StartUsed anchor the pace line. When the cycle start is
+	// unknown, the line starts at the cycle's first snapshot instead of
+	// at zero.
+	Start      time.Time
+	StartUsed  int
+	StartKnown bool
+	RenewsAt   time.Time
+	Limit      int
+	Used       int
+	// At is when Used was collected.
+	At time.Time
+}
+
+// Tolerance around a pace ratio of 1 that still counts as on pace.
+const paceTolerance = 0.05
+
+// loadPace returns the pace of the current cycle, or nil if no renewal
+// time has been recorded yet.
+func loadPace(database *db.DB) (*Pace, error) {
+	latest, err := database.GetLatestSnapshot()
+	if err != nil {
+		return nil, fmt.Errorf("getting latest snapshot: %w", err)
+	}
+	if latest == nil {
+		return nil, nil
+	}
+	cycle, err := database.GetCurrentCycle()
+	if err != nil {
+		return nil, fmt.Errorf("getting current cycle: %w", err)
+	}
+	if cycle == nil {
+		return nil, nil
+	}
+
+	p := &Pace{
+		Start:      cycle.Start,
+		StartKnown: cycle.StartKnown,
+		RenewsAt:   cycle.RenewsAt,
+		Limit:      latest.SubscriptionLimit,
+		Used:       latest.RequestsUsed,
+		At:         latest.CollectedAt,
+	}
+	if !cycle.StartKnown {
+		p.StartUsed = cycle.FirstUsed
+	}
+	return p, nil
+}
+
+// IdealUsed returns how many requests should be used by t to reach the
+// limit exactly at renewal.
+func (p *Pace) IdealUsed(t time.Time) float64 {
+	span := p.RenewsAt.Sub(p.Start)
+	if span <= 0 {
+		return float64(p.Limit)
+	}
+	f := float64(t.Sub(p.Start)) / float64(span)
+	f = max(0, min(f, 1))
+	return float64(p.StartUsed) + float64(p.Limit-p.StartUsed)*f
+}
+
+// Ratio is consumption since the start of the line relative to the pace
+// line. Above 1 the quota runs out before renewal at the current rate.
+func (p *Pace) Ratio() float64 {
+	expected := p.IdealUsed(p.At) - float64(p.StartUsed)
+	if expected <= 0 {
+		return 0
+	}
+	return float64(p.Used-p.StartUsed) / expected
+}
+
+// Status describes Ratio as over pace, under pace or on pace.
+func (p *Pace) Status() string {
+	r := p.Ratio()
+	switch {
+	case r > 1+paceTolerance:
+		return "over pace"
+	case r < 1-paceTolerance:
+		return "under pace"
+	default:
+		return "on pace"
+	}
+}
+
+// TodayAllowance is how many more requests can be used until the end of
+// today, or renewal if sooner, without going over the pace line.
+func (p *Pace) TodayAllowance(now time.Time) int {
+	y, m, d := now.Date()
+	end := time.Date(y, m, d+1, 0, 0, 0, 0, now.Location())
+	if end.After(p.RenewsAt) {
+		end = p.RenewsAt
+	}
+	return max(0, int(p.IdealUsed(end))-p.Used)
+}
+
+// DailyAllowance spreads the remaining requests evenly over the days left
+// until renewal.
+func (p *Pace) DailyAllowance(now time.Time) float64 {
+	left := float64(max(0, p.Limit-p.Used))
+	days := p.RenewsAt.Sub(now).Hours() / 24
+	return left / max(days, 1)
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPace(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	p := &Pace{
+		Start:      start,
+		StartKnown: true,
+		RenewsAt:   start.Add(10 * 24 * time.Hour),
+		Limit:      1000,
+		Used:       600,
+		At:         start.Add(5 * 24 * time.Hour),
+	}
+
+	if got := p.IdealUsed(p.At); got != 500 {
+		t.Fatalf("IdealUsed = %v, want 500", got)
+	}
+	if got := p.Ratio(); got != 1.2 {
+		t.Fatalf("Ratio = %v, want 1.2", got)
+	}
+	if got := p.Status(); got != "over pace" {
+		t.Fatalf("Status = %q, want over pace", got)
+	}
+	// End of day 6 is 600 on the line: nothing left for today
+	if got := p.TodayAllowance(p.At.Add(12 * time.Hour)); got != 0 {
+		t.Fatalf("TodayAllowance = %d, want 0", got)
+	}
+
+	p.Used = 400
+	if got := p.TodayAllowance(p.At.Add(12 * time.Hour)); got != 200 {
+		t.Fatalf("TodayAllowance = %d, want 200", got)
+	}
+	if got := p.DailyAllowance(p.At); got != 120 {
+		t.Fatalf("DailyAllowance = %v, want 120", got)
+	}
+}
+
+func TestPace_UnknownStart(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	p := &Pace{
+		Start:     start,
+		StartUsed: 200,
+		RenewsAt:  start.Add(4 * time.Hour),
+		Limit:     1000,
+		Used:      400,
+		At:        start.Add(time.Hour),
+	}
+
+	// The line runs from 200 at the first snapshot to 1000 at renewal
+	if got := p.IdealUsed(p.At); got != 400 {
+		t.Fatalf("IdealUsed = %v, want 400", got)
+	}
+	if got := p.Status(); got != "on pace" {
+		t.Fatalf("Status = %q, want on pace", got)
+	}
+	if got := p.IdealUsed(start.Add(-time.Hour)); got != 200 {
+		t.Fatalf("IdealUsed before start = %v, want 200", got)
+	}
+}
@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/aure/syntrack/internal/db"
+	"github.com/aure/syntrack/internal/proxy"
+	"github.com/spf13/cobra"
+)
+
+var proxyListen string
+var proxyUpstream string
+var proxyGuard bool
+
+var proxyCmd = &cobra.Command{
+	Use:   "proxy",
+	Short: "Meter API requests per client",
+	Long: `Run a reverse proxy in front of the Synthetic API. Point tools at it
+instead of https://api.synthetic.new; requests and responses pass
+through unchanged, streaming included.
+
+Each request is recorded in the proxy_requests table with its client,
+path, model, status and latency, and broken down by client and model in
+'stats', 'query proxy' and the dashboard.
+
+A client is named by the X-Syntrack-Client header if it sends one
+(the header is not forwarded), else by the label configured for its API
+key, else by the key ID. API keys themselves are never stored:
+
+  proxy:
+    listen: 127.0.0.1:8090
+    upstream: https://api.synthetic.new
+    labels:
+      "3f2a9c0b1d4e": alice      # from 'syntrack proxy key-id <key>'
+
+Examples:
+  syntrack proxy
+  syntrack proxy --listen 0.0.0.0:8090
+  syntrack proxy --guard      # Also refuse requests as 'syntrack guard' does
+  OPENAI_BASE_URL=http://127.0.0.1:8090/openai/v1 my-agent`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		upstream, err := url.Parse(proxyUpstream)
+		if err != nil || upstream.Host == "" {
+			return fmt.Errorf("invalid upstream URL %q", proxyUpstream)
+		}
+
+		database, err := db.New(dbPath)
+		if err != nil {
+			return fmt.Errorf("opening database: %w", err)
+		}
+		defer database.Close()
+
+		p := proxy.New(upstream)
+		p.Labels = cfg.Proxy.Labels
+		p.Record = func(r proxy.Request) { recordProxyRequest(database, r) }
+
+		var handler http.Handler = p
+		if proxyGuard {
+			// Refused requests are not forwarded, so they are not recorded
+			handler = newQuotaGuard(database, cfg.Guard, cfg.Proxy.Labels, p)
+		}
+		slog.Info("proxy listening", "addr", proxyListen, "upstream", upstream.String(), "guard", proxyGuard)
+		return listenUntilSignal(proxyListen, handler)
+	},
+}
+
+var proxyKeyIDCmd = &cobra.Command{
+	Use:   "key-id [api-key]",
+	Short: "Print the key ID the proxy records for an API key",
+	Long: `Print the key ID of an API key, or of the configured one, for use in
+proxy.labels.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key := apiKey
+		if len(args) == 1 {
+			key = args[0]
+		}
+		if key == "" {
+			return fmt.Errorf("no API key given or configured")
+		}
+		fmt.Println(proxy.KeyID(key))
+		return nil
+	},
+}
+
+func recordProxyRequest(database *db.DB, r proxy.Request) {
+	err := database.InsertProxyRequest(db.ProxyRequest{
+		At:        r.At,
+		Client:    r.Client,
+		KeyID:     r.KeyID,
+		Method:    r.Method,
+		Path:      r.Path,
+		Model:     r.Model,
+		Status:    r.Status,
+		LatencyMs: r.Latency.Milliseconds(),
+	})
+	if err != nil {
+		slog.Error("recording proxy request", "err", err)
+	}
+	slog.Debug("proxied", "client", r.Client, "method", r.Method, "path", r.Path, "model", r.Model, "status", r.Status, "duration", r.Latency)
+}
+
+// proxyUsageDays is the window of the proxy breakdowns in stats and the
+// dashboard.
+const proxyUsageDays = 7
+
+type ProxyUsageEntry struct {
+	Name         string  `json:"name"`
+	Requests     int     `json:"requests"`
+	Errors       int     `json:"errors"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	SharePercent float64 `json:"share_percent"`
+}
+
+type ProxyBreakdown struct {
+	Since    string            `json:"since"`
+	Requests int               `json:"requests"`
+	Clients  []ProxyUsageEntry `json:"clients"`
+	Models   []ProxyUsageEntry `json:"models"`
+}
+
+// loadProxyBreakdown groups the requests proxied in the last days by
+// client and by model.
+func loadProxyBreakdown(database *db.DB, days int) (ProxyBreakdown, error) {
+	since := time.Now().AddDate(0, 0, -days)
+	b := ProxyBreakdown{Since: since.Format(time.RFC3339)}
+	clients, err := database.GetProxyUsage(since, "client")
+	if err != nil {
+		return b, fmt.Errorf("getting proxy usage: %w", err)
+	}
+	models, err := database.GetProxyUsage(since, "model")
+	if err != nil {
+		return b, fmt.Errorf("getting proxy usage: %w", err)
+	}
+	for _, c := range clients {
+		b.Requests += c.Requests
+	}
+	b.Clients = proxyUsageEntries(clients, b.Requests)
+	b.Models = proxyUsageEntries(models, b.Requests)
+	return b, nil
+}
+
+func proxyUsageEntries(usage []db.ProxyUsage, total int) []ProxyUsageEntry {
+	entries := make([]ProxyUsageEntry, len(usage))
+	for i, u := range usage {
+		name := u.Name
+		if name == "" {
+			name = "(none)"
+		}
+		entries[i] = ProxyUsageEntry{
+			Name:         name,
+			Requests:     u.Requests,
+			Errors:       u.Errors,
+			AvgLatencyMs: math.Round(u.AvgLatencyMs),
+			SharePercent: math.Round(float64(u.Requests)/float64(total)*1000) / 10,
+		}
+	}
+	return entries
+}
+
+func getProxyUsageData(database *db.DB) (any, error) {
+	return loadProxyBreakdown(database, proxyUsageDays)
+}
+
+func init() {
+	proxyCmd.Flags().StringVar(&proxyListen, "listen", "127.0.0.1:8090", "Address to listen on")
+	proxyCmd.Flags().StringVar(&proxyUpstream, "upstream", "https://api.synthetic.new", "API to forward requests to")
+	proxyCmd.Flags().BoolVar(&proxyGuard, "guard", false, "Refuse non-critical requests when the quota reserve is reached (see 'syntrack guard')")
+	bindConfigFlag("proxy.listen", proxyCmd.Flags(), "listen")
+	bindConfigFlag("proxy.upstream", proxyCmd.Flags(), "upstream")
+	proxyCmd.AddCommand(proxyKeyIDCmd)
+	rootCmd.AddCommand(proxyCmd)
+}
@@ -22,9 +22,12 @@ Types:
   yesterday  - Yesterday's usage summary
   week       - This week's usage
   burn-rate  - Current burn rate and predictions
+  pace       - Consumption against an even spread until renewal
   history    - Recent snapshots (use --days flag)
   daily      - Daily breakdown (use --days flag)
   weekly     - Weekly breakdown (use --weeks flag)
+  heatmap    - Consumption by weekday and hour (use --days flag)
+  proxy      - Requests through 'syntrack proxy' by client and model (use --days flag)
 
 Examples:
   syntrack query current
@@ -52,14 +55,20 @@ Examples:
 			result, err = queryWeek(database)
 		case "burn-rate":
 			result, err = queryBurnRate(database)
+		case "pace":
+			result, err = queryPace(database)
 		case "history":
 			result, err = queryHistory(database, historyDays)
 		case "daily":
 			result, err = queryDaily(database, historyDays)
 		case "weekly":
 			result, err = queryWeekly(database, 4)
+		case "heatmap":
+			result, err = queryHeatmap(database, historyDays)
+		case "proxy":
+			result, err = loadProxyBreakdown(database, historyDays)
 		default:
-			return fmt.Errorf("unknown query type: %s (valid: current, today, yesterday, week, burn-rate, history, daily, weekly)", queryType)
+			return fmt.Errorf("unknown query type: %s (valid: current, today, yesterday, week, burn-rate, pace, history, daily, weekly, heatmap, proxy)", queryType)
 		}
 
 		if err != nil {
@@ -94,7 +103,10 @@ func queryCurrent(database *db.DB) (any, error) {
 	if snapshot == nil {
 		return CurrentStatus{Timestamp: time.Now().Format(time.RFC3339)}, nil
 	}
+	return newCurrentStatus(snapshot), nil
+}
 
+func newCurrentStatus(snapshot *db.UsageSnapshot) CurrentStatus {
 	status := CurrentStatus{
 		Timestamp:    snapshot.CollectedAt.Format(time.RFC3339),
 		Limit:        snapshot.SubscriptionLimit,
@@ -109,7 +121,7 @@ func queryCurrent(database *db.DB) (any, error) {
 		status.TimeUntilRenew = time.Until(*snapshot.RenewsAt).Round(time.Minute).String()
 	}
 
-	return status, nil
+	return status
 }
 
 type DaySummary struct {
@@ -264,6 +276,82 @@ func queryBurnRate(database *db.DB) (any, error) {
 	return result, nil
 }
 
+type PaceResult struct {
+	CalculatedAt    string  `json:"calculated_at"`
+	Status          string  `json:"status"`
+	CycleStart      string  `json:"cycle_start,omitempty"`
+	CycleStartKnown bool    `json:"cycle_start_known"`
+	RenewsAt        string  `json:"renews_at,omitempty"`
+	Limit           int     `json:"limit"`
+	Used            int     `json:"used"`
+	IdealUsed       float64 `json:"ideal_used"`
+	PaceRatio       float64 `json:"pace_ratio"`
+	TodayAllowance  int     `json:"today_allowance"`
+	DailyAllowance  float64 `json:"daily_allowance"`
+}
+
+func queryPace(database *db.DB) (any, error) {
+	now := time.Now()
+	pace, err := loadPace(database)
+	if err != nil {
+		return nil, err
+	}
+	if pace == nil {
+		return PaceResult{CalculatedAt: now.Format(time.RFC3339), Status: "unknown"}, nil
+	}
+
+	return PaceResult{
+		CalculatedAt:    now.Format(time.RFC3339),
+		Status:          pace.Status(),
+		CycleStart:      pace.Start.Format(time.RFC3339),
+		CycleStartKnown: pace.StartKnown,
+		RenewsAt:        pace.RenewsAt.Format(time.RFC3339),
+		Limit:           pace.Limit,
+		Used:            pace.Used,
+		IdealUsed:       pace.IdealUsed(pace.At),
+		PaceRatio:       pace.Ratio(),
+		TodayAllowance:  pace.TodayAllowance(now),
+		DailyAllowance:  pace.DailyAllowance(now),
+	}, nil
+}
+
+type HeatmapCell struct {
+	Weekday  string  `json:"weekday"`
+	Hour     int     `json:"hour"`
+	Consumed float64 `json:"consumed"`
+}
+
+type HeatmapResult struct {
+	Since    string        `json:"since"`
+	Until    string        `json:"until"`
+	Timezone string        `json:"timezone"`
+	Total    float64       `json:"total"`
+	Max      float64       `json:"max"`
+	Cells    []HeatmapCell `json:"cells"`
+}
+
+func queryHeatmap(database *db.DB, days int) (any, error) {
+	h, err := loadHeatmap(database, days)
+	if err != nil {
+		return nil, err
+	}
+
+	result := HeatmapResult{
+		Since:    h.Since.Format(time.RFC3339),
+		Until:    h.Until.Format(time.RFC3339),
+		Timezone: localZoneName(),
+		Total:    h.Total,
+		Max:      h.Max,
+		Cells:    make([]HeatmapCell, 0, 7*24),
+	}
+	for day, name := range heatmapWeekdays {
+		for hour := 0; hour < 24; hour++ {
+			result.Cells = append(result.Cells, HeatmapCell{Weekday: name, Hour: hour, Consumed: h.Cells[day][hour]})
+		}
+	}
+	return result, nil
+}
+
 func queryHistory(database *db.DB, days int) (any, error) {
 	since := time.Now().AddDate(0, 0, -days)
 	snapshots, err := database.GetSnapshots(since)
@@ -0,0 +1,400 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aure/syntrack/internal/db"
+	"github.com/spf13/cobra"
+)
+
+var reportPeriod string
+var reportFormat string
+var reportOutput string
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Generate a usage report for sharing",
+	Long: `Generate a self-contained usage report with totals, a daily table, the
+burn rate, a forecast until renewal and a usage chart.
+
+Periods:
+  week   the last 7 days
+  month  the last 30 days
+  cycle  the current quota cycle
+
+Formats:
+  markdown  for wikis; the chart is embedded as a data URI image
+  html      a standalone page with the chart inline, e.g. for email
+  text      plain text without a chart
+
+Examples:
+  syntrack report
+  syntrack report --period cycle --format html -o report.html
+  syntrack report --period month --format text`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var write func(io.Writer, *report) error
+		switch reportFormat {
+		case "markdown", "md":
+			write = writeReportMarkdown
+		case "html":
+			write = writeReportHTML
+		case "text":
+			write = writeReportText
+		default:
+			return fmt.Errorf("unknown format %q (valid: markdown, html, text)", reportFormat)
+		}
+
+		database, err := db.New(dbPath)
+		if err != nil {
+			return fmt.Errorf("opening database: %w", err)
+		}
+		defer database.Close()
+
+		r, err := loadReport(database, reportPeriod, time.Now())
+		if err != nil {
+			return err
+		}
+
+		var buf bytes.Buffer
+		if err := write(&buf, r); err != nil {
+			return fmt.Errorf("rendering report: %w", err)
+		}
+		if reportOutput == "" || reportOutput == "-" {
+			_, err = os.Stdout.Write(buf.Bytes())
+			return err
+		}
+		if err := os.WriteFile(reportOutput, buf.Bytes(), 0o644); err != nil {
+			return fmt.Errorf("writing report: %w", err)
+		}
+		fmt.Printf("Report written to %s\n", reportOutput)
+		return nil
+	},
+}
+
+// report holds everything shown in a usage report.
+type report struct {
+	Title     string
+	From      time.Time
+	To        time.Time
+	Generated time.Time
+	Latest    *db.UsageSnapshot
+	Snapshots int
+	Consumed  float64
+	Days      []reportDay
+	// BurnRate is the 24h rate used for the forecast; AvgRate is the
+	// average over the whole period.
+	BurnRate  float64
+	AvgRate   float64
+	Pace      *Pace
+	Anomalies int
+	// SVG is the usage chart of the period.
+	SVG string
+}
+
+type reportDay struct {
+	Day      time.Time
+	Consumed float64
+	// Leftover is the leftover of the day's last snapshot, or -1 if none
+	// was collected that day.
+	Leftover int
+}
+
+func loadReport(database *db.DB, period string, now time.Time) (*report, error) {
+	r := &report{To: now, Generated: now}
+	switch period {
+	case "week":
+		r.Title, r.From = "Weekly usage report", now.AddDate(0, 0, -7)
+	case "month":
+		r.Title, r.From = "Monthly usage report", now.AddDate(0, 0, -30)
+	case "cycle":
+		cycle, err := database.GetCurrentCycle()
+		if err != nil {
+			return nil, fmt.Errorf("getting current cycle: %w", err)
+		}
+		if cycle == nil {
+			return nil, fmt.Errorf("no renewal time recorded yet; use --period week or month")
+		}
+		r.Title, r.From = "Quota cycle report", cycle.Start
+	default:
+		return nil, fmt.Errorf("unknown period %q (valid: week, month, cycle)", period)
+	}
+
+	var err error
+	if r.Latest, err = database.GetLatestSnapshot(); err != nil {
+		return nil, fmt.Errorf("getting latest snapshot: %w", err)
+	}
+	if r.BurnRate, err = database.GetBurnRate(24); err != nil {
+		return nil, fmt.Errorf("calculating burn rate: %w", err)
+	}
+	snapshots, err := database.GetSnapshots(r.From)
+	if err != nil {
+		return nil, fmt.Errorf("getting snapshots: %w", err)
+	}
+	// Start a day early to include the interval crossing From
+	intervals, err := database.GetIntervals(r.From.Add(-24 * time.Hour))
+	if err != nil {
+		return nil, fmt.Errorf("getting intervals: %w", err)
+	}
+	overlays, err := loadChartOverlays(database, r.From)
+	if err != nil {
+		return nil, err
+	}
+
+	r.Snapshots = len(snapshots)
+	r.Pace = overlays.Pace
+	r.Anomalies = len(overlays.Anomalies)
+	r.Days = reportDays(intervals, snapshots, r.From, r.To)
+	for _, d := range r.Days {
+		r.Consumed += d.Consumed
+	}
+	if hours := r.To.Sub(r.From).Hours(); hours > 0 {
+		r.AvgRate = r.Consumed / hours
+	}
+	if len(snapshots) >= 2 {
+		r.SVG = generateSVGChart(snapshots, overlays)
+	}
+	return r, nil
+}
+
+// reportDays splits the consumption between from and to by local day,
+// with the leftover at the end of each day.
+func reportDays(intervals []db.Interval, snapshots []db.UsageSnapshot, from, to time.Time) []reportDay {
+	from, to = from.Local(), to.Local()
+	var days []reportDay
+	index := map[string]int{}
+	for y, m, d := from.Date(); ; d++ {
+		day := time.Date(y, m, d, 0, 0, 0, 0, from.Location())
+		if !day.Before(to) {
+			break
+		}
+		index[day.Format("2006-01-02")] = len(days)
+		days = append(days, reportDay{Day: day, Leftover: -1})
+	}
+
+	spreadHourly(intervals, func(t time.Time, v float64) {
+		if t.Before(from) || !t.Before(to) {
+			return
+		}
+		if i, ok := index[t.Format("2006-01-02")]; ok {
+			days[i].Consumed += v
+		}
+	})
+	for _, s := range snapshots {
+		if i, ok := index[s.CollectedAt.Local().Format("2006-01-02")]; ok {
+			days[i].Leftover = s.Leftover
+		}
+	}
+	return days
+}
+
+// Busiest returns the day with the most consumption, or nil if nothing
+// was consumed.
+func (r *report) Busiest() *reportDay {
+	var busiest *reportDay
+	for i := range r.Days {
+		if r.Days[i].Consumed > 0 && (busiest == nil || r.Days[i].Consumed > busiest.Consumed) {
+			busiest = &r.Days[i]
+		}
+	}
+	return busiest
+}
+
+// Forecast describes when the quota runs out at the 24h burn rate,
+// relative to the renewal.
+func (r *report) Forecast() string {
+	if r.Latest == nil {
+		return "No data collected yet."
+	}
+	left := r.Latest.Leftover
+	if r.BurnRate <= 0 {
+		return fmt.Sprintf("Nothing was consumed in the last 24 hours; the %d requests left are not expected to run out.", left)
+	}
+	toEmpty := time.Duration(float64(left) / r.BurnRate * float64(time.Hour))
+	if r.Latest.RenewsAt == nil {
+		return fmt.Sprintf("At %.1f requests/hour, the %d requests left last %s.", r.BurnRate, left, formatDurationShort(toEmpty))
+	}
+
+	renewsAt := *r.Latest.RenewsAt
+	toRenewal := renewsAt.Sub(r.Generated)
+	if toEmpty < toRenewal {
+		return fmt.Sprintf("At %.1f requests/hour, the %d requests left run out in %s (%s), %s before the quota renews.",
+			r.BurnRate, left, formatDurationShort(toEmpty), r.Generated.Add(toEmpty).Local().Format("2006-01-02 15:04"), formatDurationShort(toRenewal-toEmpty))
+	}
+	projected := float64(r.Latest.RequestsUsed) + r.BurnRate*toRenewal.Hours()
+	return fmt.Sprintf("At %.1f requests/hour, about %.0f of %d requests will be used when the quota renews on %s.",
+		r.BurnRate, min(projected, float64(r.Latest.SubscriptionLimit)), r.Latest.SubscriptionLimit, renewsAt.Local().Format("2006-01-02 15:04"))
+}
+
+func (r *report) periodLabel() string {
+	return fmt.Sprintf("%s to %s", r.From.Local().Format("2006-01-02 15:04"), r.To.Local().Format("2006-01-02 15:04"))
+}
+
+func (d reportDay) leftoverLabel() string {
+	if d.Leftover < 0 {
+		return "-"
+	}
+	return fmt.Sprint(d.Leftover)
+}
+
+// reportSummary lists the totals shown by every format as label/value
+// pairs.
+func (r *report) reportSummary() [][2]string {
+	rows := [][2]string{
+		{"Consumed", fmt.Sprintf("%.0f requests", r.Consumed)},
+		{"Average", fmt.Sprintf("%.1f requests/day, %.2f/hour", r.AvgRate*24, r.AvgRate)},
+	}
+	if b := r.Busiest(); b != nil {
+		rows = append(rows, [2]string{"Busiest day", fmt.Sprintf("%s (%.0f requests)", b.Day.Format("Mon 2006-01-02"), b.Consumed)})
+	}
+	if r.Latest != nil {
+		pct := 0.0
+		if r.Latest.SubscriptionLimit > 0 {
+			pct = float64(r.Latest.RequestsUsed) / float64(r.Latest.SubscriptionLimit) * 100
+		}
+		rows = append(rows, [2]string{"Current usage", fmt.Sprintf("%d / %d (%.1f%%), %d left", r.Latest.RequestsUsed, r.Latest.SubscriptionLimit, pct, r.Latest.Leftover)})
+		if r.Latest.RenewsAt != nil {
+			rows = append(rows, [2]string{"Renews", r.Latest.RenewsAt.Local().Format("2006-01-02 15:04")})
+		}
+	}
+	rows = append(rows, [2]string{"Burn rate (24h)", fmt.Sprintf("%.2f requests/hour", r.BurnRate)})
+	if r.Pace != nil {
+		rows = append(rows, [2]string{"Pace", fmt.Sprintf("%s (%.2fx)", r.Pace.Status(), r.Pace.Ratio())})
+	}
+	rows = append(rows,
+		[2]string{"Anomalies", fmt.Sprint(r.Anomalies)},
+		[2]string{"Snapshots", fmt.Sprint(r.Snapshots)},
+	)
+	return rows
+}
+
+func writeReportText(w io.Writer, r *report) error {
+	fmt.Fprintln(w, strings.ToUpper(r.Title))
+	fmt.Fprintln(w, r.periodLabel())
+	fmt.Fprintln(w, strings.Repeat("═", 50))
+
+	fmt.Fprintln(w)
+	for _, row := range r.reportSummary() {
+		fmt.Fprintf(w, "%-17s %s\n", row[0]+":", row[1])
+	}
+
+	fmt.Fprintln(w, "\nForecast")
+	fmt.Fprintln(w, strings.Repeat("─", 50))
+	fmt.Fprintln(w, r.Forecast())
+
+	fmt.Fprintln(w, "\nDaily usage")
+	fmt.Fprintln(w, strings.Repeat("─", 50))
+	fmt.Fprintf(w, "%-16s %10s %10s\n", "Day", "Consumed", "Left")
+	for _, d := range r.Days {
+		fmt.Fprintf(w, "%-16s %10.0f %10s\n", d.Day.Format("Mon 2006-01-02"), d.Consumed, d.leftoverLabel())
+	}
+
+	fmt.Fprintf(w, "\nGenerated %s by syntrack\n", r.Generated.Local().Format("2006-01-02 15:04 MST"))
+	return nil
+}
+
+func writeReportMarkdown(w io.Writer, r *report) error {
+	fmt.Fprintf(w, "# %s\n\n", r.Title)
+	fmt.Fprintf(w, "%s\n\n", r.periodLabel())
+
+	fmt.Fprintln(w, "## Summary")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "| | |")
+	fmt.Fprintln(w, "|---|---|")
+	for _, row := range r.reportSummary() {
+		fmt.Fprintf(w, "| %s | %s |\n", row[0], row[1])
+	}
+
+	fmt.Fprintf(w, "\n## Forecast\n\n%s\n", r.Forecast())
+
+	if r.SVG != "" {
+		fmt.Fprintf(w, "\n## Usage\n\n![Usage chart](data:image/svg+xml;base64,%s)\n", base64.StdEncoding.EncodeToString([]byte(r.SVG)))
+	}
+
+	fmt.Fprintln(w, "\n## Daily usage")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "| Day | Consumed | Left |")
+	fmt.Fprintln(w, "|---|---:|---:|")
+	for _, d := range r.Days {
+		fmt.Fprintf(w, "| %s | %.0f | %s |\n", d.Day.Format("Mon 2006-01-02"), d.Consumed, d.leftoverLabel())
+	}
+
+	fmt.Fprintf(w, "\n_Generated %s by syntrack._\n", r.Generated.Local().Format("2006-01-02 15:04 MST"))
+	return nil
+}
+
+var reportHTMLTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', sans-serif; background: #0f1419; color: #e7e9ea; max-width: 860px; margin: 2rem auto; padding: 0 1rem; }
+h1 { font-size: 1.4rem; margin-bottom: 0.25rem; }
+h2 { font-size: 1.1rem; color: #71767b; margin-top: 2rem; }
+table { border-collapse: collapse; width: 100%; }
+th, td { padding: 0.5rem 0.75rem; border-bottom: 1px solid #2f3336; text-align: left; }
+th { color: #71767b; font-weight: 500; }
+td.num { text-align: right; }
+.muted { color: #71767b; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="muted">{{.Period}}</p>
+
+<h2>Summary</h2>
+<table>
+{{- range .Summary}}
+<tr><th>{{index . 0}}</th><td>{{index . 1}}</td></tr>
+{{- end}}
+</table>
+
+<h2>Forecast</h2>
+<p>{{.Forecast}}</p>
+{{if .SVG}}
+<h2>Usage</h2>
+{{.SVG}}
+{{end}}
+<h2>Daily usage</h2>
+<table>
+<tr><th>Day</th><th>Consumed</th><th>Left</th></tr>
+{{- range .Days}}
+<tr><td>{{.Day}}</td><td class="num">{{.Consumed}}</td><td class="num">{{.Left}}</td></tr>
+{{- end}}
+</table>
+
+<p class="muted">Generated {{.Generated}} by syntrack.</p>
+</body>
+</html>
+`))
+
+func writeReportHTML(w io.Writer, r *report) error {
+	type dayRow struct{ Day, Consumed, Left string }
+	days := make([]dayRow, len(r.Days))
+	for i, d := range r.Days {
+		days[i] = dayRow{d.Day.Format("Mon 2006-01-02"), fmt.Sprintf("%.0f", d.Consumed), d.leftoverLabel()}
+	}
+	return reportHTMLTemplate.Execute(w, map[string]any{
+		"Title":     r.Title,
+		"Period":    r.periodLabel(),
+		"Summary":   r.reportSummary(),
+		"Forecast":  r.Forecast(),
+		"SVG":       template.HTML(r.SVG),
+		"Days":      days,
+		"Generated": r.Generated.Local().Format("2006-01-02 15:04 MST"),
+	})
+}
+
+func init() {
+	reportCmd.Flags().StringVarP(&reportPeriod, "period", "p", "week", "Period to cover: week, month, cycle")
+	reportCmd.Flags().StringVarP(&reportFormat, "format", "f", "markdown", "Output format: markdown, html, text")
+	reportCmd.Flags().StringVarP(&reportOutput, "output", "o", "", "Write the report to this file instead of stdout")
+	rootCmd.AddCommand(reportCmd)
+}
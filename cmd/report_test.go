@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aure/syntrack/internal/db"
+)
+
+func TestReportDays(t *testing.T) {
+	old := time.Local
+	time.Local = time.UTC
+	defer func() { time.Local = old }()
+
+	from := time.Date(2025, 1, 13, 12, 0, 0, 0, time.UTC)
+	to := from.Add(48 * time.Hour)
+	// 23:00 to 01:00 spans midnight
+	midnight := time.Date(2025, 1, 14, 0, 0, 0, 0, time.UTC)
+	intervals := []db.Interval{
+		{Start: from.Add(-2 * time.Hour), End: from, Consumed: 50},
+		{Start: midnight.Add(-time.Hour), End: midnight.Add(time.Hour), Consumed: 20},
+	}
+	snapshots := []db.UsageSnapshot{
+		{CollectedAt: midnight.Add(-time.Hour), Leftover: 90},
+		{CollectedAt: midnight.Add(time.Hour), Leftover: 70},
+	}
+
+	days := reportDays(intervals, snapshots, from, to)
+	if len(days) != 3 {
+		t.Fatalf("got %d days, want 3", len(days))
+	}
+	want := []reportDay{
+		{Day: from.Add(-12 * time.Hour), Consumed: 10, Leftover: 90},
+		{Day: midnight, Consumed: 10, Leftover: 70},
+		{Day: midnight.AddDate(0, 0, 1), Consumed: 0, Leftover: -1},
+	}
+	for i, d := range days {
+		if !d.Day.Equal(want[i].Day) || d.Consumed != want[i].Consumed || d.Leftover != want[i].Leftover {
+			t.Errorf("day %d = %+v, want %+v", i, d, want[i])
+		}
+	}
+}
+
+func TestReportForecast(t *testing.T) {
+	now := time.Date(2025, 1, 13, 12, 0, 0, 0, time.UTC)
+	renewsAt := now.Add(10 * time.Hour)
+	latest := &db.UsageSnapshot{SubscriptionLimit: 100, RequestsUsed: 60, Leftover: 40, RenewsAt: &renewsAt}
+
+	r := &report{Generated: now, Latest: latest, BurnRate: 8}
+	if got := r.Forecast(); !strings.Contains(got, "run out in 5h00m") {
+		t.Errorf("fast burn: %q", got)
+	}
+	r.BurnRate = 2
+	if got := r.Forecast(); !strings.Contains(got, "about 80 of 100") {
+		t.Errorf("slow burn: %q", got)
+	}
+	r.BurnRate = 0
+	if got := r.Forecast(); !strings.Contains(got, "not expected to run out") {
+		t.Errorf("no burn: %q", got)
+	}
+}
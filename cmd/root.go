@@ -1,61 +1,196 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"time"
 
-	"github.com/joho/godotenv"
+	"github.com/aure/syntrack/internal/config"
+	"github.com/aure/syntrack/internal/logging"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
 var cfgFile string
+
+// cfg is the merged configuration, loaded before any command runs. It is
+// not changed afterwards; config reloads build a separate value.
+var cfg *config.Config
+
+// configFlags maps config keys to the flags bound to them.
+var configFlags = make(map[string]*pflag.Flag)
+
+var timezone string
 var apiKey string
 var dbPath string
+var logLevel string
+var logFormat string
+var logFile string
+var logMaxSize int
+var logMaxBackups int
+
+// logCloser releases the log file opened by initConfig.
+var logCloser io.Closer
 
 var rootCmd = &cobra.Command{
 	Use:   "syntrack",
 	Short: "Synthetic usage tracker for API monitoring",
 	Long:  `Syntrack tracks and monitors synthetic API usage across your infrastructure.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return initConfig(cmd)
+	},
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.Execute()
+	code := 0
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		code = exitErr.code
+		err = exitErr.err
+	} else if err != nil {
+		code = 1
+	}
+	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		// Unattended runs (cron) only look at the log file
+		if logFile != "" {
+			slog.Error("command failed", "err", err)
+		}
+	}
+	if logCloser != nil {
+		logCloser.Close()
+	}
+	if code != 0 {
+		os.Exit(code)
+	}
+}
+
+// exitError ends the process with a specific status. Commands whose exit
+// status is their result (checks for schedulers and monitoring) return it
+// after printing their output. A non-nil err is printed like any other
+// command error.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	if e.err != nil {
+		return e.err.Error()
+	}
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// exitWithCode returns an exitError for cmd, keeping cobra from printing
+// it as a failure or showing usage.
+func exitWithCode(cmd *cobra.Command, code int, err error) error {
+	if code == 0 && err == nil {
+		return nil
 	}
+	cmd.SilenceErrors = true
+	cmd.SilenceUsage = true
+	return &exitError{code: code, err: err}
 }
 
 func init() {
-	cobra.OnInitialize(initConfig)
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.syntrack.yaml)")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "Log level: debug, info, warn or error")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "Log format: text or json")
+	rootCmd.PersistentFlags().StringVar(&logFile, "log-file", "", "Write logs to this file instead of stderr, rotating it by size")
+	rootCmd.PersistentFlags().IntVar(&logMaxSize, "log-max-size", 10, "Rotate the log file after this many megabytes")
+	rootCmd.PersistentFlags().IntVar(&logMaxBackups, "log-max-backups", 5, "Number of rotated log files to keep")
+	rootCmd.PersistentFlags().StringVar(&timezone, "timezone", "", "Timezone for displayed times and day boundaries (IANA name, default system)")
+
+	flags := rootCmd.PersistentFlags()
+	bindConfigFlag("log.level", flags, "log-level")
+	bindConfigFlag("log.format", flags, "log-format")
+	bindConfigFlag("log.file", flags, "log-file")
+	bindConfigFlag("log.max_size", flags, "log-max-size")
+	bindConfigFlag("log.max_backups", flags, "log-max-backups")
+	bindConfigFlag("timezone", flags, "timezone")
 }
 
-func initConfig() {
-	if cfgFile != "" {
-		viper.SetConfigFile(cfgFile)
-	} else {
-		home, err := os.UserHomeDir()
-		cobra.CheckErr(err)
-		viper.AddConfigPath(home)
-		viper.AddConfigPath(".")
-		viper.SetConfigType("yaml")
-		viper.SetConfigName(".syntrack")
+// initConfig loads the configuration and applies it to the flag variables
+// the commands read. The config commands report problems themselves, so
+// for them an invalid log or timezone setting falls back to the default.
+func initConfig(cmd *cobra.Command) error {
+	c, err := config.Load(cfgFile)
+	if err != nil {
+		return err
 	}
+	cfg = c
+	lenient := cmd.Parent() == configCmd
 
-	viper.SetEnvPrefix("syntrack")
-	viper.AutomaticEnv()
+	apiKey = cfg.APIKey
+	dbPath = cfg.DBPath
+	logLevel = cfg.Log.Level
+	logFormat = cfg.Log.Format
+	logFile = cfg.Log.File
+	logMaxSize = cfg.Log.MaxSize
+	logMaxBackups = cfg.Log.MaxBackups
+	collectTimeout = cfg.Collect.Timeout
+	proxyListen = cfg.Proxy.Listen
+	proxyUpstream = cfg.Proxy.Upstream
+	guardListen = cfg.Guard.Listen
+	guardUpstream = cfg.Guard.Upstream
+	applyServeConfig(cfg.Serve)
 
-	godotenv.Load()
+	closer, err := logging.Setup(logging.Options{
+		Level:      logLevel,
+		Format:     logFormat,
+		File:       logFile,
+		MaxSizeMB:  logMaxSize,
+		MaxBackups: logMaxBackups,
+	})
+	if err != nil {
+		if !lenient {
+			return err
+		}
+		closer, _ = logging.Setup(logging.Options{})
+	}
+	logCloser = closer
 
-	viper.SetDefault("database_path", "usage.db")
-	viper.BindEnv("api_key", "SYNTHETIC_API_KEY")
-	viper.BindEnv("database_path", "DATABASE_PATH")
+	loc, err := cfg.Location()
+	if err != nil && !lenient {
+		return err
+	}
+	if loc != nil {
+		time.Local = loc
+	}
+
+	if cfg.File != "" {
+		slog.Debug("using config file", "path", cfg.File)
+	}
+	return nil
+}
 
-	if err := viper.ReadInConfig(); err == nil {
-		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
+// bindConfigFlag lets a flag override the config key when it is set on the
+// command line.
+func bindConfigFlag(key string, flags *pflag.FlagSet, name string) {
+	flag := flags.Lookup(name)
+	if err := viper.BindPFlag(key, flag); err != nil {
+		panic(err)
 	}
+	configFlags[key] = flag
+}
 
-	apiKey = viper.GetString("api_key")
-	dbPath = viper.GetString("database_path")
+// reloadConfig reads the configuration again into a fresh viper instance
+// with the same flag bindings, leaving the global one and cfg untouched.
+func reloadConfig() (*config.Config, error) {
+	v := viper.New()
+	for key, flag := range configFlags {
+		if err := v.BindPFlag(key, flag); err != nil {
+			return nil, err
+		}
+	}
+	return config.LoadFrom(v, cfgFile)
 }
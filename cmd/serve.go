@@ -1,27 +1,48 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
 	"fmt"
 	"html/template"
+	"io"
+	"log/slog"
 	"net"
 	"net/http"
+	"net/netip"
+	"net/url"
 	"os"
 	"os/exec"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/aure/syntrack/internal/config"
 	"github.com/aure/syntrack/internal/db"
+	"github.com/aure/syntrack/internal/tokens"
 	"github.com/spf13/cobra"
 )
 
 var servePort int
 var requireAuth bool
-var authTokens []string
+
+// authTokens is the active token set. Reloads replace it atomically, so a
+// request always checks against one complete set.
+var authTokens atomic.Pointer[tokens.Store]
 var bindAll bool
 var useTailscale bool
 var tailscaleIP string
 var serveSilent bool
+var trustedProxies []string
+var noLocalhostBypass bool
+var refreshInterval time.Duration
+
+// trustedProxyNets is parsed from --trusted-proxy when the server starts.
+var trustedProxyNets []netip.Prefix
 
 func detectTailscaleIP() string {
 	interfaces, err := net.Interfaces()
@@ -54,47 +75,202 @@ func detectTailscaleIP() string {
 
 func tokenAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Skip auth if not required or no tokens configured
-		if !requireAuth || len(authTokens) == 0 {
+		// Login, logout and static assets are reachable without a token
+		if isPublicPath(r.URL.Path) {
 			next.ServeHTTP(w, r)
 			return
 		}
 
-		// Allow localhost requests without token
-		host := r.Host
-		if strings.HasPrefix(host, "localhost:") || strings.HasPrefix(host, "127.0.0.1:") || strings.HasPrefix(host, "[::1]:") {
+		sess, hasSession := sessionFromRequest(r)
+
+		// Skip auth if not required or no tokens configured. Allow localhost
+		// requests without token; this is decided from the connection's
+		// address, never from the client-controlled Host header.
+		store := authTokens.Load()
+		if !requireAuth || store == nil || (!noLocalhostBypass && isLocalRequest(r)) {
+			if !checkCSRF(r, sess) {
+				http.Error(w, "Forbidden: invalid CSRF token", http.StatusForbidden)
+				return
+			}
 			next.ServeHTTP(w, r)
 			return
 		}
 
-		// Check X-Auth-Token header or token query parameter
-		token := r.Header.Get("X-Auth-Token")
-		if token == "" {
-			token = r.URL.Query().Get("token")
-			if token == "" {
-				http.Error(w, "Unauthorized: X-Auth-Token header or token query parameter required", http.StatusUnauthorized)
+		// API clients authenticate with the X-Auth-Token header
+		if token := r.Header.Get("X-Auth-Token"); token != "" {
+			// Validate token (constant-time, expired tokens are rejected)
+			if _, ok := store.Verify(token); !ok {
+				logAuthFailure(r, "invalid token")
+				http.Error(w, "Unauthorized: Invalid token", http.StatusUnauthorized)
 				return
 			}
+			next.ServeHTTP(w, r)
+			return
 		}
 
-		// Validate token
-		valid := false
-		for _, t := range authTokens {
-			if t == token {
-				valid = true
-				break
+		// The dashboard authenticates with a session cookie from /login
+		if hasSession {
+			if !checkCSRF(r, sess) {
+				logAuthFailure(r, "invalid CSRF token")
+				http.Error(w, "Forbidden: invalid CSRF token", http.StatusForbidden)
+				return
 			}
+			next.ServeHTTP(w, r)
+			return
 		}
 
-		if !valid {
-			http.Error(w, "Unauthorized: Invalid token", http.StatusUnauthorized)
+		// Send browsers navigating to a page to the login form
+		if r.Method == http.MethodGet && r.Header.Get("HX-Request") == "" && strings.Contains(r.Header.Get("Accept"), "text/html") {
+			http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
 			return
 		}
 
-		next.ServeHTTP(w, r)
+		logAuthFailure(r, "missing credentials")
+		http.Error(w, "Unauthorized: X-Auth-Token header or session login required", http.StatusUnauthorized)
 	})
 }
 
+func logAuthFailure(r *http.Request, reason string) {
+	slog.Warn("authentication failed", "reason", reason, "client", clientIP(r), "method", r.Method, "path", r.URL.Path)
+}
+
+// statusRecorder captures the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+	return n, err
+}
+
+// accessLog logs every request at info level; static assets and htmx
+// partial refreshes are logged at debug level to keep the log readable.
+func accessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		level := slog.LevelInfo
+		if strings.HasPrefix(r.URL.Path, "/static/") || strings.HasPrefix(r.URL.Path, "/partials/") {
+			level = slog.LevelDebug
+		}
+		slog.Log(r.Context(), level, "request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.status,
+			"bytes", rec.bytes,
+			"duration", time.Since(start),
+			"client", clientIP(r))
+	})
+}
+
+func isPublicPath(path string) bool {
+	return path == "/login" || path == "/logout" || strings.HasPrefix(path, "/static/")
+}
+
+// parseTrustedProxies accepts IP addresses and CIDR ranges.
+func parseTrustedProxies(values []string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if strings.Contains(v, "/") {
+			prefix, err := netip.ParsePrefix(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", v, err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", v, err)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+func isTrustedProxy(addr netip.Addr) bool {
+	for _, prefix := range trustedProxyNets {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func remoteAddr(r *http.Request) netip.Addr {
+	addrPort, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addrPort.Addr().Unmap()
+}
+
+// clientIP returns the address of the client that made the request. The
+// connection's peer address is used unless it is a trusted proxy, in which
+// case X-Forwarded-For is walked from the right, skipping further trusted
+// proxies, to find the first address not under our control.
+func clientIP(r *http.Request) netip.Addr {
+	addr := remoteAddr(r)
+	if !addr.IsValid() || !isTrustedProxy(addr) {
+		return addr
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			// A malformed entry means nothing to its left can be trusted.
+			return addr
+		}
+		hop = hop.Unmap()
+		if !isTrustedProxy(hop) {
+			return hop
+		}
+		addr = hop
+	}
+	return addr
+}
+
+// isLocalRequest reports whether the request originates on this machine.
+// A loopback peer that forwards X-Forwarded-For without being a trusted
+// proxy (e.g. an unconfigured local reverse proxy) is not treated as local,
+// since the real client is somewhere else.
+func isLocalRequest(r *http.Request) bool {
+	peer := remoteAddr(r)
+	if !peer.IsValid() {
+		return false
+	}
+	if r.Header.Get("X-Forwarded-For") != "" && !isTrustedProxy(peer) {
+		return false
+	}
+	return clientIP(r).IsLoopback()
+}
+
 var serveCmd = &cobra.Command{
 	Use:   "serve",
 	Short: "Start the web dashboard server",
@@ -104,19 +280,23 @@ var serveCmd = &cobra.Command{
 			return startServerInBackground()
 		}
 
-		// Load config to get auth tokens
-		cfg, err := config.Load()
+		proxies, err := parseTrustedProxies(trustedProxies)
 		if err != nil {
-			return fmt.Errorf("loading config: %w", err)
+			return err
 		}
+		trustedProxyNets = proxies
 
 		// Load auth tokens if auth is required
 		if requireAuth || bindAll || useTailscale {
-			authTokens = cfg.AuthTokens
-			if len(authTokens) == 0 {
+			store, err := cfg.LoadAuthTokens()
+			if err != nil {
+				return fmt.Errorf("loading auth tokens: %w", err)
+			}
+			if store.Len() == 0 {
 				return fmt.Errorf("external access requires authentication; set SYNTRACK_AUTH_TOKENS or use 'syntrack token generate --save'")
 			}
 			requireAuth = true // Force auth when binding externally
+			authTokens.Store(store)
 		}
 
 		var bindHost string
@@ -127,10 +307,10 @@ var serveCmd = &cobra.Command{
 			if bindHost == "" {
 				detected := detectTailscaleIP()
 				if detected == "" {
-					fmt.Println("Warning: Tailscale interface not detected. Use --tailscale-ip to specify manually.")
+					slog.Warn("Tailscale interface not detected; use --tailscale-ip to specify it manually")
 					bindHost = "127.0.0.1"
 				} else {
-					fmt.Printf("Detected Tailscale IP: %s\n", detected)
+					slog.Info("detected Tailscale IP", "ip", detected)
 					bindHost = detected
 				}
 			}
@@ -149,77 +329,115 @@ var serveCmd = &cobra.Command{
 
 		partials := template.Must(template.New("").ParseGlob("web/templates/partials/*.html"))
 
-		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-			tmpl, err := partials.Clone()
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			tmpl, err = tmpl.ParseFiles("web/templates/layout.html", "web/templates/index.html")
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			w.Header().Set("Content-Type", "text/html")
-			if err := tmpl.ExecuteTemplate(w, "layout.html", nil); err != nil {
-				fmt.Printf("Template error: %v\n", err)
-			}
-		})
-		mux.HandleFunc("/history", func(w http.ResponseWriter, r *http.Request) {
-			tmpl, err := partials.Clone()
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			tmpl, err = tmpl.ParseFiles("web/templates/layout.html", "web/templates/history.html")
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			w.Header().Set("Content-Type", "text/html")
-			if err := tmpl.ExecuteTemplate(w, "layout.html", nil); err != nil {
-				fmt.Printf("Template error: %v\n", err)
-			}
-		})
-		mux.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
-			tmpl, err := partials.Clone()
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			tmpl, err = tmpl.ParseFiles("web/templates/layout.html", "web/templates/stats.html")
+		render := makePageRenderer()
+		mux.HandleFunc("/", makePageHandler(render, "index.html"))
+		mux.HandleFunc("/history", makePageHandler(render, "history.html"))
+		mux.HandleFunc("/stats", makePageHandler(render, "stats.html"))
+		mux.HandleFunc("/compare", handleComparePage(render))
+		mux.HandleFunc("/login", makeLoginHandler(render))
+		mux.HandleFunc("/logout", handleLogout)
+
+		for _, p := range dashboardPartials {
+			mux.HandleFunc("/partials/"+p.Name, makePartialHandler(database, partials, p.Name+".html", p.Provider))
+		}
+		mux.HandleFunc("/partials/comparison", handleComparisonPartial(database, partials))
+
+		mux.HandleFunc("/api/budget", handleBudgetAPI(database))
+		mux.HandleFunc("/api/annotations", handleAnnotationsAPI(database))
+		mux.HandleFunc("/api/annotations/{id}", handleAnnotationAPI(database))
+
+		// Apply token auth middleware and log every request
+		handler := accessLog(tokenAuth(mux))
+
+		addr := fmt.Sprintf("%s:%d", bindHost, servePort)
+		server := &http.Server{Addr: addr, Handler: handler}
+		scheme := "http"
+		if tlsEnabled() {
+			tlsConfig, err := configureTLS(bindHost)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			w.Header().Set("Content-Type", "text/html")
-			if err := tmpl.ExecuteTemplate(w, "layout.html", nil); err != nil {
-				fmt.Printf("Template error: %v\n", err)
+				return fmt.Errorf("configuring TLS: %w", err)
 			}
-		})
+			server.TLSConfig = tlsConfig
+			scheme = "https"
+		} else if httpRedirectPort != 0 {
+			return fmt.Errorf("--http-port requires TLS (--tls-cert/--tls-key or --tls-self-signed)")
+		}
+
+		slog.Info("starting server", "url", scheme+"://"+addr)
+		if requireAuth && server.TLSConfig == nil && bindHost != "127.0.0.1" {
+			slog.Warn("tokens are sent in cleartext; use --tls-self-signed or --tls-cert/--tls-key")
+		}
+		if requireAuth {
+			slog.Info("authentication enabled", "tokens", authTokens.Load().Len(), "localhost_bypass", !noLocalhostBypass)
+		}
 
-		mux.HandleFunc("/partials/status", makePartialHandler(database, partials, "status.html", getStatusData))
-		mux.HandleFunc("/partials/chart", makePartialHandler(database, partials, "chart.html", getChartData))
-		mux.HandleFunc("/partials/burn-rate", makePartialHandler(database, partials, "burn-rate.html", getBurnRateData))
-		mux.HandleFunc("/partials/history-table", makePartialHandler(database, partials, "history-table.html", getHistoryData))
-		mux.HandleFunc("/partials/daily-stats", makePartialHandler(database, partials, "daily-stats.html", getDailyData))
-		mux.HandleFunc("/partials/weekly-stats", makePartialHandler(database, partials, "weekly-stats.html", getWeeklyData))
-		mux.HandleFunc("/partials/overall-stats", makePartialHandler(database, partials, "overall-stats.html", getOverallData))
+		pidLock, err := writePIDFile()
+		if err != nil {
+			return err
+		}
+		defer removePIDFile(pidLock)
+
+		var redirectServer *http.Server
+		if httpRedirectPort != 0 {
+			redirectAddr := fmt.Sprintf("%s:%d", bindHost, httpRedirectPort)
+			redirectServer = &http.Server{Addr: redirectAddr, Handler: httpsRedirectHandler(servePort)}
+			slog.Info("redirecting plain HTTP to HTTPS", "addr", redirectAddr)
+			go func() {
+				if err := redirectServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+					slog.Error("HTTP redirect listener failed", "err", err)
+				}
+			}()
+		}
 
-		// Apply token auth middleware
-		handler := tokenAuth(mux)
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
 
-		addr := fmt.Sprintf("%s:%d", bindHost, servePort)
-		fmt.Printf("Starting server at http://%s\n", addr)
+		// Pick up token and config changes without a restart
 		if requireAuth {
-			fmt.Printf("Authentication enabled with %d token(s)\n", len(authTokens))
-			fmt.Println("External requests require X-Auth-Token header or token query parameter")
+			go watchAuthConfig(ctx)
+			go flushTokenUsage(ctx)
 		}
-		return http.ListenAndServe(addr, handler)
+
+		serveErr := make(chan error, 1)
+		go func() {
+			if server.TLSConfig != nil {
+				serveErr <- server.ListenAndServeTLS("", "")
+				return
+			}
+			serveErr <- server.ListenAndServe()
+		}()
+
+		select {
+		case err := <-serveErr:
+			return err
+		case <-ctx.Done():
+		}
+
+		// Stop accepting connections and let in-flight requests finish
+		slog.Info("shutting down server")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if redirectServer != nil {
+			redirectServer.Shutdown(shutdownCtx)
+		}
+		err = server.Shutdown(shutdownCtx)
+		flushAuthTokens(authTokens.Load())
+		if err != nil {
+			return fmt.Errorf("shutting down server: %w", err)
+		}
+		database.Close()
+		slog.Info("server stopped")
+		return nil
 	},
 }
 
 func startServerInBackground() error {
+	if pid, running, err := runningServerPID(); err != nil {
+		return err
+	} else if running {
+		return fmt.Errorf("server already running (PID %d); use 'syntrack serve restart' to restart it", pid)
+	}
+
 	fmt.Println("Starting syntrack server in the background...")
 
 	// Get the current executable path
@@ -245,22 +463,81 @@ func startServerInBackground() error {
 	if tailscaleIP != "" {
 		args = append(args, "--tailscale-ip", tailscaleIP)
 	}
+	for _, proxy := range trustedProxies {
+		args = append(args, "--trusted-proxy", proxy)
+	}
+	if noLocalhostBypass {
+		args = append(args, "--no-localhost-bypass")
+	}
+	if sessionTTL != 12*time.Hour {
+		args = append(args, "--session-ttl", sessionTTL.String())
+	}
+	if tlsCert != "" {
+		args = append(args, "--tls-cert", tlsCert)
+	}
+	if tlsKey != "" {
+		args = append(args, "--tls-key", tlsKey)
+	}
+	if tlsSelfSigned {
+		args = append(args, "--tls-self-signed")
+	}
+	if httpRedirectPort != 0 {
+		args = append(args, "--http-port", fmt.Sprintf("%d", httpRedirectPort))
+	}
+	if pidFile != "" {
+		args = append(args, "--pid-file", pidFile)
+	}
+	if refreshInterval != 5*time.Minute {
+		args = append(args, "--refresh-interval", refreshInterval.String())
+	}
+	if cfgFile != "" {
+		args = append(args, "--config", cfgFile)
+	}
+	if timezone != "" {
+		args = append(args, "--timezone", timezone)
+	}
+	if logLevel != "info" {
+		args = append(args, "--log-level", logLevel)
+	}
+	if logFormat != "text" {
+		args = append(args, "--log-format", logFormat)
+	}
+
+	// Send the server's logs to a rotating file rather than this terminal,
+	// which goes away when the CLI exits. Anything not written through the
+	// logger (e.g. a panic) is appended to the same file.
+	logPath, err := resolveServeLogFile()
+	if err != nil {
+		return err
+	}
+	args = append(args, "--log-file", logPath,
+		"--log-max-size", fmt.Sprintf("%d", logMaxSize),
+		"--log-max-backups", fmt.Sprintf("%d", logMaxBackups))
+	if err := os.MkdirAll(filepath.Dir(logPath), 0700); err != nil {
+		return fmt.Errorf("creating log directory: %w", err)
+	}
+	serverLog, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("opening log file: %w", err)
+	}
+	defer serverLog.Close()
 
 	// Start the server process detached from parent
 	cmd := exec.Command(exePath, args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stdout = serverLog
+	cmd.Stderr = serverLog
 	cmd.Stdin = nil
-
-	// Detach from parent process
-	if cmd.SysProcAttr != nil {
-		cmd.SysProcAttr.Setpgid = true
-	}
+	cmd.SysProcAttr = detachedProcAttr()
 
 	if err := cmd.Start(); err != nil {
 		return fmt.Errorf("starting server process: %w", err)
 	}
 
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
 	// Get the bind host for the health check
 	var bindHost string
 	if bindAll {
@@ -278,42 +555,195 @@ func startServerInBackground() error {
 	}
 
 	addr := fmt.Sprintf("%s:%d", bindHost, servePort)
-	url := fmt.Sprintf("http://%s", addr)
+	scheme := "http"
+	if tlsEnabled() {
+		scheme = "https"
+	}
+	url := fmt.Sprintf("%s://%s", scheme, addr)
 
 	fmt.Printf("Server starting on %s\n", url)
 	fmt.Println("Waiting for server to be ready...")
 
-	// Wait and check if server is ready
-	client := &http.Client{Timeout: 2 * time.Second}
+	// Wait and check if server is ready. The probe only checks that our own
+	// child answers, so the (possibly self-signed) certificate is not verified.
+	client := &http.Client{
+		Timeout:   2 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+	}
 	maxAttempts := 15
 	for i := 0; i < maxAttempts; i++ {
-		time.Sleep(500 * time.Millisecond)
+		select {
+		case err := <-exited:
+			return fmt.Errorf("server exited during startup (%v); see %s", err, logPath)
+		case <-time.After(500 * time.Millisecond):
+		}
 		resp, err := client.Get(url)
 		if err == nil {
 			resp.Body.Close()
 			fmt.Printf("✓ Server is online and reachable at %s\n", url)
 			fmt.Println("The server is now running in the background.")
 			fmt.Printf("Process ID: %d\n", cmd.Process.Pid)
+			fmt.Printf("Logs: %s\n", logPath)
+			fmt.Println("Stop it with 'syntrack serve stop'.")
 			return nil
 		}
 	}
 
 	// If we get here, server didn't start in time
-	return fmt.Errorf("server failed to start within %d seconds", maxAttempts/2)
+	return fmt.Errorf("server failed to start within %d seconds; see %s", maxAttempts/2, logPath)
 }
 
 func init() {
-	serveCmd.Flags().IntVarP(&servePort, "port", "p", 8080, "Port to listen on")
-	serveCmd.Flags().BoolVar(&requireAuth, "auth", false, "Require token authentication (reads from SYNTRACK_AUTH_TOKENS env or ~/.syntrack/tokens)")
-	serveCmd.Flags().BoolVar(&bindAll, "bind-all", false, "Bind to all interfaces (0.0.0.0) - requires auth token")
-	serveCmd.Flags().BoolVar(&useTailscale, "tailscale", false, "Bind to Tailscale interface")
-	serveCmd.Flags().StringVar(&tailscaleIP, "tailscale-ip", "", "Tailscale IP address (auto-detected if not specified)")
-	serveCmd.Flags().BoolVar(&serveSilent, "silent", false, "Start server in background and exit")
+	serveCmd.PersistentFlags().IntVarP(&servePort, "port", "p", 8080, "Port to listen on")
+	serveCmd.PersistentFlags().BoolVar(&requireAuth, "auth", false, "Require token authentication (reads from SYNTRACK_AUTH_TOKENS env or ~/.syntrack/tokens)")
+	serveCmd.PersistentFlags().BoolVar(&bindAll, "bind-all", false, "Bind to all interfaces (0.0.0.0) - requires auth token")
+	serveCmd.PersistentFlags().BoolVar(&useTailscale, "tailscale", false, "Bind to Tailscale interface")
+	serveCmd.PersistentFlags().StringVar(&tailscaleIP, "tailscale-ip", "", "Tailscale IP address (auto-detected if not specified)")
+	serveCmd.PersistentFlags().BoolVar(&serveSilent, "silent", false, "Start server in background and exit")
+	serveCmd.PersistentFlags().StringSliceVar(&trustedProxies, "trusted-proxy", nil, "Reverse proxy address or CIDR whose X-Forwarded-For header is trusted (repeatable)")
+	serveCmd.PersistentFlags().BoolVar(&noLocalhostBypass, "no-localhost-bypass", false, "Require a token for requests from localhost too")
+	serveCmd.PersistentFlags().DurationVar(&sessionTTL, "session-ttl", 12*time.Hour, "Lifetime of dashboard login sessions")
+	serveCmd.PersistentFlags().StringVar(&tlsCert, "tls-cert", "", "TLS certificate file (PEM); reloaded automatically when it changes")
+	serveCmd.PersistentFlags().StringVar(&tlsKey, "tls-key", "", "TLS private key file (PEM)")
+	serveCmd.PersistentFlags().BoolVar(&tlsSelfSigned, "tls-self-signed", false, "Serve HTTPS with a self-signed certificate kept in ~/.syntrack/tls")
+	serveCmd.PersistentFlags().IntVar(&httpRedirectPort, "http-port", 0, "Also listen for plain HTTP on this port and redirect to HTTPS")
+	serveCmd.PersistentFlags().DurationVar(&refreshInterval, "refresh-interval", 5*time.Minute, "How often the dashboard refreshes the current status (0 disables)")
+
+	flags := serveCmd.PersistentFlags()
+	bindConfigFlag("serve.port", flags, "port")
+	bindConfigFlag("serve.auth", flags, "auth")
+	bindConfigFlag("serve.bind_all", flags, "bind-all")
+	bindConfigFlag("serve.tailscale", flags, "tailscale")
+	bindConfigFlag("serve.tailscale_ip", flags, "tailscale-ip")
+	bindConfigFlag("serve.trusted_proxy", flags, "trusted-proxy")
+	bindConfigFlag("serve.no_localhost_bypass", flags, "no-localhost-bypass")
+	bindConfigFlag("serve.session_ttl", flags, "session-ttl")
+	bindConfigFlag("serve.refresh_interval", flags, "refresh-interval")
+	bindConfigFlag("serve.tls_cert", flags, "tls-cert")
+	bindConfigFlag("serve.tls_key", flags, "tls-key")
+	bindConfigFlag("serve.tls_self_signed", flags, "tls-self-signed")
+	bindConfigFlag("serve.http_port", flags, "http-port")
 	rootCmd.AddCommand(serveCmd)
 }
 
+// applyServeConfig copies the merged serve settings into the flag variables.
+func applyServeConfig(c config.ServeConfig) {
+	servePort = c.Port
+	requireAuth = c.Auth
+	bindAll = c.BindAll
+	useTailscale = c.Tailscale
+	tailscaleIP = c.TailscaleIP
+	trustedProxies = c.TrustedProxies
+	noLocalhostBypass = c.NoLocalhostBypass
+	sessionTTL = c.SessionTTL
+	refreshInterval = c.RefreshInterval
+	tlsCert = c.TLSCert
+	tlsKey = c.TLSKey
+	tlsSelfSigned = c.TLSSelfSigned
+	httpRedirectPort = c.HTTPPort
+	pidFile = c.PIDFile
+}
+
+// pageData is passed to layout.html; Data holds the page's own content.
+type pageData struct {
+	AuthRequired  bool
+	Authenticated bool
+	CSRFToken     string
+	// Refresh is the htmx trigger interval for live sections, e.g. "5m".
+	Refresh string
+	Data    any
+
+	// Static pages are exported by export-site: Partials holds the
+	// rendered partials by name instead of loading them with htmx.
+	Static   bool
+	Partials map[string]template.HTML
+	Exported string
+}
+
+// Link returns the URL of a dashboard page ("" for the index).
+func (pd pageData) Link(page string) string {
+	if pd.Static {
+		if page == "" {
+			page = "index"
+		}
+		return page + ".html"
+	}
+	return "/" + page
+}
+
+// Asset returns the URL of a file under web/static.
+func (pd pageData) Asset(name string) string {
+	if pd.Static {
+		return "static/" + name
+	}
+	return "/static/" + name
+}
+
+type pageRenderer func(w http.ResponseWriter, r *http.Request, page string, status int, data any)
+
+func makePageRenderer() pageRenderer {
+	return func(w http.ResponseWriter, r *http.Request, page string, status int, data any) {
+		pd := pageData{AuthRequired: requireAuth, Data: data}
+		if refreshInterval > 0 {
+			pd.Refresh = fmt.Sprintf("%ds", int(refreshInterval.Seconds()))
+		}
+		if sess, ok := sessionFromRequest(r); ok {
+			pd.Authenticated = true
+			pd.CSRFToken = sess.csrfToken
+		}
+
+		var buf bytes.Buffer
+		if err := renderPage(&buf, page, pd); err != nil {
+			slog.Error("rendering page", "page", page, "err", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(status)
+		w.Write(buf.Bytes())
+	}
+}
+
+// renderPage executes layout.html with the given page template. The
+// templates are parsed for each page: a template set cannot be cloned
+// once one of its partials has been executed.
+func renderPage(w io.Writer, page string, pd pageData) error {
+	tmpl, err := template.ParseGlob("web/templates/partials/*.html")
+	if err != nil {
+		return err
+	}
+	tmpl, err = tmpl.ParseFiles("web/templates/layout.html", "web/templates/"+page)
+	if err != nil {
+		return err
+	}
+	return tmpl.ExecuteTemplate(w, "layout.html", pd)
+}
+
+func makePageHandler(render pageRenderer, page string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render(w, r, page, http.StatusOK, nil)
+	}
+}
+
 type partialDataProvider func(database *db.DB) (any, error)
 
+// dashboardPartials are served as /partials/<name> from <name>.html, and
+// rendered inline by export-site.
+var dashboardPartials = []struct {
+	Name     string
+	Provider partialDataProvider
+}{
+	{"status", getStatusData},
+	{"chart", getChartData},
+	{"burn-rate", getBurnRateData},
+	{"history-table", getHistoryData},
+	{"daily-stats", getDailyData},
+	{"weekly-stats", getWeeklyData},
+	{"overall-stats", getOverallData},
+	{"heatmap", getHeatmapData},
+	{"proxy-usage", getProxyUsageData},
+}
+
 func makePartialHandler(database *db.DB, tmpl *template.Template, name string, provider partialDataProvider) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		data, err := provider(database)
@@ -322,7 +752,9 @@ func makePartialHandler(database *db.DB, tmpl *template.Template, name string, p
 			return
 		}
 		w.Header().Set("Content-Type", "text/html")
-		tmpl.ExecuteTemplate(w, name, data)
+		if err := tmpl.ExecuteTemplate(w, name, data); err != nil {
+			slog.Error("rendering partial", "partial", name, "err", err)
+		}
 	}
 }
 
@@ -331,6 +763,9 @@ type StatusData struct {
 	Used     int
 	Leftover int
 	Percent  float64
+	// Pace is nil until a renewal date has been recorded.
+	Pace           *Pace
+	TodayAllowance int
 }
 
 func getStatusData(database *db.DB) (any, error) {
@@ -338,12 +773,21 @@ func getStatusData(database *db.DB) (any, error) {
 	if err != nil || snapshot == nil {
 		return StatusData{}, err
 	}
-	return StatusData{
+	data := StatusData{
 		Limit:    snapshot.SubscriptionLimit,
 		Used:     snapshot.RequestsUsed,
 		Leftover: snapshot.Leftover,
 		Percent:  float64(snapshot.RequestsUsed) / float64(snapshot.SubscriptionLimit) * 100,
-	}, nil
+	}
+	pace, err := loadPace(database)
+	if err != nil {
+		return data, err
+	}
+	if pace != nil {
+		data.Pace = pace
+		data.TodayAllowance = pace.TodayAllowance(time.Now())
+	}
+	return data, nil
 }
 
 type ChartData struct {
@@ -357,11 +801,15 @@ func getChartData(database *db.DB) (any, error) {
 		return ChartData{SVGContent: template.HTML("<text x='400' y='200' text-anchor='middle' fill='#71767b'>No data available</text>")}, nil
 	}
 
-	svg := generateSVGChart(snapshots)
+	overlays, err := loadChartOverlays(database, since)
+	if err != nil {
+		slog.Warn("loading chart overlays", "err", err)
+	}
+	svg := generateSVGChart(snapshots, overlays)
 	return ChartData{SVGContent: template.HTML(svg)}, nil
 }
 
-func generateSVGChart(snapshots []db.UsageSnapshot) string {
+func generateSVGChart(snapshots []db.UsageSnapshot, overlays chartOverlays) string {
 	if len(snapshots) < 2 {
 		return "<text x='400' y='200' text-anchor='middle' fill='#71767b'>Need more data points</text>"
 	}
@@ -381,6 +829,7 @@ func generateSVGChart(snapshots []db.UsageSnapshot) string {
 
 	pointsUsed := make([]string, len(snapshots))
 	pointsLeft := make([]string, len(snapshots))
+	var pointsPace []string
 
 	for i, s := range snapshots {
 		x := padding + (float64(i)/float64(len(snapshots)-1))*chartWidth
@@ -389,6 +838,11 @@ func generateSVGChart(snapshots []db.UsageSnapshot) string {
 
 		pointsUsed[i] = fmt.Sprintf("%.1f,%.1f", x, yUsed)
 		pointsLeft[i] = fmt.Sprintf("%.1f,%.1f", x, yLeft)
+
+		if ideal, ok := overlays.paceValue(s.CollectedAt); ok {
+			yPace := padding + chartHeight - (ideal/maxVal)*chartHeight
+			pointsPace = append(pointsPace, fmt.Sprintf("%.1f,%.1f", x, yPace))
+		}
 	}
 
 	var svg strings.Builder
@@ -405,6 +859,39 @@ func generateSVGChart(snapshots []db.UsageSnapshot) string {
 	svg.WriteString(fmt.Sprintf(`<polyline points="%s" fill="none" stroke="#f4212e" stroke-width="2"/>`, strings.Join(pointsUsed, " ")))
 	svg.WriteString(fmt.Sprintf(`<polyline points="%s" fill="none" stroke="#00ba7c" stroke-width="2"/>`, strings.Join(pointsLeft, " ")))
 
+	if len(pointsPace) > 1 {
+		svg.WriteString(fmt.Sprintf(`<text x="%.0f" y="%.0f" fill="#71767b" font-size="12">Pace</text>`, padding+180, padding-20))
+		svg.WriteString(fmt.Sprintf(`<polyline points="%s" fill="none" stroke="#1d9bf0" stroke-width="1.5" stroke-dasharray="6 4"/>`, strings.Join(pointsPace, " ")))
+	}
+
+	for _, a := range overlays.Anomalies {
+		i := snapshotIndex(snapshots, a.IntervalEnd)
+		if i < 0 {
+			continue
+		}
+		x := padding + (float64(i)/float64(len(snapshots)-1))*chartWidth
+		y := padding + chartHeight - (float64(snapshots[i].RequestsUsed)/maxVal)*chartHeight
+		svg.WriteString(fmt.Sprintf(`<circle cx="%.1f" cy="%.1f" r="6" fill="none" stroke="#ffd400" stroke-width="2"><title>Anomaly: %d requests (%.1f/h, usual %.1f/h) until %s</title></circle>`,
+			x, y, a.Consumed, a.Rate, a.BaselineRate, a.IntervalEnd.Local().Format("01/02 15:04")))
+	}
+
+	annotated := 0
+	for _, a := range overlays.Annotations {
+		pos, ok := snapshotPosition(snapshots, a.At)
+		if !ok {
+			continue
+		}
+		annotated++
+		x := padding + pos/float64(len(snapshots)-1)*chartWidth
+		svg.WriteString(fmt.Sprintf(`<g><title>%s: %s</title>`, a.At.Local().Format("01/02 15:04"), template.HTMLEscapeString(a.Text)))
+		svg.WriteString(fmt.Sprintf(`<line x1="%.1f" y1="%.0f" x2="%.1f" y2="%.0f" stroke="#c084fc" stroke-width="1" stroke-dasharray="3 3"/>`, x, padding, x, height-padding))
+		svg.WriteString(fmt.Sprintf(`<circle cx="%.1f" cy="%.0f" r="8" fill="#c084fc"/>`, x, padding))
+		svg.WriteString(fmt.Sprintf(`<text x="%.1f" y="%.0f" fill="#0f1419" font-size="10" font-weight="bold" text-anchor="middle">%d</text></g>`, x, padding+3.5, annotated))
+	}
+	if annotated > 0 {
+		svg.WriteString(fmt.Sprintf(`<text x="%.0f" y="%.0f" fill="#c084fc" font-size="12">Annotations</text>`, padding+260, padding-20))
+	}
+
 	step := len(snapshots) / 5
 	if step < 1 {
 		step = 1
@@ -421,6 +908,14 @@ func generateSVGChart(snapshots []db.UsageSnapshot) string {
 	return svg.String()
 }
 
+func getHeatmapData(database *db.DB) (any, error) {
+	h, err := loadHeatmap(database, 28)
+	if err != nil {
+		return ChartData{}, err
+	}
+	return ChartData{SVGContent: template.HTML(generateHeatmapSVG(h))}, nil
+}
+
 type BurnRateData struct {
 	Rate      float64
 	HoursLeft float64
@@ -455,7 +950,7 @@ func getBurnRateData(database *db.DB) (any, error) {
 }
 
 type HistoryTableData struct {
-	Snapshots []db.UsageSnapshot
+	Rows []historyRow
 }
 
 func getHistoryData(database *db.DB) (any, error) {
@@ -465,11 +960,12 @@ func getHistoryData(database *db.DB) (any, error) {
 		return HistoryTableData{}, err
 	}
 
-	for i, j := 0, len(snapshots)-1; i < j; i, j = i+1, j-1 {
-		snapshots[i], snapshots[j] = snapshots[j], snapshots[i]
+	annotations, err := database.GetAnnotations(since)
+	if err != nil {
+		return HistoryTableData{}, err
 	}
 
-	return HistoryTableData{Snapshots: snapshots}, nil
+	return HistoryTableData{Rows: historyRows(snapshots, annotations)}, nil
 }
 
 func getDailyData(database *db.DB) (any, error) {
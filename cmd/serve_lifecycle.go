@@ -0,0 +1,228 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aure/syntrack/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var pidFile string
+
+// stopTimeout is how long 'serve stop' waits for a graceful shutdown.
+const stopTimeout = 15 * time.Second
+
+var serveStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop the background server",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return stopServer()
+	},
+}
+
+var serveStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether the background server is running",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := resolvePIDFile()
+		if err != nil {
+			return err
+		}
+
+		pid, running, err := runningServerPID()
+		if err != nil {
+			return err
+		}
+		if !running {
+			fmt.Println("Server is not running")
+			return nil
+		}
+
+		fmt.Printf("Server is running (PID %d)\n", pid)
+		fmt.Printf("  PID file: %s\n", path)
+		if logPath, err := resolveServeLogFile(); err == nil {
+			if _, err := os.Stat(logPath); err == nil {
+				fmt.Printf("  Log file: %s\n", logPath)
+			}
+		}
+		return nil
+	},
+}
+
+var serveRestartCmd = &cobra.Command{
+	Use:   "restart",
+	Short: "Restart the background server",
+	Long: `Stop the background server (if running) and start it again in the
+background. Pass the same flags you would give 'syntrack serve'.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, running, err := runningServerPID(); err != nil {
+			return err
+		} else if running {
+			if err := stopServer(); err != nil {
+				return err
+			}
+		}
+		return startServerInBackground()
+	},
+}
+
+func resolvePIDFile() (string, error) {
+	if pidFile != "" {
+		return pidFile, nil
+	}
+	dir, err := config.Dir()
+	if err != nil {
+		return "", fmt.Errorf("getting home directory: %w", err)
+	}
+	return filepath.Join(dir, "syntrack.pid"), nil
+}
+
+func resolveServeLogFile() (string, error) {
+	if logFile != "" {
+		return logFile, nil
+	}
+	dir, err := config.Dir()
+	if err != nil {
+		return "", fmt.Errorf("getting home directory: %w", err)
+	}
+	return filepath.Join(dir, "logs", "serve.log"), nil
+}
+
+func readPIDFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("invalid PID file %s", path)
+	}
+	return pid, nil
+}
+
+// runningServerPID returns the PID recorded in the PID file and whether the
+// server that wrote it still runs, which is the case while it holds the
+// lock on the file. The PID alone is not trusted: after a crash or reboot
+// it may belong to an unrelated process. A stale PID file is removed.
+func runningServerPID() (int, bool, error) {
+	path, err := resolvePIDFile()
+	if err != nil {
+		return 0, false, err
+	}
+
+	pid, err := readPIDFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	locked, err := lockPIDFile(f)
+	f.Close()
+	if err != nil {
+		return 0, false, fmt.Errorf("checking PID file %s: %w", path, err)
+	}
+	if locked {
+		os.Remove(path)
+		return pid, false, nil
+	}
+	return pid, true, nil
+}
+
+// writePIDFile records the current process and locks the PID file until
+// removePIDFile or exit, refusing to take over the file of a running
+// server.
+func writePIDFile() (*os.File, error) {
+	path, err := resolvePIDFile()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	locked, err := lockPIDFile(f)
+	if err != nil || !locked {
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("locking PID file %s: %w", path, err)
+		}
+		if pid, err := readPIDFile(path); err == nil {
+			return nil, fmt.Errorf("server already running (PID %d); use 'syntrack serve stop' or --pid-file", pid)
+		}
+		return nil, fmt.Errorf("server already running (%s is locked); use 'syntrack serve stop' or --pid-file", path)
+	}
+
+	if err := f.Truncate(0); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.WriteString(strconv.Itoa(os.Getpid()) + "\n"); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// removePIDFile deletes the PID file if it still belongs to this process
+// and releases the lock.
+func removePIDFile(f *os.File) {
+	if pid, err := readPIDFile(f.Name()); err == nil && pid == os.Getpid() {
+		os.Remove(f.Name())
+	}
+	f.Close()
+}
+
+func stopServer() error {
+	pid, running, err := runningServerPID()
+	if err != nil {
+		return err
+	}
+	if !running {
+		fmt.Println("Server is not running")
+		return nil
+	}
+
+	fmt.Printf("Stopping server (PID %d)...\n", pid)
+	if err := terminateProcess(pid); err != nil {
+		return fmt.Errorf("signalling server: %w", err)
+	}
+
+	// The server removes its PID file on the way out; a crash leaves it
+	// unlocked, which runningServerPID cleans up.
+	deadline := time.Now().Add(stopTimeout)
+	for time.Now().Before(deadline) {
+		if _, running, err := runningServerPID(); err == nil && !running {
+			fmt.Println("✓ Server stopped")
+			return nil
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	return fmt.Errorf("server (PID %d) did not stop within %s", pid, stopTimeout)
+}
+
+func init() {
+	serveCmd.PersistentFlags().StringVar(&pidFile, "pid-file", "", "PID file of the server (default ~/.syntrack/syntrack.pid)")
+	bindConfigFlag("serve.pid_file", serveCmd.PersistentFlags(), "pid-file")
+	serveCmd.AddCommand(serveStopCmd)
+	serveCmd.AddCommand(serveStatusCmd)
+	serveCmd.AddCommand(serveRestartCmd)
+}
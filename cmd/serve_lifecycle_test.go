@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func usePIDFile(t *testing.T) string {
+	t.Helper()
+	old := pidFile
+	pidFile = filepath.Join(t.TempDir(), "syntrack.pid")
+	t.Cleanup(func() { pidFile = old })
+	return pidFile
+}
+
+func TestWritePIDFile(t *testing.T) {
+	path := usePIDFile(t)
+
+	lock, err := writePIDFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pid, err := readPIDFile(path); err != nil || pid != os.Getpid() {
+		t.Fatalf("PID file holds %d, %v; want %d", pid, err, os.Getpid())
+	}
+	if pid, running, err := runningServerPID(); err != nil || !running || pid != os.Getpid() {
+		t.Fatalf("runningServerPID = %d, %v, %v; want the locked server", pid, running, err)
+	}
+
+	// A second server must not take over the PID file
+	if second, err := writePIDFile(); err == nil {
+		second.Close()
+		t.Fatal("second server was allowed to write the PID file")
+	} else if !strings.Contains(err.Error(), "already running (PID "+strconv.Itoa(os.Getpid())+")") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	removePIDFile(lock)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("PID file left behind: %v", err)
+	}
+	if _, running, err := runningServerPID(); err != nil || running {
+		t.Fatalf("after removal: running %v, %v", running, err)
+	}
+}
+
+func TestRunningServerPID_StaleFile(t *testing.T) {
+	tests := []struct {
+		name string
+		pid  int
+	}{
+		// A live process that is not a syntrack server, as after a reboot
+		// hands the old PID to something else
+		{"live unrelated process", os.Getpid()},
+		{"dead process", 1 << 30},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := usePIDFile(t)
+			if err := os.WriteFile(path, []byte(strconv.Itoa(tt.pid)+"\n"), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, running, err := runningServerPID(); err != nil || running {
+				t.Fatalf("running %v, %v; want a stale PID file", running, err)
+			}
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Fatalf("stale PID file not removed: %v", err)
+			}
+		})
+	}
+}
+
+func TestStopServer_IgnoresUnlockedPIDFile(t *testing.T) {
+	path := usePIDFile(t)
+	// Were the PID trusted, stopServer would SIGTERM the test binary
+	if err := os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := stopServer(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("stale PID file not removed: %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"time"
+
+	"github.com/aure/syntrack/internal/tokens"
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDebounce groups the bursts of events editors and atomic renames
+// produce into a single reload.
+const reloadDebounce = 250 * time.Millisecond
+
+// tokenFlushInterval is how often the last-used times of the auth tokens
+// are written to disk.
+const tokenFlushInterval = time.Minute
+
+// watchAuthConfig reloads the auth tokens when the token file or config file
+// changes, or when the process receives SIGHUP, until ctx is done.
+func watchAuthConfig(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var events chan fsnotify.Event
+	var errs chan error
+	watched := watchedConfigFiles()
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		slog.Warn("cannot watch token and config files; reload with SIGHUP", "err", err)
+	} else {
+		defer watcher.Close()
+		// Watch the directories: editors and the token store replace files
+		// by renaming, which a watch on the file itself would not survive.
+		dirs := make(map[string]bool)
+		for path := range watched {
+			dirs[filepath.Dir(path)] = true
+		}
+		for dir := range dirs {
+			if err := watcher.Add(dir); err != nil {
+				slog.Warn("cannot watch directory; reload with SIGHUP", "dir", dir, "err", err)
+			}
+		}
+		events, errs = watcher.Events, watcher.Errors
+	}
+
+	var pending <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			reloadAuthConfig("SIGHUP")
+		case ev, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			if watched[filepath.Clean(ev.Name)] && !ev.Has(fsnotify.Chmod) {
+				pending = time.After(reloadDebounce)
+			}
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			slog.Warn("file watcher error", "err", err)
+		case <-pending:
+			pending = nil
+			reloadAuthConfig("file changed")
+		}
+	}
+}
+
+// watchedConfigFiles returns the files whose changes affect the token set.
+func watchedConfigFiles() map[string]bool {
+	files := make(map[string]bool)
+	for _, path := range []string{cfg.TokenFile, cfg.File, cfg.TokensFile} {
+		if path == "" {
+			continue
+		}
+		if abs, err := filepath.Abs(path); err == nil {
+			files[abs] = true
+		}
+	}
+	return files
+}
+
+// reloadAuthConfig re-reads the configuration and token file and swaps in
+// the new token set. On any error the current set stays active. Only the
+// tokens are published; other settings keep the values the server started
+// with.
+func reloadAuthConfig(reason string) {
+	next, err := reloadConfig()
+	if err != nil {
+		slog.Error("reloading config failed; keeping current tokens", "reason", reason, "err", err)
+		return
+	}
+	store, err := next.LoadAuthTokens()
+	if err != nil {
+		slog.Error("reloading auth tokens failed; keeping current tokens", "reason", reason, "err", err)
+		return
+	}
+
+	previous := authTokens.Swap(store)
+	flushAuthTokens(previous)
+	changes := tokens.Diff(previous, store)
+	for _, c := range changes {
+		slog.Info("auth token "+c.Kind, "id", c.Token.ID, "label", c.Token.Label)
+	}
+	if len(changes) > 0 || reason == "SIGHUP" {
+		slog.Info("auth tokens reloaded", "reason", reason, "tokens", store.Len(), "changes", len(changes))
+	}
+	if store.Len() == 0 {
+		slog.Warn("no auth tokens configured; all authenticated requests are rejected")
+	}
+
+	if !reflect.DeepEqual(next.Serve, cfg.Serve) {
+		slog.Warn("serve settings changed in the config; restart the server to apply them")
+	}
+}
+
+// flushTokenUsage writes the last-used times of the active tokens every
+// tokenFlushInterval until ctx is done.
+func flushTokenUsage(ctx context.Context) {
+	ticker := time.NewTicker(tokenFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			flushAuthTokens(authTokens.Load())
+		}
+	}
+}
+
+func flushAuthTokens(store *tokens.Store) {
+	if store == nil {
+		return
+	}
+	if err := store.Flush(); err != nil {
+		slog.Warn("recording token last use failed", "err", err)
+	}
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aure/syntrack/internal/tokens"
+	"github.com/spf13/viper"
+)
+
+func TestReloadAuthConfig(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "tokens")
+	configFile := filepath.Join(dir, "syntrack.yaml")
+	if err := os.WriteFile(configFile, []byte("token_file: "+tokenFile+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	oldCfg, oldCfgFile, oldAuthTokens := cfg, cfgFile, authTokens.Load()
+	t.Cleanup(func() {
+		cfg, cfgFile = oldCfg, oldCfgFile
+		authTokens.Store(oldAuthTokens)
+	})
+	cfgFile = configFile
+	started, err := reloadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg = started
+	authTokens.Store(tokens.New(tokenFile))
+	globalFile := viper.ConfigFileUsed()
+
+	secret, _, err := tokens.New(tokenFile).Generate("ci", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloadAuthConfig("test")
+
+	store := authTokens.Load()
+	if _, ok := store.Verify(secret); !ok {
+		t.Fatal("expected the new token to be active after reload")
+	}
+	if cfg != started {
+		t.Fatal("reload replaced the startup config")
+	}
+	if viper.ConfigFileUsed() != globalFile {
+		t.Fatalf("reload changed the global viper config file to %q", viper.ConfigFileUsed())
+	}
+
+	// Recording token use must not look like a change to the token set
+	if err := store.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	watched := watchedConfigFiles()
+	written, _ := filepath.Glob(filepath.Join(dir, "*"))
+	for _, path := range written {
+		if path != tokenFile && path != configFile && watched[path] {
+			t.Fatalf("server-written file %s is watched", path)
+		}
+	}
+}
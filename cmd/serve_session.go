@@ -0,0 +1,249 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const sessionCookieName = "syntrack_session"
+
+var sessionTTL time.Duration
+
+// session is a dashboard login. Sessions live only in server memory, so a
+// restart logs everyone out.
+type session struct {
+	id        string
+	csrfToken string
+	tokenHash string
+	expiresAt time.Time
+}
+
+type sessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+var sessions = &sessionStore{sessions: make(map[string]*session)}
+
+func (s *sessionStore) create(tokenHash string, ttl time.Duration) (*session, error) {
+	id, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+	csrf, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+
+	sess := &session{
+		id:        id,
+		csrfToken: csrf,
+		tokenHash: tokenHash,
+		expiresAt: time.Now().Add(ttl),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for key, existing := range s.sessions {
+		if now.After(existing.expiresAt) {
+			delete(s.sessions, key)
+		}
+	}
+	s.sessions[id] = sess
+	return sess, nil
+}
+
+func (s *sessionStore) get(id string) (*session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.sessions[id]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(sess.expiresAt) {
+		delete(s.sessions, id)
+		return nil, false
+	}
+	return sess, true
+}
+
+func (s *sessionStore) delete(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+}
+
+// sessionFromRequest returns the live session referenced by the request's
+// cookie. A session stops being valid as soon as the token it was created
+// with is revoked, rotated or expires.
+func sessionFromRequest(r *http.Request) (*session, bool) {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil || cookie.Value == "" {
+		return nil, false
+	}
+	sess, ok := sessions.get(cookie.Value)
+	if !ok {
+		return nil, false
+	}
+	if store := authTokens.Load(); store != nil && !store.Active(sess.tokenHash) {
+		sessions.delete(sess.id)
+		return nil, false
+	}
+	return sess, true
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// checkCSRF guards state-changing requests made by browsers. Requests in a
+// session must echo the session's CSRF token in the X-CSRF-Token header or
+// a csrf_token form field; requests without a session (localhost bypass or
+// auth disabled) must at least not come from another site.
+func checkCSRF(r *http.Request, sess *session) bool {
+	if isSafeMethod(r.Method) {
+		return true
+	}
+	if sess != nil {
+		token := r.Header.Get("X-CSRF-Token")
+		if token == "" {
+			token = r.PostFormValue("csrf_token")
+		}
+		return subtle.ConstantTimeCompare([]byte(token), []byte(sess.csrfToken)) == 1
+	}
+	return isSameOrigin(r)
+}
+
+func isSameOrigin(r *http.Request) bool {
+	if r.Header.Get("Sec-Fetch-Site") == "cross-site" {
+		return false
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return u.Host == r.Host
+}
+
+// safeRedirect only allows local paths, so ?next= cannot send a freshly
+// logged-in user to another site.
+func safeRedirect(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
+
+func setSessionCookie(w http.ResponseWriter, r *http.Request, sess *session) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    sess.id,
+		Path:     "/",
+		Expires:  sess.expiresAt,
+		MaxAge:   int(time.Until(sess.expiresAt).Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+func clearSessionCookie(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// makeLoginHandler serves the login form on GET and exchanges a token for a
+// session cookie on POST.
+func makeLoginHandler(render pageRenderer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		next := safeRedirect(r.FormValue("next"))
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			render(w, r, "login.html", http.StatusOK, loginData{Next: next})
+		case http.MethodPost:
+			if !isSameOrigin(r) {
+				http.Error(w, "Forbidden: cross-site login", http.StatusForbidden)
+				return
+			}
+			store := authTokens.Load()
+			if store == nil {
+				http.Redirect(w, r, next, http.StatusSeeOther)
+				return
+			}
+
+			token, ok := store.Verify(r.PostFormValue("token"))
+			if !ok {
+				logAuthFailure(r, "invalid login token")
+				render(w, r, "login.html", http.StatusUnauthorized, loginData{Next: next, Error: "Invalid or expired token."})
+				return
+			}
+
+			sess, err := sessions.create(token.Hash, sessionTTL)
+			if err != nil {
+				http.Error(w, "creating session", http.StatusInternalServerError)
+				return
+			}
+			setSessionCookie(w, r, sess)
+			http.Redirect(w, r, next, http.StatusSeeOther)
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func handleLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if sess, ok := sessionFromRequest(r); ok {
+		if !checkCSRF(r, sess) {
+			logAuthFailure(r, "invalid CSRF token")
+			http.Error(w, "Forbidden: invalid CSRF token", http.StatusForbidden)
+			return
+		}
+		sessions.delete(sess.id)
+	}
+	clearSessionCookie(w, r)
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+type loginData struct {
+	Next  string
+	Error string
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
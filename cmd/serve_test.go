@@ -3,19 +3,23 @@ package cmd
 import (
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/aure/syntrack/internal/tokens"
 )
 
 func TestTokenAuth_AllowsHeaderToken(t *testing.T) {
 	oldRequireAuth := requireAuth
-	oldAuthTokens := authTokens
+	oldAuthTokens := authTokens.Load()
 	t.Cleanup(func() {
 		requireAuth = oldRequireAuth
-		authTokens = oldAuthTokens
+		authTokens.Store(oldAuthTokens)
 	})
 
 	requireAuth = true
-	authTokens = []string{"syntrack_token_valid"}
+	authTokens.Store(tokens.FromPlaintext("syntrack_token_valid"))
 
 	nextCalled := false
 	handler := tokenAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -38,16 +42,16 @@ func TestTokenAuth_AllowsHeaderToken(t *testing.T) {
 	}
 }
 
-func TestTokenAuth_AllowsQueryToken(t *testing.T) {
+func TestTokenAuth_RejectsQueryToken(t *testing.T) {
 	oldRequireAuth := requireAuth
-	oldAuthTokens := authTokens
+	oldAuthTokens := authTokens.Load()
 	t.Cleanup(func() {
 		requireAuth = oldRequireAuth
-		authTokens = oldAuthTokens
+		authTokens.Store(oldAuthTokens)
 	})
 
 	requireAuth = true
-	authTokens = []string{"syntrack_token_valid"}
+	authTokens.Store(tokens.FromPlaintext("syntrack_token_valid"))
 
 	nextCalled := false
 	handler := tokenAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -55,30 +59,32 @@ func TestTokenAuth_AllowsQueryToken(t *testing.T) {
 		w.WriteHeader(http.StatusOK)
 	}))
 
+	// Tokens in URLs leak into history and proxy logs, so they are no
+	// longer accepted; browsers log in through /login instead.
 	req := httptest.NewRequest(http.MethodGet, "http://example.com/?token=syntrack_token_valid", nil)
 	req.Host = "example.com:8080"
 	rr := httptest.NewRecorder()
 
 	handler.ServeHTTP(rr, req)
 
-	if rr.Code != http.StatusOK {
-		t.Fatalf("expected status 200, got %d", rr.Code)
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status 401, got %d", rr.Code)
 	}
-	if !nextCalled {
-		t.Fatal("expected next handler to be called")
+	if nextCalled {
+		t.Fatal("expected next handler not to be called")
 	}
 }
 
 func TestTokenAuth_RejectsWhenTokenMissingOrInvalid(t *testing.T) {
 	oldRequireAuth := requireAuth
-	oldAuthTokens := authTokens
+	oldAuthTokens := authTokens.Load()
 	t.Cleanup(func() {
 		requireAuth = oldRequireAuth
-		authTokens = oldAuthTokens
+		authTokens.Store(oldAuthTokens)
 	})
 
 	requireAuth = true
-	authTokens = []string{"syntrack_token_valid"}
+	authTokens.Store(tokens.FromPlaintext("syntrack_token_valid"))
 
 	handler := tokenAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -102,3 +108,177 @@ func TestTokenAuth_RejectsWhenTokenMissingOrInvalid(t *testing.T) {
 		t.Fatalf("expected status 401 for invalid token, got %d", invalidRR.Code)
 	}
 }
+
+func TestTokenAuth_LocalhostBypassUsesRemoteAddr(t *testing.T) {
+	oldRequireAuth := requireAuth
+	oldAuthTokens := authTokens.Load()
+	oldTrusted := trustedProxyNets
+	oldNoBypass := noLocalhostBypass
+	t.Cleanup(func() {
+		requireAuth = oldRequireAuth
+		authTokens.Store(oldAuthTokens)
+		trustedProxyNets = oldTrusted
+		noLocalhostBypass = oldNoBypass
+	})
+
+	requireAuth = true
+	authTokens.Store(tokens.FromPlaintext("syntrack_token_valid"))
+
+	handler := tokenAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		host       string
+		forwarded  string
+		trusted    []string
+		noBypass   bool
+		want       int
+	}{
+		{name: "spoofed localhost host", remoteAddr: "203.0.113.7:51000", host: "localhost:8080", want: http.StatusUnauthorized},
+		{name: "spoofed loopback host", remoteAddr: "203.0.113.7:51000", host: "127.0.0.1:8080", want: http.StatusUnauthorized},
+		{name: "spoofed ipv6 loopback host", remoteAddr: "203.0.113.7:51000", host: "[::1]:8080", want: http.StatusUnauthorized},
+		{name: "loopback peer", remoteAddr: "127.0.0.1:51000", host: "example.com:8080", want: http.StatusOK},
+		{name: "ipv6 loopback peer", remoteAddr: "[::1]:51000", host: "example.com:8080", want: http.StatusOK},
+		{name: "loopback peer with bypass disabled", remoteAddr: "127.0.0.1:51000", host: "localhost:8080", noBypass: true, want: http.StatusUnauthorized},
+		{name: "spoofed forwarded header from untrusted peer", remoteAddr: "203.0.113.7:51000", host: "localhost:8080", forwarded: "127.0.0.1", want: http.StatusUnauthorized},
+		{name: "untrusted local proxy forwarding remote client", remoteAddr: "127.0.0.1:51000", host: "localhost:8080", forwarded: "203.0.113.7", want: http.StatusUnauthorized},
+		{name: "trusted proxy forwarding remote client", remoteAddr: "10.0.0.5:51000", host: "localhost:8080", forwarded: "127.0.0.1, 203.0.113.7", trusted: []string{"10.0.0.0/8"}, want: http.StatusUnauthorized},
+		{name: "trusted proxy forwarding local client", remoteAddr: "127.0.0.1:51000", host: "example.com", forwarded: "127.0.0.1", trusted: []string{"127.0.0.1"}, want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nets, err := parseTrustedProxies(tt.trusted)
+			if err != nil {
+				t.Fatalf("parsing trusted proxies: %v", err)
+			}
+			trustedProxyNets = nets
+			noLocalhostBypass = tt.noBypass
+
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Host = tt.host
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.want {
+				t.Fatalf("expected status %d, got %d", tt.want, rr.Code)
+			}
+		})
+	}
+}
+
+func TestSessionLogin_CookieAndCSRF(t *testing.T) {
+	oldRequireAuth := requireAuth
+	oldAuthTokens := authTokens.Load()
+	oldSessionTTL := sessionTTL
+	t.Cleanup(func() {
+		requireAuth = oldRequireAuth
+		authTokens.Store(oldAuthTokens)
+		sessionTTL = oldSessionTTL
+	})
+
+	requireAuth = true
+	authTokens.Store(tokens.FromPlaintext("syntrack_token_valid"))
+	sessionTTL = time.Hour
+
+	render := func(w http.ResponseWriter, r *http.Request, page string, status int, data any) {
+		w.WriteHeader(status)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", makeLoginHandler(render))
+	mux.HandleFunc("/logout", handleLogout)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := tokenAuth(mux)
+
+	// A wrong token does not create a session
+	badReq := httptest.NewRequest(http.MethodPost, "http://example.com/login", strings.NewReader("token=syntrack_token_invalid"))
+	badReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	badRR := httptest.NewRecorder()
+	handler.ServeHTTP(badRR, badReq)
+	if badRR.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status 401 for invalid login, got %d", badRR.Code)
+	}
+	if len(badRR.Result().Cookies()) != 0 {
+		t.Fatal("expected no cookie for invalid login")
+	}
+
+	loginReq := httptest.NewRequest(http.MethodPost, "http://example.com/login", strings.NewReader("token=syntrack_token_valid&next=/stats"))
+	loginReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	loginRR := httptest.NewRecorder()
+	handler.ServeHTTP(loginRR, loginReq)
+
+	if loginRR.Code != http.StatusSeeOther {
+		t.Fatalf("expected status 303 after login, got %d", loginRR.Code)
+	}
+	if loc := loginRR.Header().Get("Location"); loc != "/stats" {
+		t.Fatalf("expected redirect to /stats, got %q", loc)
+	}
+	var cookie *http.Cookie
+	for _, c := range loginRR.Result().Cookies() {
+		if c.Name == sessionCookieName {
+			cookie = c
+		}
+	}
+	if cookie == nil {
+		t.Fatal("expected session cookie")
+	}
+	if !cookie.HttpOnly || cookie.SameSite != http.SameSiteStrictMode {
+		t.Fatalf("expected HttpOnly SameSite=Strict cookie, got %+v", cookie)
+	}
+
+	getReq := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	getReq.AddCookie(cookie)
+	getRR := httptest.NewRecorder()
+	handler.ServeHTTP(getRR, getReq)
+	if getRR.Code != http.StatusOK {
+		t.Fatalf("expected status 200 with session, got %d", getRR.Code)
+	}
+
+	postReq := httptest.NewRequest(http.MethodPost, "http://example.com/", nil)
+	postReq.AddCookie(cookie)
+	postRR := httptest.NewRecorder()
+	handler.ServeHTTP(postRR, postReq)
+	if postRR.Code != http.StatusForbidden {
+		t.Fatalf("expected status 403 for POST without CSRF token, got %d", postRR.Code)
+	}
+
+	sess, ok := sessions.get(cookie.Value)
+	if !ok {
+		t.Fatal("expected session to exist")
+	}
+	csrfReq := httptest.NewRequest(http.MethodPost, "http://example.com/", nil)
+	csrfReq.AddCookie(cookie)
+	csrfReq.Header.Set("X-CSRF-Token", sess.csrfToken)
+	csrfRR := httptest.NewRecorder()
+	handler.ServeHTTP(csrfRR, csrfReq)
+	if csrfRR.Code != http.StatusOK {
+		t.Fatalf("expected status 200 for POST with CSRF token, got %d", csrfRR.Code)
+	}
+
+	logoutReq := httptest.NewRequest(http.MethodPost, "http://example.com/logout", strings.NewReader("csrf_token="+sess.csrfToken))
+	logoutReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	logoutReq.AddCookie(cookie)
+	logoutRR := httptest.NewRecorder()
+	handler.ServeHTTP(logoutRR, logoutReq)
+	if logoutRR.Code != http.StatusSeeOther {
+		t.Fatalf("expected status 303 after logout, got %d", logoutRR.Code)
+	}
+
+	afterReq := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	afterReq.AddCookie(cookie)
+	afterRR := httptest.NewRecorder()
+	handler.ServeHTTP(afterRR, afterReq)
+	if afterRR.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status 401 after logout, got %d", afterRR.Code)
+	}
+}
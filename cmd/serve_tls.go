@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aure/syntrack/internal/config"
+)
+
+var tlsCert string
+var tlsKey string
+var tlsSelfSigned bool
+var httpRedirectPort int
+
+// certCheckInterval limits how often the certificate files are stat'ed to
+// notice a renewal.
+const certCheckInterval = 5 * time.Second
+
+// certReloader serves a certificate from disk and picks up replacements
+// (e.g. from certbot or a tailscale cert cron job) without a restart.
+type certReloader struct {
+	certPath string
+	keyPath  string
+
+	mu          sync.Mutex
+	cert        *tls.Certificate
+	certModTime time.Time
+	keyModTime  time.Time
+	lastCheck   time.Time
+}
+
+func newCertReloader(certPath, keyPath string) (*certReloader, error) {
+	r := &certReloader{certPath: certPath, keyPath: keyPath}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) load() error {
+	certInfo, err := os.Stat(r.certPath)
+	if err != nil {
+		return err
+	}
+	keyInfo, err := os.Stat(r.keyPath)
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certPath, r.keyPath)
+	if err != nil {
+		return fmt.Errorf("loading TLS key pair: %w", err)
+	}
+
+	r.cert = &cert
+	r.certModTime = certInfo.ModTime()
+	r.keyModTime = keyInfo.ModTime()
+	return nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate. If the files changed
+// but cannot be loaded (e.g. the key was written before the certificate),
+// the previous certificate keeps being served until the next check.
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.lastCheck) < certCheckInterval {
+		return r.cert, nil
+	}
+	r.lastCheck = time.Now()
+
+	certInfo, certErr := os.Stat(r.certPath)
+	keyInfo, keyErr := os.Stat(r.keyPath)
+	if certErr != nil || keyErr != nil {
+		return r.cert, nil
+	}
+	if certInfo.ModTime().Equal(r.certModTime) && keyInfo.ModTime().Equal(r.keyModTime) {
+		return r.cert, nil
+	}
+
+	previous := r.cert
+	if err := r.load(); err != nil {
+		slog.Warn("certificate changed but could not be reloaded", "err", err)
+		r.cert = previous
+		return r.cert, nil
+	}
+	slog.Info("reloaded TLS certificate", "path", r.certPath)
+	return r.cert, nil
+}
+
+// selfSignedPaths returns where the generated certificate is persisted.
+func selfSignedPaths() (string, string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", "", fmt.Errorf("getting home directory: %w", err)
+	}
+	tlsDir := filepath.Join(dir, "tls")
+	return filepath.Join(tlsDir, "cert.pem"), filepath.Join(tlsDir, "key.pem"), nil
+}
+
+// ensureSelfSignedCert reuses the persisted self-signed certificate if it is
+// still valid for the bind host, and generates a new one otherwise.
+func ensureSelfSignedCert(bindHost string) (string, string, error) {
+	certPath, keyPath, err := selfSignedPaths()
+	if err != nil {
+		return "", "", err
+	}
+
+	if cert, err := tls.LoadX509KeyPair(certPath, keyPath); err == nil {
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err == nil && time.Now().Add(7*24*time.Hour).Before(leaf.NotAfter) && certCoversHost(leaf, bindHost) {
+			return certPath, keyPath, nil
+		}
+	}
+
+	slog.Info("generating self-signed certificate", "dir", filepath.Dir(certPath))
+	if err := writeSelfSignedCert(certPath, keyPath, bindHost); err != nil {
+		return "", "", fmt.Errorf("generating self-signed certificate: %w", err)
+	}
+	return certPath, keyPath, nil
+}
+
+func certCoversHost(leaf *x509.Certificate, host string) bool {
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		return true
+	}
+	return leaf.VerifyHostname(host) == nil
+}
+
+func writeSelfSignedCert(certPath, keyPath, bindHost string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+
+	dnsNames := []string{"localhost"}
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		dnsNames = append(dnsNames, hostname)
+	}
+	ips := []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
+	if ip := net.ParseIP(bindHost); ip != nil && !ip.IsUnspecified() && !ip.IsLoopback() {
+		ips = append(ips, ip)
+	}
+	if ts := detectTailscaleIP(); ts != "" && ts != bindHost {
+		ips = append(ips, net.ParseIP(ts))
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "syntrack", Organization: []string{"syntrack self-signed"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              dnsNames,
+		IPAddresses:           ips,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(certPath), 0700); err != nil {
+		return err
+	}
+	// Write the key first: the reloader only reacts once both files are
+	// readable as a matching pair.
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return err
+	}
+	return os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+}
+
+// tlsEnabled reports whether serve should listen with HTTPS.
+func tlsEnabled() bool {
+	return tlsSelfSigned || tlsCert != "" || tlsKey != ""
+}
+
+// configureTLS resolves the certificate to use and returns a TLS config
+// that reloads it when the files change.
+func configureTLS(bindHost string) (*tls.Config, error) {
+	certPath, keyPath := tlsCert, tlsKey
+	if tlsSelfSigned {
+		if certPath != "" || keyPath != "" {
+			return nil, fmt.Errorf("--tls-self-signed cannot be combined with --tls-cert/--tls-key")
+		}
+		var err error
+		certPath, keyPath, err = ensureSelfSignedCert(bindHost)
+		if err != nil {
+			return nil, err
+		}
+	} else if certPath == "" || keyPath == "" {
+		return nil, fmt.Errorf("--tls-cert and --tls-key must be given together")
+	}
+
+	reloader, err := newCertReloader(certPath, keyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}, nil
+}
+
+// httpsRedirectHandler sends plain HTTP requests to the HTTPS port.
+func httpsRedirectHandler(httpsPort int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		target := "https://" + net.JoinHostPort(host, strconv.Itoa(httpsPort)) + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,259 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestPair writes a self-signed pair and moves its modification time
+// forward so the reloader sees a change even within the clock resolution.
+func writeTestPair(t *testing.T, certPath, keyPath string, age time.Duration) {
+	t.Helper()
+	if err := writeSelfSignedCert(certPath, keyPath, "127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	touch(t, age, certPath, keyPath)
+}
+
+func touch(t *testing.T, age time.Duration, paths ...string) {
+	t.Helper()
+	mtime := time.Now().Add(age)
+	for _, path := range paths {
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCertReloader_GetCertificate(t *testing.T) {
+	tests := []struct {
+		name     string
+		replace  func(t *testing.T, certPath, keyPath string)
+		wantSame bool
+	}{
+		{
+			name: "replaced pair",
+			replace: func(t *testing.T, certPath, keyPath string) {
+				writeTestPair(t, certPath, keyPath, time.Minute)
+			},
+		},
+		{
+			name: "broken certificate",
+			replace: func(t *testing.T, certPath, keyPath string) {
+				if err := os.WriteFile(certPath, []byte("not a certificate"), 0644); err != nil {
+					t.Fatal(err)
+				}
+				touch(t, time.Minute, certPath)
+			},
+			wantSame: true,
+		},
+		{
+			name: "key from another pair",
+			replace: func(t *testing.T, certPath, keyPath string) {
+				dir := t.TempDir()
+				writeTestPair(t, filepath.Join(dir, "cert.pem"), keyPath, time.Minute)
+			},
+			wantSame: true,
+		},
+		{
+			name: "missing files",
+			replace: func(t *testing.T, certPath, keyPath string) {
+				os.Remove(certPath)
+			},
+			wantSame: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			certPath, keyPath := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+			writeTestPair(t, certPath, keyPath, -time.Minute)
+
+			r, err := newCertReloader(certPath, keyPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			before, _ := r.GetCertificate(nil)
+
+			tt.replace(t, certPath, keyPath)
+			// Within the check interval the cached certificate is served
+			if cert, _ := r.GetCertificate(nil); cert != before {
+				t.Fatal("files were checked again within the check interval")
+			}
+			r.lastCheck = time.Time{}
+
+			after, err := r.GetCertificate(nil)
+			if err != nil || after == nil {
+				t.Fatalf("GetCertificate = %v, %v", after, err)
+			}
+			if same := bytes.Equal(after.Certificate[0], before.Certificate[0]); same != tt.wantSame {
+				t.Fatalf("same certificate after replacement = %v, want %v", same, tt.wantSame)
+			}
+		})
+	}
+}
+
+func TestEnsureSelfSignedCert(t *testing.T) {
+	tests := []struct {
+		name      string
+		setup     func(t *testing.T, certPath, keyPath string)
+		host      string
+		wantReuse bool
+	}{
+		{name: "valid for host", host: "127.0.0.1", wantReuse: true},
+		{name: "valid for all interfaces", host: "0.0.0.0", wantReuse: true},
+		{name: "other host", host: "192.0.2.10"},
+		{
+			name: "corrupt key",
+			host: "127.0.0.1",
+			setup: func(t *testing.T, certPath, keyPath string) {
+				if err := os.WriteFile(keyPath, []byte("garbage"), 0600); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOME", t.TempDir())
+			certPath, keyPath, err := ensureSelfSignedCert("127.0.0.1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.setup != nil {
+				tt.setup(t, certPath, keyPath)
+			}
+			before, _ := os.ReadFile(certPath)
+
+			if _, _, err := ensureSelfSignedCert(tt.host); err != nil {
+				t.Fatal(err)
+			}
+			after, _ := os.ReadFile(certPath)
+			if reused := bytes.Equal(before, after); reused != tt.wantReuse {
+				t.Fatalf("reused = %v, want %v", reused, tt.wantReuse)
+			}
+			if _, err := tls.LoadX509KeyPair(certPath, keyPath); err != nil {
+				t.Fatalf("resulting pair does not load: %v", err)
+			}
+		})
+	}
+}
+
+func TestCertCoversHost(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := writeSelfSignedCert(certPath, keyPath, "192.0.2.10"); err != nil {
+		t.Fatal(err)
+	}
+	pair, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"", true},
+		{"0.0.0.0", true},
+		{"::", true},
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"localhost", true},
+		{"192.0.2.10", true},
+		{"192.0.2.11", false},
+		{"example.com", false},
+	}
+	for _, tt := range tests {
+		if got := certCoversHost(leaf, tt.host); got != tt.want {
+			t.Errorf("certCoversHost(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestHTTPSRedirectHandler(t *testing.T) {
+	handler := httpsRedirectHandler(8443)
+
+	tests := []struct {
+		name   string
+		host   string
+		target string
+		want   string
+	}{
+		{name: "host with port", host: "example.com:8080", target: "/history?days=7", want: "https://example.com:8443/history?days=7"},
+		{name: "host without port", host: "example.com", target: "/", want: "https://example.com:8443/"},
+		{name: "ipv6 host", host: "[fd7a:115c::1]:8080", target: "/api/current", want: "https://[fd7a:115c::1]:8443/api/current"},
+		{name: "ip host", host: "100.64.0.1:80", target: "/login?next=%2F", want: "https://100.64.0.1:8443/login?next=%2F"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req.Host = tt.host
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusPermanentRedirect {
+				t.Fatalf("expected status %d, got %d", http.StatusPermanentRedirect, rr.Code)
+			}
+			if got := rr.Header().Get("Location"); got != tt.want {
+				t.Fatalf("Location = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigureTLS_InvalidFlags(t *testing.T) {
+	oldCert, oldKey, oldSelfSigned := tlsCert, tlsKey, tlsSelfSigned
+	t.Cleanup(func() {
+		tlsCert, tlsKey, tlsSelfSigned = oldCert, oldKey, oldSelfSigned
+	})
+
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeTestPair(t, certPath, keyPath, 0)
+
+	tests := []struct {
+		name       string
+		cert       string
+		key        string
+		selfSigned bool
+		wantErr    string
+	}{
+		{name: "self-signed with cert", cert: certPath, selfSigned: true, wantErr: "cannot be combined"},
+		{name: "self-signed with key", key: keyPath, selfSigned: true, wantErr: "cannot be combined"},
+		{name: "cert without key", cert: certPath, wantErr: "must be given together"},
+		{name: "key without cert", key: keyPath, wantErr: "must be given together"},
+		{name: "missing files", cert: filepath.Join(dir, "missing.pem"), key: keyPath, wantErr: "no such file"},
+		{name: "swapped files", cert: keyPath, key: certPath, wantErr: "loading TLS key pair"},
+		{name: "valid pair", cert: certPath, key: keyPath},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tlsCert, tlsKey, tlsSelfSigned = tt.cert, tt.key, tt.selfSigned
+
+			config, err := configureTLS("127.0.0.1")
+			if tt.wantErr == "" {
+				if err != nil || config == nil || config.GetCertificate == nil {
+					t.Fatalf("configureTLS = %v, %v", config, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// detachedProcAttr starts the background server in its own process group so
+// it does not receive signals (e.g. Ctrl-C) sent to the launching shell.
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setpgid: true}
+}
+
+// lockPIDFile takes an exclusive lock on the PID file without waiting and
+// reports false if another process holds it. The lock goes away with the
+// process, so a locked PID file always belongs to a running server.
+func lockPIDFile(f *os.File) (bool, error) {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func terminateProcess(pid int) error {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return process.Signal(syscall.SIGTERM)
+}
@@ -0,0 +1,39 @@
+//go:build windows
+
+package cmd
+
+import (
+	"errors"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// detachedProcAttr starts the background server in its own process group so
+// it does not receive Ctrl-C sent to the launching console.
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// lockPIDFile takes an exclusive lock on the PID file without waiting and
+// reports false if another process holds it. Windows locks are mandatory,
+// so the locked byte lies past the PID to keep the file readable.
+func lockPIDFile(f *os.File) (bool, error) {
+	ol := &windows.Overlapped{OffsetHigh: 1}
+	err := windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, ol)
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// terminateProcess kills the server; Windows has no SIGTERM to trigger a
+// graceful shutdown.
+func terminateProcess(pid int) error {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return process.Kill()
+}
@@ -56,6 +56,22 @@ var statsCmd = &cobra.Command{
 			fmt.Println("  Not enough data to calculate")
 		}
 
+		fmt.Println("\n🎯 Pace (current cycle)")
+		fmt.Println("─────────────────────")
+		pace, err := loadPace(database)
+		if err != nil {
+			return err
+		}
+		if pace != nil {
+			now := time.Now()
+			fmt.Printf("  Status:    %s (%.2fx)\n", pace.Status(), pace.Ratio())
+			fmt.Printf("  Ideal:     %.0f used by now, %d actual\n", pace.IdealUsed(pace.At), pace.Used)
+			fmt.Printf("  Today:     %d requests left to stay on pace\n", pace.TodayAllowance(now))
+			fmt.Printf("  Per day:   %.1f requests until %s\n", pace.DailyAllowance(now), pace.RenewsAt.Local().Format("2006-01-02 15:04"))
+		} else {
+			fmt.Println("  No renewal date recorded yet")
+		}
+
 		fmt.Println("\n📅 Daily Usage")
 		fmt.Println("─────────────────────")
 		daily, err := database.GetDailyUsage(7)
@@ -130,6 +146,18 @@ var statsCmd = &cobra.Command{
 			}
 		}
 
+		proxied, err := loadProxyBreakdown(database, proxyUsageDays)
+		if err != nil {
+			return err
+		}
+		if proxied.Requests > 0 {
+			fmt.Printf("\n👥 API Clients (proxy, last %d days)\n", proxyUsageDays)
+			fmt.Println("─────────────────────")
+			printProxyUsage("Client", proxied.Clients)
+			fmt.Println()
+			printProxyUsage("Model", proxied.Models)
+		}
+
 		if statsChart && len(snapshots) > 1 {
 			fmt.Println("\n📉 Usage Trend (last 7 days)")
 			fmt.Println("─────────────────────")
@@ -144,6 +172,13 @@ var statsCmd = &cobra.Command{
 	},
 }
 
+func printProxyUsage(title string, entries []ProxyUsageEntry) {
+	fmt.Printf("%-24s %8s %7s %7s %8s\n", title, "Requests", "Share", "Errors", "Avg ms")
+	for _, e := range entries {
+		fmt.Printf("%-24s %8d %6.1f%% %7d %8.0f\n", truncateRunes(e.Name, 24), e.Requests, e.SharePercent, e.Errors, e.AvgLatencyMs)
+	}
+}
+
 func printMiniBar(used, total, width int) {
 	pct := float64(used) / float64(total)
 	filled := int(pct * float64(width))
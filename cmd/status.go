@@ -1,16 +1,54 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
 
+	"github.com/aure/syntrack/internal/config"
 	"github.com/aure/syntrack/internal/db"
 	"github.com/spf13/cobra"
 )
 
+var statusFormat string
+var statusPreset string
+var statusWarn float64
+var statusCrit float64
+var statusCacheTTL time.Duration
+
 var statusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Show current usage status",
+	Long: `Show current usage status.
+
+--format renders a Go template over the current status, for shell prompts
+and status bars. Fields: .Timestamp .Limit .Used .Leftover .UsagePercent
+.RenewsAt .TimeUntilRenew .Class (ok, warning, critical or unknown).
+
+--preset prints ready-made output for status bars: waybar (JSON),
+i3blocks (JSON, set format=json in the block) or polybar (text with
+color tags). The class follows --warn and --crit (percent used). It
+cannot be combined with --format.
+
+Templated and preset output is served from ~/.syntrack/status-cache.json
+while the database has not changed, so frequent prompt invocations do
+not open SQLite.
+
+Examples:
+  syntrack status --format '{{.Leftover}}/{{.Limit}} {{.TimeUntilRenew}}'
+  syntrack status --preset waybar --warn 70 --crit 90`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if statusFormat != "" && statusPreset != "" {
+			return fmt.Errorf("--format and --preset cannot be combined")
+		}
+		if statusFormat != "" || statusPreset != "" {
+			return printFormattedStatus()
+		}
+
 		database, err := db.New(dbPath)
 		if err != nil {
 			return fmt.Errorf("opening database: %w", err)
@@ -43,6 +81,187 @@ var statusCmd = &cobra.Command{
 	},
 }
 
+// statusView is the data available to --format templates.
+type statusView struct {
+	CurrentStatus
+	Class string
+}
+
+func printFormattedStatus() error {
+	status, err := cachedCurrentStatus()
+	if err != nil {
+		return err
+	}
+
+	view := statusView{Class: "unknown"}
+	if status != nil {
+		view.CurrentStatus = *status
+		// The cache may be older than a minute; recompute the countdown
+		if status.RenewsAt != nil {
+			if renewsAt, err := time.Parse(time.RFC3339, *status.RenewsAt); err == nil {
+				view.TimeUntilRenew = time.Until(renewsAt).Round(time.Minute).String()
+			}
+		}
+		view.Class = statusClass(status.UsagePercent)
+	}
+
+	if statusPreset != "" {
+		out, err := renderStatusPreset(statusPreset, view, status != nil)
+		if err != nil {
+			return err
+		}
+		fmt.Println(out)
+		return nil
+	}
+
+	tmpl, err := template.New("status").Parse(statusFormat)
+	if err != nil {
+		return fmt.Errorf("parsing --format template: %w", err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, view); err != nil {
+		return fmt.Errorf("rendering --format template: %w", err)
+	}
+	fmt.Println(b.String())
+	return nil
+}
+
+func statusClass(usagePercent float64) string {
+	switch {
+	case usagePercent >= statusCrit:
+		return "critical"
+	case usagePercent >= statusWarn:
+		return "warning"
+	default:
+		return "ok"
+	}
+}
+
+var statusColors = map[string]string{
+	"ok":       "#a6e3a1",
+	"warning":  "#f9e2af",
+	"critical": "#f38ba8",
+	"unknown":  "#9399b2",
+}
+
+func renderStatusPreset(preset string, v statusView, hasData bool) (string, error) {
+	text := "n/a"
+	tooltip := "No data collected yet"
+	if hasData {
+		text = fmt.Sprintf("%d/%d", v.Leftover, v.Limit)
+		tooltip = fmt.Sprintf("%d of %d requests used (%.1f%%), %d left", v.Used, v.Limit, v.UsagePercent, v.Leftover)
+		if v.TimeUntilRenew != "" {
+			tooltip += ", renews in " + v.TimeUntilRenew
+		}
+	}
+
+	switch preset {
+	case "waybar":
+		out, err := json.Marshal(map[string]any{
+			"text":       text,
+			"tooltip":    tooltip,
+			"class":      v.Class,
+			"alt":        v.Class,
+			"percentage": int(v.UsagePercent + 0.5),
+		})
+		return string(out), err
+	case "i3blocks":
+		out, err := json.Marshal(map[string]any{
+			"full_text":  text + " left",
+			"short_text": text,
+			"color":      statusColors[v.Class],
+		})
+		return string(out), err
+	case "polybar":
+		return fmt.Sprintf("%%{F%s}%s%%{F-}", statusColors[v.Class], text), nil
+	default:
+		return "", fmt.Errorf("unknown preset: %s (valid: waybar, i3blocks, polybar)", preset)
+	}
+}
+
+// statusCache lets prompt invocations skip SQLite while the database file
+// is unchanged.
+type statusCache struct {
+	DBPath    string         `json:"db_path"`
+	DBModTime time.Time      `json:"db_mod_time"`
+	CachedAt  time.Time      `json:"cached_at"`
+	Status    *CurrentStatus `json:"status"`
+}
+
+func statusCachePath() (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", fmt.Errorf("getting home directory: %w", err)
+	}
+	return filepath.Join(dir, "status-cache.json"), nil
+}
+
+// dbModTime returns the newest modification time of the database and its
+// write-ahead log.
+func dbModTime(path string) time.Time {
+	var latest time.Time
+	for _, p := range []string{path, path + "-wal"} {
+		if info, err := os.Stat(p); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+// cachedCurrentStatus returns the latest status, from the cache file when
+// it matches the database and is younger than --cache-ttl. A nil status
+// means no data has been collected.
+func cachedCurrentStatus() (*CurrentStatus, error) {
+	absDB, err := filepath.Abs(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	modTime := dbModTime(absDB)
+
+	cachePath, cacheErr := statusCachePath()
+	if cacheErr == nil && statusCacheTTL > 0 {
+		if data, err := os.ReadFile(cachePath); err == nil {
+			var c statusCache
+			if json.Unmarshal(data, &c) == nil && c.DBPath == absDB && c.DBModTime.Equal(modTime) && time.Since(c.CachedAt) < statusCacheTTL {
+				return c.Status, nil
+			}
+		}
+	}
+
+	database, err := db.New(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("opening database: %w", err)
+	}
+	defer database.Close()
+
+	snapshot, err := database.GetLatestSnapshot()
+	if err != nil {
+		return nil, fmt.Errorf("getting latest snapshot: %w", err)
+	}
+	var status *CurrentStatus
+	if snapshot != nil {
+		s := newCurrentStatus(snapshot)
+		status = &s
+	}
+
+	// A failed cache write only costs speed on the next call
+	if cacheErr == nil && statusCacheTTL > 0 {
+		data, err := json.Marshal(statusCache{DBPath: absDB, DBModTime: dbModTime(absDB), CachedAt: time.Now(), Status: status})
+		if err == nil && os.MkdirAll(filepath.Dir(cachePath), 0700) == nil {
+			tmp := cachePath + ".tmp"
+			if os.WriteFile(tmp, data, 0600) == nil {
+				os.Rename(tmp, cachePath)
+			}
+		}
+	}
+	return status, nil
+}
+
 func init() {
+	statusCmd.Flags().StringVarP(&statusFormat, "format", "f", "", "Go template for one-line output, e.g. '{{.Leftover}}/{{.Limit}}'")
+	statusCmd.Flags().StringVar(&statusPreset, "preset", "", "Status bar output: waybar, i3blocks or polybar")
+	statusCmd.Flags().Float64Var(&statusWarn, "warn", 75, "Usage percent at which the class becomes warning")
+	statusCmd.Flags().Float64Var(&statusCrit, "crit", 90, "Usage percent at which the class becomes critical")
+	statusCmd.Flags().DurationVar(&statusCacheTTL, "cache-ttl", time.Minute, "Reuse cached status for templated output up to this age (0 disables)")
 	rootCmd.AddCommand(statusCmd)
 }
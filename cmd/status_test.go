@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatusClass(t *testing.T) {
+	oldWarn, oldCrit := statusWarn, statusCrit
+	t.Cleanup(func() { statusWarn, statusCrit = oldWarn, oldCrit })
+	statusWarn, statusCrit = 75, 90
+
+	tests := []struct {
+		percent float64
+		want    string
+	}{
+		{0, "ok"},
+		{74.9, "ok"},
+		{75, "warning"},
+		{89.9, "warning"},
+		{90, "critical"},
+		{120, "critical"},
+	}
+	for _, tt := range tests {
+		if got := statusClass(tt.percent); got != tt.want {
+			t.Errorf("statusClass(%v) = %q, want %q", tt.percent, got, tt.want)
+		}
+	}
+}
+
+func TestRenderStatusPreset(t *testing.T) {
+	view := statusView{
+		CurrentStatus: CurrentStatus{Limit: 135, Used: 89, Leftover: 46, UsagePercent: 65.9, TimeUntilRenew: "2h0m0s"},
+		Class:         "warning",
+	}
+
+	tests := []struct {
+		name    string
+		preset  string
+		view    statusView
+		hasData bool
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "waybar", preset: "waybar", view: view, hasData: true,
+			want: `{"alt":"warning","class":"warning","percentage":66,"text":"46/135","tooltip":"89 of 135 requests used (65.9%), 46 left, renews in 2h0m0s"}`,
+		},
+		{
+			name: "waybar without data", preset: "waybar", view: statusView{Class: "unknown"},
+			want: `{"alt":"unknown","class":"unknown","percentage":0,"text":"n/a","tooltip":"No data collected yet"}`,
+		},
+		{
+			name: "i3blocks", preset: "i3blocks", view: view, hasData: true,
+			want: `{"color":"#f9e2af","full_text":"46/135 left","short_text":"46/135"}`,
+		},
+		{
+			name: "polybar", preset: "polybar", view: view, hasData: true,
+			want: `%{F#f9e2af}46/135%{F-}`,
+		},
+		{
+			name: "polybar critical", preset: "polybar", view: statusView{CurrentStatus: CurrentStatus{Limit: 100, Leftover: 3}, Class: "critical"}, hasData: true,
+			want: `%{F#f38ba8}3/100%{F-}`,
+		},
+		{name: "unknown preset", preset: "tmux", view: view, hasData: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderStatusPreset(tt.preset, tt.view, tt.hasData)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCachedCurrentStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		ttl     time.Duration
+		change  func(t *testing.T, cache *statusCache, dbPath string)
+		wantHit bool
+	}{
+		{name: "fresh cache", ttl: time.Minute, wantHit: true},
+		{
+			name: "expired cache", ttl: time.Minute,
+			change: func(t *testing.T, cache *statusCache, dbPath string) {
+				cache.CachedAt = time.Now().Add(-2 * time.Minute)
+			},
+		},
+		{
+			name: "database changed", ttl: time.Minute,
+			change: func(t *testing.T, cache *statusCache, dbPath string) {
+				touch(t, time.Minute, dbPath)
+			},
+		},
+		{
+			name: "other database", ttl: time.Minute,
+			change: func(t *testing.T, cache *statusCache, dbPath string) {
+				cache.DBPath += ".other"
+			},
+		},
+		{name: "cache disabled", ttl: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := useTestDB(t, 40)
+			oldTTL := statusCacheTTL
+			t.Cleanup(func() { statusCacheTTL = oldTTL })
+			statusCacheTTL = time.Minute
+
+			status, err := cachedCurrentStatus()
+			if err != nil || status == nil || status.Used != 40 {
+				t.Fatalf("first call = %+v, %v", status, err)
+			}
+
+			// Mark the cached status so a cache hit is recognisable
+			cachePath, err := statusCachePath()
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(cachePath)
+			if err != nil {
+				t.Fatalf("cache not written: %v", err)
+			}
+			var cache statusCache
+			if err := json.Unmarshal(data, &cache); err != nil {
+				t.Fatal(err)
+			}
+			cache.Status.Used = 999
+			if tt.change != nil {
+				tt.change(t, &cache, path)
+			}
+			data, _ = json.Marshal(cache)
+			if err := os.WriteFile(cachePath, data, 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			statusCacheTTL = tt.ttl
+			status, err = cachedCurrentStatus()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hit := status.Used == 999; hit != tt.wantHit {
+				t.Fatalf("cache hit = %v, want %v", hit, tt.wantHit)
+			}
+		})
+	}
+}
+
+func TestStatus_PresetWithFormat(t *testing.T) {
+	oldFormat, oldPreset := statusFormat, statusPreset
+	t.Cleanup(func() { statusFormat, statusPreset = oldFormat, oldPreset })
+	statusFormat, statusPreset = "{{.Leftover}}", "waybar"
+
+	err := statusCmd.RunE(statusCmd, nil)
+	if err == nil || !strings.Contains(err.Error(), "cannot be combined") {
+		t.Fatalf("err = %v, want the combination rejected", err)
+	}
+}
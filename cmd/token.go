@@ -1,95 +1,227 @@
 package cmd
 
 import (
-	"crypto/rand"
-	"encoding/hex"
 	"fmt"
 	"os"
-	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
 
+	"github.com/aure/syntrack/internal/tokens"
 	"github.com/spf13/cobra"
 )
 
 var saveToken bool
+var tokenLabel string
+var tokenExpires string
 
 var tokenCmd = &cobra.Command{
 	Use:   "token",
 	Short: "Manage authentication tokens",
-	Long:  `Generate and manage authentication tokens for secure API access.`,
+	Long: `Generate and manage authentication tokens for secure API access.
+
+Saved tokens live in the token file (token_file in the config, default
+~/.syntrack/tokens). Only a SHA-256 hash of each token is
+stored, so a token is shown exactly once, when it is generated or rotated.
+Token files from older versions (one plaintext token per line) are converted
+automatically the first time they are read.`,
 }
 
 var tokenGenerateCmd = &cobra.Command{
 	Use:   "generate",
 	Short: "Generate a new authentication token",
 	Long: `Generate a secure random token for authentication.
-	
-The token will be printed to stdout. Use --save to store it in ~/.syntrack/tokens.
-Generated tokens are 32 bytes (64 hex characters) for strong security.`,
+
+The token will be printed to stdout. Use --save to store its hash in the token file.
+Generated tokens are 32 bytes (64 hex characters) for strong security.
+
+Examples:
+  syntrack token generate --save --label laptop
+  syntrack token generate --save --label ci --expires 30d`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		token, err := generateSecureToken()
+		ttl, err := parseLifetime(tokenExpires)
 		if err != nil {
-			return fmt.Errorf("generating token: %w", err)
+			return err
+		}
+		if !saveToken && (tokenLabel != "" || ttl > 0) {
+			return fmt.Errorf("--label and --expires require --save")
 		}
 
-		fmt.Println("Generated token:")
-		fmt.Println(token)
-		fmt.Println()
-
+		var token string
 		if saveToken {
-			if err := saveTokenToFile(token); err != nil {
+			store, err := loadTokenStore()
+			if err != nil {
+				return err
+			}
+			var saved *tokens.Token
+			token, saved, err = store.Generate(tokenLabel, ttl)
+			if err != nil {
 				return fmt.Errorf("saving token: %w", err)
 			}
-			fmt.Println("Token saved to ~/.syntrack/tokens")
+			fmt.Println("Generated token:")
+			fmt.Println(token)
+			fmt.Println()
+			fmt.Printf("Token saved to %s (ID %s", cfg.TokenFile, saved.ID)
+			if saved.ExpiresAt != nil {
+				fmt.Printf(", expires %s", saved.ExpiresAt.Local().Format("2006-01-02 15:04"))
+			}
+			fmt.Println(")")
+			fmt.Println("Only a hash is stored; copy the token now, it cannot be shown again.")
+			fmt.Println()
+		} else {
+			token, err = tokens.NewSecret()
+			if err != nil {
+				return fmt.Errorf("generating token: %w", err)
+			}
+			fmt.Println("Generated token:")
+			fmt.Println(token)
+			fmt.Println()
 		}
 
 		fmt.Println("Usage:")
 		fmt.Println("  - Set environment variable: export SYNTRACK_AUTH_TOKENS=" + token)
-		fmt.Println("  - Or use the saved token file at ~/.syntrack/tokens")
+		fmt.Println("  - Or use the saved token file at " + cfg.TokenFile)
 		fmt.Println("  - Include in requests: X-Auth-Token: <token>")
 		fmt.Println()
-		fmt.Println("Note: Localhost requests (127.0.0.1, ::1) are always allowed without token.")
+		fmt.Println("Note: Localhost requests (127.0.0.1, ::1) are allowed without token unless serve runs with --no-localhost-bypass.")
 
 		return nil
 	},
 }
 
-func generateSecureToken() (string, error) {
-	bytes := make([]byte, 32)
-	if _, err := rand.Read(bytes); err != nil {
-		return "", err
-	}
-	return "syntrack_token_" + hex.EncodeToString(bytes), nil
+var tokenListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List saved tokens",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := loadTokenStore()
+		if err != nil {
+			return err
+		}
+
+		list := store.List()
+		if len(list) == 0 {
+			fmt.Println("No saved tokens. Run 'syntrack token generate --save' to create one.")
+			return nil
+		}
+
+		now := time.Now()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tLABEL\tCREATED\tEXPIRES\tLAST USED\tSTATUS")
+		for _, t := range list {
+			label := t.Label
+			if label == "" {
+				label = "-"
+			}
+			status := "active"
+			if t.Expired(now) {
+				status = "expired"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				t.ID, label, formatTokenTime(&t.CreatedAt), formatTokenTime(t.ExpiresAt), formatTokenTime(t.LastUsed), status)
+		}
+		return w.Flush()
+	},
 }
 
-func saveTokenToFile(token string) error {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return fmt.Errorf("getting home directory: %w", err)
-	}
+var tokenRevokeCmd = &cobra.Command{
+	Use:   "revoke <id|label>",
+	Short: "Revoke a saved token",
+	Long: `Remove a saved token so it is no longer accepted.
 
-	syntrackDir := filepath.Join(homeDir, ".syntrack")
-	if err := os.MkdirAll(syntrackDir, 0700); err != nil {
-		return fmt.Errorf("creating .syntrack directory: %w", err)
-	}
+A running server reloads the token file when it changes, or on SIGHUP, so
+the token stops working without a restart.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := loadTokenStore()
+		if err != nil {
+			return err
+		}
+
+		revoked, err := store.Revoke(args[0])
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Revoked token %s", revoked.ID)
+		if revoked.Label != "" {
+			fmt.Printf(" (%s)", revoked.Label)
+		}
+		fmt.Println()
+		return nil
+	},
+}
+
+var tokenRotateCmd = &cobra.Command{
+	Use:   "rotate <id|label>",
+	Short: "Replace a saved token with a new secret",
+	Long: `Replace the secret of a saved token, keeping its ID and label.
+
+The old secret stops working immediately. A token with an expiry date gets
+the same lifetime again, counted from now.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := loadTokenStore()
+		if err != nil {
+			return err
+		}
+
+		token, rotated, err := store.Rotate(args[0])
+		if err != nil {
+			return err
+		}
 
-	tokenFile := filepath.Join(syntrackDir, "tokens")
+		fmt.Printf("Rotated token %s. New token:\n", rotated.ID)
+		fmt.Println(token)
+		if rotated.ExpiresAt != nil {
+			fmt.Printf("Expires %s\n", rotated.ExpiresAt.Local().Format("2006-01-02 15:04"))
+		}
+		return nil
+	},
+}
 
-	var content string
-	if existing, err := os.ReadFile(tokenFile); err == nil && len(existing) > 0 {
-		content = string(existing) + "\n" + token + "\n"
-	} else {
-		content = token + "\n"
+func loadTokenStore() (*tokens.Store, error) {
+	store, err := tokens.Load(cfg.TokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading token file: %w", err)
 	}
+	return store, nil
+}
 
-	if err := os.WriteFile(tokenFile, []byte(content), 0600); err != nil {
-		return fmt.Errorf("writing token file: %w", err)
+// parseLifetime accepts Go durations plus a "d" suffix for days (e.g. 30d).
+// An empty string means no expiry.
+func parseLifetime(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid lifetime %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
 	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid lifetime %q", s)
+	}
+	return d, nil
+}
 
-	return nil
+func formatTokenTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04")
 }
 
 func init() {
-	tokenGenerateCmd.Flags().BoolVarP(&saveToken, "save", "s", false, "Save token to ~/.syntrack/tokens")
+	tokenGenerateCmd.Flags().BoolVarP(&saveToken, "save", "s", false, "Save token hash to the token file (default ~/.syntrack/tokens)")
+	tokenGenerateCmd.Flags().StringVarP(&tokenLabel, "label", "l", "", "Label for the saved token (e.g. laptop, ci)")
+	tokenGenerateCmd.Flags().StringVarP(&tokenExpires, "expires", "e", "", "Token lifetime, e.g. 30d or 12h (default: never expires)")
 	tokenCmd.AddCommand(tokenGenerateCmd)
+	tokenCmd.AddCommand(tokenListCmd)
+	tokenCmd.AddCommand(tokenRevokeCmd)
+	tokenCmd.AddCommand(tokenRotateCmd)
 	rootCmd.AddCommand(tokenCmd)
 }
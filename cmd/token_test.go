@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aure/syntrack/internal/tokens"
+)
+
+func TestTokenRevoke_ConfiguredTokenFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "tokens")
+	configFile := filepath.Join(dir, "syntrack.yaml")
+	if err := os.WriteFile(configFile, []byte("token_file: "+tokenFile+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	oldCfg, oldCfgFile := cfg, cfgFile
+	t.Cleanup(func() { cfg, cfgFile = oldCfg, oldCfgFile })
+	cfgFile = configFile
+	loaded, err := reloadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg = loaded
+
+	secret, _, err := tokens.New(tokenFile).Generate("ci", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify := func() bool {
+		t.Helper()
+		store, err := cfg.LoadAuthTokens()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, ok := store.Verify(secret)
+		return ok
+	}
+	if !verify() {
+		t.Fatal("generated token not accepted before revoke")
+	}
+
+	if err := tokenRevokeCmd.RunE(tokenRevokeCmd, []string{"ci"}); err != nil {
+		t.Fatal(err)
+	}
+	if verify() {
+		t.Fatal("revoked token still accepted from the configured token file")
+	}
+	if _, err := os.Stat(filepath.Join(home, ".syntrack", "tokens")); !os.IsNotExist(err) {
+		t.Fatalf("default token file was written: %v", err)
+	}
+}
@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aure/syntrack/internal/db"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var topInterval time.Duration
+var topRange string
+
+var topCmd = &cobra.Command{
+	Use:   "top",
+	Short: "Interactive full-screen dashboard",
+	Long: `Show a live terminal dashboard with the current status, burn rate, a
+usage chart, daily consumption and the latest snapshots. The screen refreshes
+as soon as 'syntrack collect' writes a new snapshot.
+
+Keys:
+  1 / 2 / 3   Range: last 24h, last 7 days, current quota cycle
+  v / Tab     Switch view: overview, chart, snapshots
+  r           Refresh now
+  q / Esc     Quit`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		in, out := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+		if !term.IsTerminal(in) || !term.IsTerminal(out) {
+			return fmt.Errorf("syntrack top needs an interactive terminal; use 'syntrack stats' or 'syntrack query' instead")
+		}
+
+		state := topState{}
+		for i, r := range topRanges {
+			if r == topRange {
+				state.rangeIdx = i
+			}
+		}
+		if topRanges[state.rangeIdx] != topRange {
+			return fmt.Errorf("unknown range: %s (valid: %s)", topRange, strings.Join(topRanges, ", "))
+		}
+
+		database, err := db.New(dbPath)
+		if err != nil {
+			return fmt.Errorf("opening database: %w", err)
+		}
+		defer database.Close()
+
+		oldState, err := term.MakeRaw(in)
+		if err != nil {
+			return fmt.Errorf("setting terminal mode: %w", err)
+		}
+		defer term.Restore(in, oldState)
+
+		// Alternate screen, hidden cursor; restored on exit
+		fmt.Print("\033[?1049h\033[?25l")
+		defer fmt.Print("\033[?25h\033[?1049l")
+
+		return runTop(database, state, in, out)
+	},
+}
+
+func runTop(database *db.DB, state topState, in, out int) error {
+	keys := make(chan byte, 16)
+	go readKeys(keys)
+
+	ticker := time.NewTicker(topInterval)
+	defer ticker.Stop()
+
+	var data topData
+	var lastID int64 = -1
+	reload := true
+	for {
+		if id, err := database.LatestSnapshotID(); err == nil && id != lastID {
+			lastID = id
+			reload = true
+		}
+		if reload {
+			d, err := loadTopData(database, topRanges[state.rangeIdx])
+			if err != nil {
+				return err
+			}
+			data = d
+			reload = false
+		}
+
+		width, height, err := term.GetSize(out)
+		if err != nil {
+			width, height = 80, 24
+		}
+		state.width, state.height = width, height
+		drawTop(renderTop(state, data, time.Now()))
+
+		select {
+		case <-ticker.C:
+		case k, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			switch k {
+			case 'q', 'Q', 3, 27: // Ctrl-C, Esc
+				return nil
+			case '1', '2', '3':
+				state.rangeIdx = int(k - '1')
+				reload = true
+			case 'v', 'V', '\t':
+				state.view = (state.view + 1) % topViewCount
+			case 'r', 'R':
+				reload = true
+			}
+		}
+	}
+}
+
+// readKeys forwards single bytes from stdin. Escape sequences (arrow keys)
+// arrive as separate bytes; only a lone Esc quits because the rest of the
+// sequence is not a known key.
+func readKeys(keys chan<- byte) {
+	defer close(keys)
+	buf := make([]byte, 16)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return
+		}
+		if n > 1 && buf[0] == 27 {
+			continue
+		}
+		for _, b := range buf[:n] {
+			keys <- b
+		}
+	}
+}
+
+func drawTop(lines []string) {
+	var b strings.Builder
+	b.WriteString("\033[H")
+	for i, l := range lines {
+		b.WriteString(l)
+		b.WriteString("\033[K")
+		if i < len(lines)-1 {
+			b.WriteString("\r\n")
+		}
+	}
+	b.WriteString("\033[J")
+	os.Stdout.WriteString(b.String())
+}
+
+func loadTopData(database *db.DB, rangeName string) (topData, error) {
+	var data topData
+
+	latest, err := database.GetLatestSnapshot()
+	if err != nil {
+		return data, fmt.Errorf("getting latest snapshot: %w", err)
+	}
+	data.latest = latest
+	if latest == nil {
+		return data, nil
+	}
+
+	now := time.Now()
+	switch rangeName {
+	case "7d":
+		data.since = now.AddDate(0, 0, -7)
+	case "cycle":
+		data.since = now.AddDate(0, 0, -30)
+		cycle, err := database.GetCurrentCycle()
+		if err != nil {
+			return data, fmt.Errorf("getting current cycle: %w", err)
+		}
+		if cycle != nil {
+			data.since = cycle.Start
+		}
+	default:
+		data.since = now.Add(-24 * time.Hour)
+	}
+
+	if data.snapshots, err = database.GetSnapshots(data.since); err != nil {
+		return data, fmt.Errorf("getting snapshots: %w", err)
+	}
+	if data.burnRate, err = database.GetBurnRate(24); err != nil {
+		return data, fmt.Errorf("calculating burn rate: %w", err)
+	}
+	if data.daily, err = database.GetDailyUsage(7); err != nil {
+		return data, fmt.Errorf("getting daily usage: %w", err)
+	}
+	return data, nil
+}
+
+func init() {
+	topCmd.Flags().DurationVarP(&topInterval, "interval", "i", 2*time.Second, "How often to check the database for new snapshots")
+	topCmd.Flags().StringVarP(&topRange, "range", "r", "24h", "Initial range (24h, 7d, cycle)")
+	rootCmd.AddCommand(topCmd)
+}
@@ -0,0 +1,309 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aure/syntrack/internal/db"
+)
+
+// Views and ranges of 'syntrack top'.
+const (
+	topViewOverview = iota
+	topViewChart
+	topViewSnapshots
+	topViewCount
+)
+
+var topViewNames = []string{"overview", "chart", "snapshots"}
+var topRanges = []string{"24h", "7d", "cycle"}
+
+// Below these sizes the overview drops the chart and tables.
+const (
+	topMinChartWidth  = 40
+	topMinChartHeight = 18
+)
+
+type topState struct {
+	rangeIdx int
+	view     int
+	width    int
+	height   int
+}
+
+type topData struct {
+	latest    *db.UsageSnapshot
+	burnRate  float64
+	since     time.Time
+	snapshots []db.UsageSnapshot
+	daily     []db.DailyUsage
+}
+
+// renderTop lays out one screen. Every line is at most state.width
+// characters wide and at most state.height lines are returned.
+func renderTop(state topState, data topData, now time.Time) []string {
+	var lines []string
+	title := fmt.Sprintf(" syntrack top · %s · %s", topRanges[state.rangeIdx], topViewNames[state.view])
+	lines = append(lines, padBetween(title, now.Format("15:04:05")+" ", state.width))
+	lines = append(lines, " "+strings.Repeat("─", max(state.width-2, 0)))
+
+	if data.latest == nil {
+		lines = append(lines, " No data available. Run 'syntrack collect' first.")
+		return fitScreen(lines, topFooter(state.width), state)
+	}
+
+	// Leave room for the footer and a blank line above it
+	body := state.height - len(lines) - 2
+
+	switch state.view {
+	case topViewChart:
+		lines = append(lines, topStatusLines(data, now, state.width, true)...)
+		lines = append(lines, "")
+		// The chart's time axis takes one more line
+		lines = append(lines, renderTopChart(data, state.width, state.height-len(lines)-2)...)
+	case topViewSnapshots:
+		lines = append(lines, renderTopSnapshots(data.snapshots, state.width, body)...)
+	default:
+		lines = append(lines, topStatusLines(data, now, state.width, false)...)
+		if state.width < topMinChartWidth || state.height < topMinChartHeight {
+			break
+		}
+
+		remaining := body - 4
+		chartHeight := max(4, remaining/2)
+		lines = append(lines, "", fmt.Sprintf(" Leftover (%s)", topRanges[state.rangeIdx]))
+		lines = append(lines, renderTopChart(data, state.width, chartHeight)...)
+		remaining -= chartHeight + 2
+
+		if remaining >= 3 && len(data.daily) > 0 {
+			n := min(len(data.daily), remaining-2)
+			lines = append(lines, "", " Daily consumption")
+			lines = append(lines, renderTopBars(data.daily[:n], state.width)...)
+			remaining -= n + 2
+		}
+		if remaining >= 4 {
+			lines = append(lines, "")
+			lines = append(lines, renderTopSnapshots(data.snapshots, state.width, remaining-1)...)
+		}
+	}
+
+	return fitScreen(lines, topFooter(state.width), state)
+}
+
+func topStatusLines(data topData, now time.Time, width int, compact bool) []string {
+	s := data.latest
+	pct := 0.0
+	if s.SubscriptionLimit > 0 {
+		pct = float64(s.RequestsUsed) / float64(s.SubscriptionLimit) * 100
+	}
+
+	lines := []string{fmt.Sprintf(" Used      %d / %d (%.1f%%)", s.RequestsUsed, s.SubscriptionLimit, pct)}
+	if !compact && width >= 50 {
+		barWidth := min(width-16, 40)
+		filled := int(pct / 100 * float64(barWidth))
+		filled = max(0, min(filled, barWidth))
+		lines = append(lines, " "+strings.Repeat(" ", 9)+"["+strings.Repeat("█", filled)+strings.Repeat("░", barWidth-filled)+"]")
+	}
+
+	left := fmt.Sprintf(" Leftover  %d", s.Leftover)
+	if s.RenewsAt != nil {
+		left += fmt.Sprintf("   Renews in %s", formatDurationShort(s.RenewsAt.Sub(now)))
+	}
+	lines = append(lines, left)
+
+	if data.burnRate > 0 {
+		lines = append(lines, fmt.Sprintf(" Burn      %.2f req/h   Est. left %.1fh", data.burnRate, float64(s.Leftover)/data.burnRate))
+	} else {
+		lines = append(lines, " Burn      not enough data")
+	}
+	if !compact {
+		lines = append(lines, fmt.Sprintf(" Updated   %s", s.CollectedAt.Local().Format("2006-01-02 15:04")))
+	}
+	return lines
+}
+
+// renderTopChart draws leftover requests over the selected range as an
+// area chart, resampled to the available width.
+func renderTopChart(data topData, width, height int) []string {
+	const axis = 7 // "  135 │"
+	cols := width - axis - 1
+	if cols < 10 || height < 2 {
+		return nil
+	}
+	if len(data.snapshots) < 2 {
+		return []string{" Need at least 2 snapshots in this range."}
+	}
+
+	limit := data.latest.SubscriptionLimit
+	for _, s := range data.snapshots {
+		limit = max(limit, s.SubscriptionLimit, s.Leftover)
+	}
+	if limit <= 0 {
+		limit = 1
+	}
+
+	values := resampleLeftover(data.snapshots, data.since, data.latest.CollectedAt, cols)
+
+	lines := make([]string, 0, height+1)
+	for row := height; row >= 1; row-- {
+		label := "     "
+		switch row {
+		case height:
+			label = fmt.Sprintf("%5d", limit)
+		case 1:
+			label = fmt.Sprintf("%5d", 0)
+		}
+
+		var b strings.Builder
+		b.WriteString(" " + label + " │")
+		for _, v := range values {
+			level := float64(v) / float64(limit) * float64(height)
+			switch {
+			case v < 0:
+				b.WriteRune(' ')
+			case level >= float64(row):
+				b.WriteRune('█')
+			case level > float64(row-1)+0.5:
+				b.WriteRune('▄')
+			default:
+				b.WriteRune(' ')
+			}
+		}
+		lines = append(lines, b.String())
+	}
+	lines = append(lines, padBetween(" "+strings.Repeat(" ", axis-1)+data.since.Local().Format("01-02 15:04"), data.latest.CollectedAt.Local().Format("01-02 15:04"), width))
+	return lines
+}
+
+// resampleLeftover returns the leftover at the end of each of cols equal
+// time buckets between from and to, carrying the last value forward. Buckets
+// before the first snapshot are -1.
+func resampleLeftover(snapshots []db.UsageSnapshot, from, to time.Time, cols int) []int {
+	if first := snapshots[0].CollectedAt; from.Before(first) && to.Sub(first) > 0 {
+		from = first
+	}
+	span := to.Sub(from)
+	values := make([]int, cols)
+	idx := 0
+	last := -1
+	for c := 0; c < cols; c++ {
+		end := from.Add(time.Duration(float64(span) * float64(c+1) / float64(cols)))
+		for idx < len(snapshots) && !snapshots[idx].CollectedAt.After(end) {
+			last = snapshots[idx].Leftover
+			idx++
+		}
+		values[c] = last
+	}
+	return values
+}
+
+func renderTopBars(daily []db.DailyUsage, width int) []string {
+	maxConsumed := 1
+	for _, d := range daily {
+		maxConsumed = max(maxConsumed, d.RequestsConsumed)
+	}
+	barWidth := min(width-20, 40)
+	if barWidth < 5 {
+		return nil
+	}
+
+	lines := make([]string, 0, len(daily))
+	for _, d := range daily {
+		n := d.RequestsConsumed * barWidth / maxConsumed
+		n = max(0, min(n, barWidth))
+		lines = append(lines, fmt.Sprintf(" %s %s %d", d.Day, strings.Repeat("█", n)+strings.Repeat("░", barWidth-n), d.RequestsConsumed))
+	}
+	return lines
+}
+
+// renderTopSnapshots lists the newest snapshots first.
+func renderTopSnapshots(snapshots []db.UsageSnapshot, width, height int) []string {
+	if height < 2 {
+		return nil
+	}
+	lines := []string{fmt.Sprintf(" %-16s %6s %6s %8s", "Time", "Used", "Left", "Change")}
+	if width < 42 {
+		lines[0] = fmt.Sprintf(" %-11s %5s %5s", "Time", "Used", "Left")
+	}
+
+	for i := len(snapshots) - 1; i >= 0 && len(lines) < height; i-- {
+		s := snapshots[i]
+		if width < 42 {
+			lines = append(lines, fmt.Sprintf(" %-11s %5d %5d", s.CollectedAt.Local().Format("01-02 15:04"), s.RequestsUsed, s.Leftover))
+			continue
+		}
+		change := ""
+		if i > 0 {
+			change = fmt.Sprintf("%+d", s.RequestsUsed-snapshots[i-1].RequestsUsed)
+		}
+		lines = append(lines, fmt.Sprintf(" %-16s %6d %6d %8s", s.CollectedAt.Local().Format("2006-01-02 15:04"), s.RequestsUsed, s.Leftover, change))
+	}
+	return lines
+}
+
+func topFooter(width int) string {
+	if width < 32 {
+		return " q quit · v view"
+	}
+	if width < 50 {
+		return " 1/2/3 range · v view · q quit"
+	}
+	return " [1] 24h  [2] 7d  [3] cycle   [v] view   [r] refresh   [q] quit"
+}
+
+// fitScreen truncates lines to the terminal and pins the footer to the
+// last row.
+func fitScreen(lines []string, footer string, state topState) []string {
+	maxBody := max(state.height-1, 0)
+	if len(lines) > maxBody {
+		lines = lines[:maxBody]
+	}
+	for len(lines) < maxBody {
+		lines = append(lines, "")
+	}
+	if state.height > 0 {
+		lines = append(lines, footer)
+	}
+	for i, l := range lines {
+		lines[i] = truncateRunes(l, state.width)
+	}
+	return lines
+}
+
+func truncateRunes(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	r := []rune(s)
+	return string(r[:max(width, 0)])
+}
+
+// padBetween places left and right on one line of the given width,
+// dropping right if it does not fit.
+func padBetween(left, right string, width int) string {
+	gap := width - utf8.RuneCountInString(left) - utf8.RuneCountInString(right)
+	if gap < 1 {
+		return left
+	}
+	return left + strings.Repeat(" ", gap) + right
+}
+
+func formatDurationShort(d time.Duration) string {
+	if d <= 0 {
+		return "now"
+	}
+	d = d.Round(time.Minute)
+	days := int(d.Hours()) / 24
+	hours := int(d.Hours()) % 24
+	minutes := int(d.Minutes()) % 60
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd%dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh%02dm", hours, minutes)
+	default:
+		return fmt.Sprintf("%dm", minutes)
+	}
+}
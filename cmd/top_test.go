@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aure/syntrack/internal/db"
+)
+
+func testTopData(now time.Time) topData {
+	renews := now.Add(5 * time.Hour)
+	var snapshots []db.UsageSnapshot
+	for i := 0; i < 12; i++ {
+		used := i * 8
+		snapshots = append(snapshots, db.UsageSnapshot{
+			ID:                int64(i + 1),
+			CollectedAt:       now.Add(time.Duration(i-11) * 2 * time.Hour),
+			SubscriptionLimit: 135,
+			RequestsUsed:      used,
+			Leftover:          135 - used,
+			RenewsAt:          &renews,
+		})
+	}
+	return topData{
+		latest:    &snapshots[len(snapshots)-1],
+		burnRate:  4,
+		since:     now.Add(-24 * time.Hour),
+		snapshots: snapshots,
+		daily:     []db.DailyUsage{{Day: "2025-01-15", RequestsConsumed: 40}, {Day: "2025-01-14", RequestsConsumed: 20}},
+	}
+}
+
+func TestRenderTop_FitsTerminal(t *testing.T) {
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	data := testTopData(now)
+
+	sizes := [][2]int{{120, 40}, {80, 24}, {45, 20}, {30, 10}, {20, 5}}
+	for view := 0; view < topViewCount; view++ {
+		for _, size := range sizes {
+			state := topState{view: view, width: size[0], height: size[1]}
+			lines := renderTop(state, data, now)
+
+			if len(lines) != state.height {
+				t.Fatalf("view %d at %dx%d: expected %d lines, got %d", view, size[0], size[1], state.height, len(lines))
+			}
+			for _, l := range lines {
+				if n := utf8.RuneCountInString(l); n > state.width {
+					t.Fatalf("view %d at %dx%d: line of width %d: %q", view, size[0], size[1], n, l)
+				}
+			}
+			if !strings.Contains(lines[len(lines)-1], "q") {
+				t.Fatalf("view %d at %dx%d: footer missing: %q", view, size[0], size[1], lines[len(lines)-1])
+			}
+		}
+	}
+}
+
+func TestRenderTop_NarrowOverviewDropsChart(t *testing.T) {
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	data := testTopData(now)
+
+	wide := strings.Join(renderTop(topState{width: 100, height: 40}, data, now), "\n")
+	narrow := strings.Join(renderTop(topState{width: 35, height: 40}, data, now), "\n")
+
+	if !strings.Contains(wide, "Leftover (24h)") {
+		t.Fatalf("expected chart in wide overview:\n%s", wide)
+	}
+	if strings.Contains(narrow, "Leftover (24h)") {
+		t.Fatalf("expected no chart in narrow overview:\n%s", narrow)
+	}
+	if !strings.Contains(narrow, "Leftover  47") {
+		t.Fatalf("expected status in narrow overview:\n%s", narrow)
+	}
+}
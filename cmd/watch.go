@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/aure/syntrack/internal/api"
+	"github.com/aure/syntrack/internal/db"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var watchInterval time.Duration
+var watchLive bool
+var watchNDJSON bool
+
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Stream the current status to a terminal or pipe",
+	Long: `Poll for new snapshots and print the current status.
+
+On a terminal a single status line is redrawn in place. When the output is
+piped, or with --ndjson, one line is written per new snapshot; --ndjson
+writes the same JSON object as 'query current'.
+
+By default the database is polled, so something else (cron, 'collect')
+must be collecting. With --live the Synthetic API is queried directly at
+every interval instead; nothing is stored.
+
+Examples:
+  syntrack watch
+  syntrack watch --live --interval 1m
+  syntrack watch --ndjson | jq .leftover`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if watchInterval <= 0 {
+			return fmt.Errorf("--interval must be positive")
+		}
+
+		var source func(context.Context) (*db.UsageSnapshot, bool, error)
+		if watchLive {
+			if apiKey == "" {
+				return fmt.Errorf("SYNTHETIC_API_KEY not set")
+			}
+			source = liveSnapshotSource(api.NewClient(apiKey))
+		} else {
+			database, err := db.New(dbPath)
+			if err != nil {
+				return fmt.Errorf("opening database: %w", err)
+			}
+			defer database.Close()
+			source = dbSnapshotSource(database)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		inPlace := !watchNDJSON && term.IsTerminal(int(os.Stdout.Fd()))
+		if inPlace {
+			defer fmt.Println()
+		}
+
+		ticker := time.NewTicker(watchInterval)
+		defer ticker.Stop()
+		for {
+			snapshot, isNew, err := source(ctx)
+			switch {
+			case err != nil && ctx.Err() == nil:
+				// Keep watching through transient failures
+				slog.Warn("watch poll failed", "err", err)
+				if inPlace {
+					fmt.Printf("\r\033[K%s", err)
+				}
+			case snapshot == nil:
+				if inPlace {
+					fmt.Print("\r\033[KNo data yet; waiting for the first snapshot...")
+				}
+			case watchNDJSON:
+				if isNew {
+					if err := writeWatchNDJSON(os.Stdout, snapshot); err != nil {
+						return err
+					}
+				}
+			case inPlace:
+				// Redraw every tick so the countdown stays current
+				fmt.Printf("\r\033[K%s", formatWatchLine(snapshot, time.Now()))
+			case isNew:
+				fmt.Println(formatWatchLine(snapshot, time.Now()))
+			}
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+	},
+}
+
+// dbSnapshotSource returns the latest stored snapshot and whether it is
+// newer than the one returned by the previous call.
+func dbSnapshotSource(database *db.DB) func(context.Context) (*db.UsageSnapshot, bool, error) {
+	var lastID int64
+	return func(ctx context.Context) (*db.UsageSnapshot, bool, error) {
+		snapshot, err := database.GetLatestSnapshot()
+		if err != nil || snapshot == nil {
+			return nil, false, err
+		}
+		isNew := snapshot.ID != lastID
+		lastID = snapshot.ID
+		return snapshot, isNew, nil
+	}
+}
+
+// liveSnapshotSource queries the API; every successful response counts as
+// a new snapshot.
+func liveSnapshotSource(client *api.Client) func(context.Context) (*db.UsageSnapshot, bool, error) {
+	return func(ctx context.Context) (*db.UsageSnapshot, bool, error) {
+		reqCtx, cancel := context.WithTimeout(ctx, collectTimeout)
+		defer cancel()
+
+		quota, err := client.GetQuotas(reqCtx)
+		if err != nil {
+			return nil, false, fmt.Errorf("fetching quotas: %w", err)
+		}
+
+		snapshot := &db.UsageSnapshot{
+			CollectedAt:       time.Now(),
+			SubscriptionLimit: quota.Subscription.Limit,
+			RequestsUsed:      quota.Subscription.Requests,
+			Leftover:          quota.Subscription.Limit - quota.Subscription.Requests,
+		}
+		if !quota.Subscription.RenewsAt.IsZero() {
+			renewsAt := quota.Subscription.RenewsAt
+			snapshot.RenewsAt = &renewsAt
+		}
+		return snapshot, true, nil
+	}
+}
+
+// writeWatchNDJSON writes s as a single line holding the object
+// 'query current' prints.
+func writeWatchNDJSON(w io.Writer, s *db.UsageSnapshot) error {
+	return json.NewEncoder(w).Encode(newCurrentStatus(s))
+}
+
+func formatWatchLine(s *db.UsageSnapshot, now time.Time) string {
+	pct := 0.0
+	if s.SubscriptionLimit > 0 {
+		pct = float64(s.RequestsUsed) / float64(s.SubscriptionLimit) * 100
+	}
+	line := fmt.Sprintf("%d/%d used · %d left (%.0f%%)", s.RequestsUsed, s.SubscriptionLimit, s.Leftover, pct)
+	if s.RenewsAt != nil {
+		line += " · renews in " + formatDurationShort(s.RenewsAt.Sub(now))
+	}
+	return line + " · " + s.CollectedAt.Local().Format("15:04")
+}
+
+func init() {
+	watchCmd.Flags().DurationVarP(&watchInterval, "interval", "i", 30*time.Second, "How often to poll")
+	watchCmd.Flags().BoolVar(&watchLive, "live", false, "Query the Synthetic API directly instead of the database")
+	watchCmd.Flags().BoolVar(&watchNDJSON, "ndjson", false, "Write one JSON object per new snapshot")
+	rootCmd.AddCommand(watchCmd)
+}
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aure/syntrack/internal/db"
+)
+
+func TestDBSnapshotSource_EmitsNewSnapshotsOnce(t *testing.T) {
+	database, err := db.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	source := dbSnapshotSource(database)
+	poll := func() (*db.UsageSnapshot, bool) {
+		t.Helper()
+		snapshot, isNew, err := source(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return snapshot, isNew
+	}
+
+	if snapshot, isNew := poll(); snapshot != nil || isNew {
+		t.Fatalf("empty database: got %+v, new %v", snapshot, isNew)
+	}
+
+	renews := time.Now().Add(time.Hour)
+	for _, used := range []int{10, 25} {
+		if err := database.InsertSnapshot(100, used, &renews); err != nil {
+			t.Fatal(err)
+		}
+		if snapshot, isNew := poll(); !isNew || snapshot.RequestsUsed != used {
+			t.Fatalf("after inserting %d: got %+v, new %v", used, snapshot, isNew)
+		}
+		// The same snapshot is returned again but not as new
+		if snapshot, isNew := poll(); isNew || snapshot == nil || snapshot.RequestsUsed != used {
+			t.Fatalf("second poll after inserting %d: got %+v, new %v", used, snapshot, isNew)
+		}
+	}
+}
+
+func TestWriteWatchNDJSON(t *testing.T) {
+	collected := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	renews := collected.Add(6 * time.Hour)
+	snapshots := []*db.UsageSnapshot{
+		{CollectedAt: collected, SubscriptionLimit: 200, RequestsUsed: 50, Leftover: 150, RenewsAt: &renews},
+		{CollectedAt: collected.Add(time.Minute), SubscriptionLimit: 200, RequestsUsed: 60, Leftover: 140},
+	}
+
+	var buf bytes.Buffer
+	for _, s := range snapshots {
+		if err := writeWatchNDJSON(&buf, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != len(snapshots) {
+		t.Fatalf("got %d lines, want %d:\n%s", len(lines), len(snapshots), buf.String())
+	}
+	wantKeys := [][]string{
+		{"leftover", "limit", "renews_at", "time_until_renew", "timestamp", "usage_percent", "used"},
+		{"leftover", "limit", "timestamp", "usage_percent", "used"},
+	}
+	for i, line := range lines {
+		var obj map[string]any
+		if err := json.Unmarshal([]byte(line), &obj); err != nil {
+			t.Fatalf("line %d is not JSON: %v: %s", i+1, err, line)
+		}
+		var keys []string
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		if !slices.Equal(keys, wantKeys[i]) {
+			t.Fatalf("line %d keys = %v, want %v", i+1, keys, wantKeys[i])
+		}
+	}
+
+	var first CurrentStatus
+	json.Unmarshal([]byte(lines[0]), &first)
+	if first.Timestamp != "2025-01-15T12:00:00Z" || first.Used != 50 || first.Leftover != 150 || first.UsagePercent != 25 || *first.RenewsAt != "2025-01-15T18:00:00Z" {
+		t.Fatalf("first line = %+v", first)
+	}
+}
+
+func TestFormatWatchLine(t *testing.T) {
+	old := time.Local
+	time.Local = time.UTC
+	defer func() { time.Local = old }()
+
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+
+	tests := []struct {
+		name     string
+		snapshot db.UsageSnapshot
+		want     string
+	}{
+		{
+			name:     "renews in hours",
+			snapshot: db.UsageSnapshot{CollectedAt: now.Add(-5 * time.Minute), SubscriptionLimit: 135, RequestsUsed: 89, Leftover: 46, RenewsAt: at(2*time.Hour + 5*time.Minute)},
+			want:     "89/135 used · 46 left (66%) · renews in 2h05m · 11:55",
+		},
+		{
+			name:     "renews in days",
+			snapshot: db.UsageSnapshot{CollectedAt: now, SubscriptionLimit: 100, RequestsUsed: 10, Leftover: 90, RenewsAt: at(50 * time.Hour)},
+			want:     "10/100 used · 90 left (10%) · renews in 2d2h · 12:00",
+		},
+		{
+			name:     "renewal passed",
+			snapshot: db.UsageSnapshot{CollectedAt: now, SubscriptionLimit: 100, RequestsUsed: 100, Leftover: 0, RenewsAt: at(-time.Minute)},
+			want:     "100/100 used · 0 left (100%) · renews in now · 12:00",
+		},
+		{
+			name:     "no renewal",
+			snapshot: db.UsageSnapshot{CollectedAt: now, SubscriptionLimit: 100, RequestsUsed: 40, Leftover: 60},
+			want:     "40/100 used · 60 left (40%) · 12:00",
+		},
+		{
+			name:     "zero limit",
+			snapshot: db.UsageSnapshot{CollectedAt: now},
+			want:     "0/0 used · 0 left (0%) · 12:00",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatWatchLine(&tt.snapshot, now); got != tt.want {
+				t.Fatalf("got  %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+// Package anomaly flags consumption intervals that are far above what is
+// usual for the same hour of the week.
+//
+// Intervals are compared by their rate (requests per hour) against the
+// intervals of the same hour of the week, and the hours on either side,
+// using the median and the median absolute deviation (MAD). Both are
+// robust against earlier spikes in the history, unlike a mean and standard
+// deviation.
+package anomaly
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/aure/syntrack/internal/db"
+)
+
+const hoursPerWeek = 7 * 24
+
+// madScale makes the MAD comparable to a standard deviation for normally
+// distributed data.
+const madScale = 1.4826
+
+// minRateHours keeps a request or two in a short interval (a manual
+// collect right after the cron run) from looking like a huge rate.
+const minRateHours = 0.25
+
+// Detector holds the detection parameters.
+type Detector struct {
+	// Threshold is the robust z-score at or above which an interval is
+	// anomalous.
+	Threshold float64
+	// MinSamples is how many historical intervals the hour of the week
+	// needs before anything is judged.
+	MinSamples int
+	// MinConsumed ignores intervals that used fewer requests.
+	MinConsumed int
+	// MinSpread is the smallest spread in requests per hour, so an hour
+	// that is usually idle does not flag every single request.
+	MinSpread float64
+}
+
+// Default is the detector used after each collect.
+var Default = Detector{Threshold: 3.5, MinSamples: 9, MinConsumed: 5, MinSpread: 1}
+
+// Result describes how one interval compares to its history.
+type Result struct {
+	Rate      float64
+	Median    float64
+	MAD       float64
+	Score     float64
+	Samples   int
+	Anomalous bool
+}
+
+// Rate returns the consumption of iv in requests per hour.
+func Rate(iv db.Interval) float64 {
+	hours := max(iv.End.Sub(iv.Start).Hours(), minRateHours)
+	return float64(iv.Consumed) / hours
+}
+
+// Evaluate compares iv with the history intervals of the same hour of the
+// week (±1 hour). The history should not include iv itself.
+func (d Detector) Evaluate(history []db.Interval, iv db.Interval) Result {
+	slot := hourOfWeek(midpoint(iv))
+	var rates []float64
+	for _, h := range history {
+		diff := (hourOfWeek(midpoint(h)) - slot + hoursPerWeek) % hoursPerWeek
+		if diff <= 1 || diff == hoursPerWeek-1 {
+			rates = append(rates, Rate(h))
+		}
+	}
+
+	r := Result{Rate: Rate(iv), Samples: len(rates)}
+	if len(rates) < d.MinSamples {
+		return r
+	}
+
+	r.Median = median(rates)
+	deviations := make([]float64, len(rates))
+	for i, v := range rates {
+		deviations[i] = math.Abs(v - r.Median)
+	}
+	r.MAD = median(deviations)
+
+	spread := max(madScale*r.MAD, d.MinSpread)
+	r.Score = (r.Rate - r.Median) / spread
+	r.Anomalous = iv.Consumed >= d.MinConsumed && r.Score >= d.Threshold
+	return r
+}
+
+func midpoint(iv db.Interval) time.Time {
+	return iv.Start.Add(iv.End.Sub(iv.Start) / 2)
+}
+
+// hourOfWeek numbers the hours of the local week from Monday 00:00.
+func hourOfWeek(t time.Time) int {
+	t = t.Local()
+	return ((int(t.Weekday())+6)%7)*24 + t.Hour()
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
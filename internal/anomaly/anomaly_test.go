@@ -0,0 +1,68 @@
+package anomaly
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aure/syntrack/internal/db"
+)
+
+// weekly returns one-hour intervals ending at the same hour in each of
+// the previous weeks, consuming the given amounts.
+func weekly(end time.Time, consumed ...int) []db.Interval {
+	var intervals []db.Interval
+	for i, c := range consumed {
+		e := end.AddDate(0, 0, -7*(i+1))
+		intervals = append(intervals, db.Interval{Start: e.Add(-time.Hour), End: e, Consumed: c})
+	}
+	return intervals
+}
+
+func TestEvaluate(t *testing.T) {
+	old := time.Local
+	time.Local = time.UTC
+	defer func() { time.Local = old }()
+
+	end := time.Date(2025, 3, 10, 15, 0, 0, 0, time.UTC)
+	history := weekly(end, 10, 12, 9, 11, 10, 13, 8, 10, 7, 14)
+
+	tests := []struct {
+		name      string
+		consumed  int
+		anomalous bool
+	}{
+		{"usual", 12, false},
+		{"spike", 60, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Default.Evaluate(history, db.Interval{Start: end.Add(-time.Hour), End: end, Consumed: tt.consumed})
+			if r.Anomalous != tt.anomalous {
+				t.Fatalf("anomalous = %v, want %v (%+v)", r.Anomalous, tt.anomalous, r)
+			}
+			if r.Samples != len(history) || r.Median != 10 {
+				t.Fatalf("unexpected baseline: %+v", r)
+			}
+		})
+	}
+}
+
+func TestEvaluate_NeedsHistory(t *testing.T) {
+	end := time.Date(2025, 3, 10, 15, 0, 0, 0, time.UTC)
+	r := Default.Evaluate(weekly(end, 1, 1), db.Interval{Start: end.Add(-time.Hour), End: end, Consumed: 500})
+	if r.Anomalous {
+		t.Fatalf("flagged with only %d samples", r.Samples)
+	}
+}
+
+func TestEvaluate_IdleHourSpread(t *testing.T) {
+	end := time.Date(2025, 3, 10, 3, 0, 0, 0, time.UTC)
+	history := weekly(end, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+
+	if r := Default.Evaluate(history, db.Interval{Start: end.Add(-time.Hour), End: end, Consumed: 3}); r.Anomalous {
+		t.Fatalf("flagged below MinConsumed: %+v", r)
+	}
+	if r := Default.Evaluate(history, db.Interval{Start: end.Add(-time.Hour), End: end, Consumed: 20}); !r.Anomalous {
+		t.Fatalf("missed spike in idle hour: %+v", r)
+	}
+}
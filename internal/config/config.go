@@ -1,85 +1,505 @@
 package config
 
 import (
-	"bufio"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
+	"github.com/aure/syntrack/internal/logging"
+	"github.com/aure/syntrack/internal/tokens"
 	"github.com/joho/godotenv"
 	"github.com/spf13/viper"
 )
 
+// Config is the effective configuration. Values are resolved in order of
+// increasing precedence: built-in defaults, the YAML config file,
+// environment variables, then command-line flags.
 type Config struct {
-	APIKey     string
-	DBPath     string
-	AuthTokens []string
+	APIKey     string   `mapstructure:"api_key" yaml:"api_key"`
+	APIKeyFile string   `mapstructure:"api_key_file" yaml:"api_key_file,omitempty"`
+	DBPath     string   `mapstructure:"database_path" yaml:"database_path"`
+	Timezone   string   `mapstructure:"timezone" yaml:"timezone,omitempty"`
+	Tokens     []string `mapstructure:"-" yaml:"auth_tokens,omitempty"`
+	TokensFile string   `mapstructure:"auth_tokens_file" yaml:"auth_tokens_file,omitempty"`
+	TokenFile  string   `mapstructure:"token_file" yaml:"token_file"`
+
+	Log     LogConfig     `mapstructure:"log" yaml:"log"`
+	Serve   ServeConfig   `mapstructure:"serve" yaml:"serve"`
+	Collect CollectConfig `mapstructure:"collect" yaml:"collect"`
+	Digest  DigestConfig  `mapstructure:"digest" yaml:"digest"`
+	Proxy   ProxyConfig   `mapstructure:"proxy" yaml:"proxy"`
+	Guard   GuardConfig   `mapstructure:"guard" yaml:"guard"`
+
+	// File is the config file that was read, if any.
+	File string `mapstructure:"-" yaml:"-"`
+}
+
+type LogConfig struct {
+	Level      string `mapstructure:"level" yaml:"level"`
+	Format     string `mapstructure:"format" yaml:"format"`
+	File       string `mapstructure:"file" yaml:"file,omitempty"`
+	MaxSize    int    `mapstructure:"max_size" yaml:"max_size"`
+	MaxBackups int    `mapstructure:"max_backups" yaml:"max_backups"`
+}
+
+type ServeConfig struct {
+	Port              int           `mapstructure:"port" yaml:"port"`
+	Auth              bool          `mapstructure:"auth" yaml:"auth"`
+	BindAll           bool          `mapstructure:"bind_all" yaml:"bind_all"`
+	Tailscale         bool          `mapstructure:"tailscale" yaml:"tailscale"`
+	TailscaleIP       string        `mapstructure:"tailscale_ip" yaml:"tailscale_ip,omitempty"`
+	TrustedProxies    []string      `mapstructure:"trusted_proxy" yaml:"trusted_proxy,omitempty"`
+	NoLocalhostBypass bool          `mapstructure:"no_localhost_bypass" yaml:"no_localhost_bypass"`
+	SessionTTL        time.Duration `mapstructure:"session_ttl" yaml:"session_ttl"`
+	RefreshInterval   time.Duration `mapstructure:"refresh_interval" yaml:"refresh_interval"`
+	TLSCert           string        `mapstructure:"tls_cert" yaml:"tls_cert,omitempty"`
+	TLSKey            string        `mapstructure:"tls_key" yaml:"tls_key,omitempty"`
+	TLSSelfSigned     bool          `mapstructure:"tls_self_signed" yaml:"tls_self_signed"`
+	HTTPPort          int           `mapstructure:"http_port" yaml:"http_port,omitempty"`
+	PIDFile           string        `mapstructure:"pid_file" yaml:"pid_file,omitempty"`
+}
+
+type CollectConfig struct {
+	Timeout time.Duration `mapstructure:"timeout" yaml:"timeout"`
+}
+
+// DigestConfig schedules the digest and configures where it is sent.
+// A channel is enabled by setting smtp.host or webhook.url.
+type DigestConfig struct {
+	Period  string        `mapstructure:"period" yaml:"period"`
+	Time    string        `mapstructure:"time" yaml:"time"`
+	Weekday string        `mapstructure:"weekday" yaml:"weekday"`
+	SMTP    SMTPConfig    `mapstructure:"smtp" yaml:"smtp"`
+	Webhook WebhookConfig `mapstructure:"webhook" yaml:"webhook"`
+}
+
+type SMTPConfig struct {
+	Host     string   `mapstructure:"host" yaml:"host,omitempty"`
+	Port     int      `mapstructure:"port" yaml:"port"`
+	Username string   `mapstructure:"username" yaml:"username,omitempty"`
+	Password string   `mapstructure:"password" yaml:"password,omitempty"`
+	From     string   `mapstructure:"from" yaml:"from,omitempty"`
+	To       []string `mapstructure:"to" yaml:"to,omitempty"`
+}
+
+type WebhookConfig struct {
+	URL string `mapstructure:"url" yaml:"url,omitempty"`
+	// Template is a text/template producing the JSON body; the digest is
+	// sent as JSON when it is empty.
+	Template string `mapstructure:"template" yaml:"template,omitempty"`
+}
+
+// ProxyConfig configures 'syntrack proxy'.
+type ProxyConfig struct {
+	Listen   string `mapstructure:"listen" yaml:"listen"`
+	Upstream string `mapstructure:"upstream" yaml:"upstream"`
+	// Labels names the clients using each API key, by key ID (see
+	// 'syntrack proxy key-id').
+	Labels map[string]string `mapstructure:"labels" yaml:"labels,omitempty"`
 }
 
-func Load() (*Config, error) {
+// GuardConfig configures the quota guard of 'syntrack guard' and
+// 'syntrack proxy --guard'.
+type GuardConfig struct {
+	Listen   string `mapstructure:"listen" yaml:"listen"`
+	Upstream string `mapstructure:"upstream" yaml:"upstream"`
+	// Reserve is the leftover normal clients cannot touch; LowReserve is
+	// the same for low priority clients, at least Reserve.
+	Reserve    int `mapstructure:"reserve" yaml:"reserve"`
+	LowReserve int `mapstructure:"low_reserve" yaml:"low_reserve"`
+	// Priorities maps key labels (proxy.labels), in lower case, to
+	// critical, normal or low. A name in X-Syntrack-Client can only lower
+	// the priority of the key.
+	Priorities      map[string]string `mapstructure:"priorities" yaml:"priorities,omitempty"`
+	DefaultPriority string            `mapstructure:"default_priority" yaml:"default_priority"`
+}
+
+// Guard priorities. Critical clients are never refused.
+const (
+	PriorityCritical = "critical"
+	PriorityNormal   = "normal"
+	PriorityLow      = "low"
+)
+
+func validPriority(p string) bool {
+	return p == PriorityCritical || p == PriorityNormal || p == PriorityLow
+}
+
+// Credential names looked up in $CREDENTIALS_DIRECTORY (systemd
+// LoadCredential=) when the corresponding value is not set otherwise.
+const (
+	CredentialAPIKey     = "synthetic_api_key"
+	CredentialAuthTokens = "syntrack_auth_tokens"
+)
+
+// Load reads the config file (configFile, or .syntrack.yaml in the home or
+// current directory), the environment and any flags bound to viper with
+// BindPFlag, and returns the merged configuration.
+func Load(configFile string) (*Config, error) {
 	godotenv.Load()
+	return LoadFrom(viper.GetViper(), configFile)
+}
+
+// LoadFrom is Load reading through v instead of the global viper instance.
+// With a fresh instance it leaves the global state alone, so it is safe to
+// call while commands run.
+func LoadFrom(v *viper.Viper, configFile string) (*Config, error) {
+	if configFile != "" {
+		v.SetConfigFile(configFile)
+	} else {
+		if home, err := os.UserHomeDir(); err == nil {
+			v.AddConfigPath(home)
+		}
+		v.AddConfigPath(".")
+		v.SetConfigType("yaml")
+		v.SetConfigName(".syntrack")
+	}
+
+	v.SetDefault("database_path", "usage.db")
+	v.SetDefault("collect.timeout", 30*time.Second)
+	v.SetDefault("digest.period", "daily")
+	v.SetDefault("digest.time", "08:00")
+	v.SetDefault("digest.weekday", "monday")
+	v.SetDefault("digest.smtp.port", 587)
+	v.SetDefault("proxy.listen", "127.0.0.1:8090")
+	v.SetDefault("proxy.upstream", "https://api.synthetic.new")
+	v.SetDefault("guard.listen", "127.0.0.1:8091")
+	v.SetDefault("guard.upstream", "https://api.synthetic.new")
+	v.SetDefault("guard.reserve", 0)
+	v.SetDefault("guard.default_priority", PriorityNormal)
+
+	// Nested keys map to SYNTRACK_SERVE_PORT, SYNTRACK_LOG_LEVEL, ...
+	v.SetEnvPrefix("syntrack")
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+
+	v.BindEnv("api_key", "SYNTHETIC_API_KEY", "SYNTRACK_API_KEY")
+	v.BindEnv("api_key_file", "SYNTHETIC_API_KEY_FILE", "SYNTRACK_API_KEY_FILE")
+	v.BindEnv("database_path", "DATABASE_PATH", "SYNTRACK_DATABASE_PATH")
+	v.BindEnv("auth_tokens", "SYNTRACK_AUTH_TOKENS")
+	v.BindEnv("auth_tokens_file", "SYNTRACK_AUTH_TOKENS_FILE")
+	v.BindEnv("token_file", "SYNTRACK_TOKEN_FILE")
+	v.BindEnv("digest.smtp.host", "SYNTRACK_DIGEST_SMTP_HOST")
+	v.BindEnv("digest.smtp.username", "SYNTRACK_DIGEST_SMTP_USERNAME")
+	v.BindEnv("digest.smtp.password", "SYNTRACK_DIGEST_SMTP_PASSWORD")
+	v.BindEnv("digest.webhook.url", "SYNTRACK_DIGEST_WEBHOOK_URL")
 
-	viper.SetDefault("database_path", "usage.db")
-	viper.AutomaticEnv()
-	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	cfg := &Config{}
+	if err := v.ReadInConfig(); err == nil {
+		cfg.File = v.ConfigFileUsed()
+	} else {
+		var notFound viper.ConfigFileNotFoundError
+		if configFile != "" || !errors.As(err, &notFound) {
+			return nil, fmt.Errorf("reading config file: %w", err)
+		}
+	}
+
+	if err := v.Unmarshal(cfg); err != nil {
+		return nil, fmt.Errorf("parsing config: %w", err)
+	}
+	cfg.Tokens = splitList(v.Get("auth_tokens"))
+	cfg.Digest.SMTP.To = splitList(v.Get("digest.smtp.to"))
 
-	viper.BindEnv("api_key", "SYNTHETIC_API_KEY")
-	viper.BindEnv("database_path", "DATABASE_PATH")
-	viper.BindEnv("auth_tokens", "SYNTRACK_AUTH_TOKENS")
+	if err := cfg.resolveSecrets(); err != nil {
+		return nil, err
+	}
 
-	cfg := &Config{
-		APIKey:     viper.GetString("api_key"),
-		DBPath:     viper.GetString("database_path"),
-		AuthTokens: loadAuthTokens(),
+	if cfg.TokenFile == "" {
+		path, err := TokenFile()
+		if err != nil {
+			return nil, fmt.Errorf("getting home directory: %w", err)
+		}
+		cfg.TokenFile = path
 	}
 
 	return cfg, nil
 }
 
-func loadAuthTokens() []string {
-	var tokens []string
+// resolveSecrets fills the API key and auth tokens from *_FILE paths or
+// systemd credentials when they were not given directly.
+func (c *Config) resolveSecrets() error {
+	credDir := os.Getenv("CREDENTIALS_DIRECTORY")
 
-	// Load from environment variable (comma-separated)
-	if envTokens := viper.GetString("auth_tokens"); envTokens != "" {
-		for _, token := range strings.Split(envTokens, ",") {
-			token = strings.TrimSpace(token)
-			if token != "" {
-				tokens = append(tokens, token)
+	if c.APIKey == "" {
+		path := c.APIKeyFile
+		if path == "" && credDir != "" {
+			path = credentialPath(credDir, CredentialAPIKey)
+		}
+		if path != "" {
+			key, err := readSecretFile(path, "SYNTHETIC_API_KEY")
+			if err != nil {
+				return fmt.Errorf("reading API key file: %w", err)
 			}
+			c.APIKey = key
 		}
 	}
 
-	// Load from file (~/.syntrack/tokens)
-	homeDir, err := os.UserHomeDir()
-	if err == nil {
-		tokenFile := filepath.Join(homeDir, ".syntrack", "tokens")
-		if fileTokens, err := loadTokensFromFile(tokenFile); err == nil {
-			tokens = append(tokens, fileTokens...)
+	if len(c.Tokens) == 0 {
+		path := c.TokensFile
+		if path == "" && credDir != "" {
+			path = credentialPath(credDir, CredentialAuthTokens)
+		}
+		if path != "" {
+			value, err := readSecretFile(path, "SYNTRACK_AUTH_TOKENS")
+			if err != nil {
+				return fmt.Errorf("reading auth tokens file: %w", err)
+			}
+			c.Tokens = splitList(value)
 		}
 	}
 
-	return tokens
+	return nil
+}
+
+// credentialPath returns the credential file if it exists, or "".
+func credentialPath(dir, name string) string {
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
 }
 
-func loadTokensFromFile(path string) ([]string, error) {
-	file, err := os.Open(path)
+// readSecretFile returns the content of a secret file. The file may hold
+// just the value, or be a .env file that defines envName.
+func readSecretFile(path, envName string) (string, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	defer file.Close()
+	content := strings.TrimSpace(string(data))
 
-	var tokens []string
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		token := strings.TrimSpace(scanner.Text())
-		if token != "" && !strings.HasPrefix(token, "#") {
-			tokens = append(tokens, token)
+	if strings.Contains(content, envName+"=") {
+		if vars, err := godotenv.Unmarshal(content); err == nil && vars[envName] != "" {
+			return vars[envName], nil
 		}
 	}
+	return content, nil
+}
 
-	if err := scanner.Err(); err != nil {
+// splitList accepts a YAML list or a comma/newline separated string.
+func splitList(v any) []string {
+	var raw []string
+	switch v := v.(type) {
+	case string:
+		raw = strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == '\n' })
+	case []string:
+		raw = v
+	case []any:
+		for _, item := range v {
+			raw = append(raw, fmt.Sprint(item))
+		}
+	}
+
+	var out []string
+	for _, s := range raw {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// Location returns the configured timezone, defaulting to the system one.
+func (c *Config) Location() (*time.Location, error) {
+	if c.Timezone == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q", c.Timezone)
+	}
+	return loc, nil
+}
+
+// LoadAuthTokens opens the token store and adds the tokens given in the
+// config or environment; those are never written to the token file.
+func (c *Config) LoadAuthTokens() (*tokens.Store, error) {
+	store, err := tokens.Load(c.TokenFile)
+	if err != nil {
 		return nil, err
 	}
+	for _, token := range c.Tokens {
+		store.AddPlaintext(token, "env")
+	}
+	return store, nil
+}
+
+// Validate reports every problem with the configuration.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if _, err := logging.ParseLevel(c.Log.Level); err != nil {
+		errs = append(errs, err)
+	}
+	if f := strings.ToLower(c.Log.Format); f != "" && f != "text" && f != "json" {
+		errs = append(errs, fmt.Errorf("unknown log format %q (valid: text, json)", c.Log.Format))
+	}
+	if _, err := c.Location(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Serve.Port < 1 || c.Serve.Port > 65535 {
+		errs = append(errs, fmt.Errorf("serve.port %d out of range", c.Serve.Port))
+	}
+	if c.Serve.HTTPPort < 0 || c.Serve.HTTPPort > 65535 {
+		errs = append(errs, fmt.Errorf("serve.http_port %d out of range", c.Serve.HTTPPort))
+	}
+	if c.Serve.BindAll && c.Serve.Tailscale {
+		errs = append(errs, fmt.Errorf("serve.bind_all and serve.tailscale are mutually exclusive"))
+	}
+	if (c.Serve.TLSCert == "") != (c.Serve.TLSKey == "") {
+		errs = append(errs, fmt.Errorf("serve.tls_cert and serve.tls_key must be set together"))
+	}
+	if c.Serve.TLSSelfSigned && c.Serve.TLSCert != "" {
+		errs = append(errs, fmt.Errorf("serve.tls_self_signed cannot be combined with serve.tls_cert"))
+	}
+	for _, path := range []string{c.Serve.TLSCert, c.Serve.TLSKey} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			errs = append(errs, fmt.Errorf("TLS file: %w", err))
+		}
+	}
+	if c.Serve.SessionTTL <= 0 {
+		errs = append(errs, fmt.Errorf("serve.session_ttl must be positive"))
+	}
+	if c.Serve.RefreshInterval < 0 {
+		errs = append(errs, fmt.Errorf("serve.refresh_interval must not be negative"))
+	}
+	if c.Collect.Timeout <= 0 {
+		errs = append(errs, fmt.Errorf("collect.timeout must be positive"))
+	}
+	errs = append(errs, c.Digest.validate()...)
+	for _, kv := range [][2]string{{"proxy.upstream", c.Proxy.Upstream}, {"guard.upstream", c.Guard.Upstream}} {
+		if u, err := url.Parse(kv[1]); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("%s %q is not an http(s) URL", kv[0], kv[1]))
+		}
+	}
+	for _, kv := range [][2]string{{"proxy.listen", c.Proxy.Listen}, {"guard.listen", c.Guard.Listen}} {
+		if _, _, err := net.SplitHostPort(kv[1]); err != nil {
+			errs = append(errs, fmt.Errorf("%s %q is not host:port", kv[0], kv[1]))
+		}
+	}
+	errs = append(errs, c.Guard.validate()...)
+	if dir := filepath.Dir(c.DBPath); dir != "" {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			errs = append(errs, fmt.Errorf("database directory %s does not exist", dir))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (d *DigestConfig) validate() []error {
+	var errs []error
+	if d.Period != "daily" && d.Period != "weekly" {
+		errs = append(errs, fmt.Errorf("unknown digest.period %q (valid: daily, weekly)", d.Period))
+	}
+	if _, err := time.Parse("15:04", d.Time); err != nil {
+		errs = append(errs, fmt.Errorf("digest.time %q is not HH:MM", d.Time))
+	}
+	if _, err := ParseWeekday(d.Weekday); err != nil {
+		errs = append(errs, fmt.Errorf("digest.weekday: %w", err))
+	}
+	if d.SMTP.Host != "" {
+		if d.SMTP.From == "" || len(d.SMTP.To) == 0 {
+			errs = append(errs, fmt.Errorf("digest.smtp.from and digest.smtp.to are required with digest.smtp.host"))
+		}
+		if d.SMTP.Port < 1 || d.SMTP.Port > 65535 {
+			errs = append(errs, fmt.Errorf("digest.smtp.port %d out of range", d.SMTP.Port))
+		}
+	}
+	return errs
+}
+
+func (g *GuardConfig) validate() []error {
+	var errs []error
+	if g.Reserve < 0 || g.LowReserve < 0 {
+		errs = append(errs, fmt.Errorf("guard.reserve and guard.low_reserve must not be negative"))
+	}
+	if g.LowReserve > 0 && g.LowReserve < g.Reserve {
+		errs = append(errs, fmt.Errorf("guard.low_reserve %d is below guard.reserve %d", g.LowReserve, g.Reserve))
+	}
+	if !validPriority(g.DefaultPriority) {
+		errs = append(errs, fmt.Errorf("unknown guard.default_priority %q (valid: critical, normal, low)", g.DefaultPriority))
+	}
+	clients := slices.Sorted(maps.Keys(g.Priorities))
+	for _, client := range clients {
+		if p := g.Priorities[client]; !validPriority(p) {
+			errs = append(errs, fmt.Errorf("unknown guard priority %q for %s (valid: critical, normal, low)", p, client))
+		}
+	}
+	return errs
+}
 
-	return tokens, nil
+// ParseWeekday accepts full or three-letter English weekday names.
+func ParseWeekday(s string) (time.Weekday, error) {
+	s = strings.ToLower(s)
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		if s == name || s == name[:3] {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown weekday %q", s)
+}
+
+// Redacted returns a copy that is safe to print.
+func (c *Config) Redacted() *Config {
+	out := *c
+	out.APIKey = redact(c.APIKey)
+	out.Tokens = make([]string, len(c.Tokens))
+	for i, t := range c.Tokens {
+		out.Tokens[i] = redact(t)
+	}
+	out.Serve.TrustedProxies = append([]string(nil), c.Serve.TrustedProxies...)
+	out.Digest.SMTP.Password = redact(c.Digest.SMTP.Password)
+	// Webhook URLs often embed a secret token
+	out.Digest.Webhook.URL = redact(c.Digest.Webhook.URL)
+	return &out
+}
+
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	if len(secret) <= 12 {
+		return "********"
+	}
+	return secret[:4] + "********" + secret[len(secret)-4:]
+}
+
+// Dir returns the per-user syntrack state directory (~/.syntrack).
+func Dir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".syntrack"), nil
+}
+
+// TokenFile returns the default path of the token store (~/.syntrack/tokens).
+func TokenFile() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "tokens"), nil
+}
+
+// DefaultFile returns where 'syntrack config init' writes the config file.
+func DefaultFile() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".syntrack.yaml"), nil
 }
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadSecretFile(t *testing.T) {
+	dir := t.TempDir()
+
+	plain := filepath.Join(dir, "key")
+	os.WriteFile(plain, []byte("syn_plain\n"), 0600)
+	dotenv := filepath.Join(dir, ".env")
+	os.WriteFile(dotenv, []byte("# comment\nDATABASE_PATH=/tmp/x.db\nSYNTHETIC_API_KEY=syn_dotenv\n"), 0600)
+
+	for path, want := range map[string]string{plain: "syn_plain", dotenv: "syn_dotenv"} {
+		got, err := readSecretFile(path, "SYNTHETIC_API_KEY")
+		if err != nil {
+			t.Fatalf("readSecretFile(%s): %v", path, err)
+		}
+		if got != want {
+			t.Fatalf("readSecretFile(%s): expected %q, got %q", path, want, got)
+		}
+	}
+}
+
+func TestResolveSecrets_CredentialsDirectory(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, CredentialAPIKey), []byte("syn_credential\n"), 0600)
+	os.WriteFile(filepath.Join(dir, CredentialAuthTokens), []byte("tok_one\ntok_two, tok_three\n"), 0600)
+	t.Setenv("CREDENTIALS_DIRECTORY", dir)
+
+	c := &Config{}
+	if err := c.resolveSecrets(); err != nil {
+		t.Fatalf("resolveSecrets: %v", err)
+	}
+	if c.APIKey != "syn_credential" {
+		t.Fatalf("expected API key from credentials, got %q", c.APIKey)
+	}
+	if len(c.Tokens) != 3 {
+		t.Fatalf("expected 3 tokens from credentials, got %v", c.Tokens)
+	}
+
+	// A key given directly wins over the credential
+	c = &Config{APIKey: "syn_direct"}
+	if err := c.resolveSecrets(); err != nil {
+		t.Fatalf("resolveSecrets: %v", err)
+	}
+	if c.APIKey != "syn_direct" {
+		t.Fatalf("expected direct API key to win, got %q", c.APIKey)
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	c := &Config{APIKey: "syn_0123456789abcdef", Tokens: []string{"short"}}
+	r := c.Redacted()
+	if r.APIKey != "syn_********cdef" || r.Tokens[0] != "********" {
+		t.Fatalf("unexpected redaction: %q %v", r.APIKey, r.Tokens)
+	}
+	if c.Tokens[0] != "short" {
+		t.Fatal("Redacted modified the original config")
+	}
+}
@@ -0,0 +1,54 @@
+package db
+
+import "time"
+
+// Annotation explains what happened at a point on the usage timeline.
+type Annotation struct {
+	ID        int64
+	At        time.Time
+	Text      string
+	CreatedAt time.Time
+}
+
+// InsertAnnotation records an annotation and returns it with its ID.
+func (db *DB) InsertAnnotation(at time.Time, text string) (Annotation, error) {
+	res, err := db.Exec(`INSERT INTO annotations (at, text) VALUES (?, ?)`, at.UTC(), text)
+	if err != nil {
+		return Annotation{}, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return Annotation{}, err
+	}
+	return Annotation{ID: id, At: at, Text: text, CreatedAt: time.Now()}, nil
+}
+
+// GetAnnotations returns annotations at or after since, oldest first.
+func (db *DB) GetAnnotations(since time.Time) ([]Annotation, error) {
+	rows, err := db.Query(`SELECT id, at, text, created_at FROM annotations WHERE at >= ? ORDER BY at ASC, id ASC`, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var annotations []Annotation
+	for rows.Next() {
+		var a Annotation
+		if err := rows.Scan(&a.ID, &a.At, &a.Text, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		annotations = append(annotations, a)
+	}
+	return annotations, rows.Err()
+}
+
+// DeleteAnnotation removes an annotation. It reports false if there was
+// none with that ID.
+func (db *DB) DeleteAnnotation(id int64) (bool, error) {
+	res, err := db.Exec(`DELETE FROM annotations WHERE id = ?`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,50 @@
+package db
+
+import "time"
+
+// Anomaly is a consumption interval far above the usual rate for its hour
+// of the week.
+type Anomaly struct {
+	ID            int64
+	DetectedAt    time.Time
+	IntervalStart time.Time
+	IntervalEnd   time.Time
+	Consumed      int
+	// Rate and BaselineRate are in requests per hour; BaselineRate is the
+	// median of the history the interval was compared with.
+	Rate         float64
+	BaselineRate float64
+	Score        float64
+}
+
+// InsertAnomaly records an anomaly. It reports false if one was already
+// recorded for the same interval.
+func (db *DB) InsertAnomaly(a Anomaly) (bool, error) {
+	res, err := db.Exec(`INSERT OR IGNORE INTO anomalies (interval_start, interval_end, consumed, rate, baseline_rate, score) VALUES (?, ?, ?, ?, ?, ?)`,
+		a.IntervalStart.UTC(), a.IntervalEnd.UTC(), a.Consumed, a.Rate, a.BaselineRate, a.Score)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// GetAnomalies returns anomalies whose interval ended since the given
+// time, oldest first.
+func (db *DB) GetAnomalies(since time.Time) ([]Anomaly, error) {
+	rows, err := db.Query(`SELECT id, detected_at, interval_start, interval_end, consumed, rate, baseline_rate, score FROM anomalies WHERE interval_end >= ? ORDER BY interval_end ASC`, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var anomalies []Anomaly
+	for rows.Next() {
+		var a Anomaly
+		if err := rows.Scan(&a.ID, &a.DetectedAt, &a.IntervalStart, &a.IntervalEnd, &a.Consumed, &a.Rate, &a.BaselineRate, &a.Score); err != nil {
+			return nil, err
+		}
+		anomalies = append(anomalies, a)
+	}
+	return anomalies, rows.Err()
+}
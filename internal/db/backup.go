@@ -0,0 +1,194 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// requiredColumns lists the usage_snapshots columns a database must have
+// before it is accepted as a restore source.
+var requiredColumns = []string{"id", "collected_at", "subscription_limit", "requests_used", "leftover", "renews_at"}
+
+type BackupInfo struct {
+	Path           string
+	Snapshots      int
+	FirstSnapshot  *time.Time
+	LatestSnapshot *time.Time
+}
+
+// Backup writes a consistent copy of the database to dest. It uses
+// VACUUM INTO, so it is safe to run while other processes (e.g. serve or
+// collect) hold the database open.
+func (db *DB) Backup(dest string) error {
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("%s already exists", dest)
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0700); err != nil {
+		return err
+	}
+
+	if _, err := db.Exec(`VACUUM INTO ?`, dest); err != nil {
+		return err
+	}
+
+	return os.Chmod(dest, 0600)
+}
+
+// Inspect opens a database file without migrating it, checks its integrity
+// and schema, and reports how many snapshots it holds.
+func Inspect(path string) (*BackupInfo, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+
+	conn, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var integrity string
+	if err := conn.QueryRow(`PRAGMA quick_check`).Scan(&integrity); err != nil {
+		return nil, fmt.Errorf("checking integrity: %w", err)
+	}
+	if integrity != "ok" {
+		return nil, fmt.Errorf("integrity check failed: %s", integrity)
+	}
+
+	// table_xinfo rather than table_info so the generated leftover column
+	// is listed.
+	rows, err := conn.Query(`PRAGMA table_xinfo(usage_snapshots)`)
+	if err != nil {
+		return nil, fmt.Errorf("reading schema: %w", err)
+	}
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var cid, notNull, pk, hidden int
+		var name, colType string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dflt, &pk, &hidden); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("reading schema: %w", err)
+		}
+		columns[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading schema: %w", err)
+	}
+
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("usage_snapshots table not found")
+	}
+	for _, col := range requiredColumns {
+		if !columns[col] {
+			return nil, fmt.Errorf("usage_snapshots is missing column %q", col)
+		}
+	}
+
+	info := &BackupInfo{Path: path}
+	if err := conn.QueryRow(`SELECT COUNT(*) FROM usage_snapshots`).Scan(&info.Snapshots); err != nil {
+		return nil, fmt.Errorf("counting snapshots: %w", err)
+	}
+	if info.Snapshots == 0 {
+		return info, nil
+	}
+
+	var first, latest time.Time
+	if err := conn.QueryRow(`SELECT collected_at FROM usage_snapshots ORDER BY collected_at ASC LIMIT 1`).Scan(&first); err != nil {
+		return nil, fmt.Errorf("reading first snapshot: %w", err)
+	}
+	if err := conn.QueryRow(`SELECT collected_at FROM usage_snapshots ORDER BY collected_at DESC LIMIT 1`).Scan(&latest); err != nil {
+		return nil, fmt.Errorf("reading latest snapshot: %w", err)
+	}
+	info.FirstSnapshot = &first
+	info.LatestSnapshot = &latest
+
+	return info, nil
+}
+
+// Restore replaces the database at livePath with a copy of src. The copy is
+// written next to the live file and renamed into place so readers never see
+// a partially written database. Callers are expected to validate src with
+// Inspect first.
+func Restore(livePath, src string) error {
+	if SameFile(livePath, src) {
+		return fmt.Errorf("%s is the live database", src)
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(livePath), 0700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(livePath), filepath.Base(livePath)+".restore-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, 0600); err != nil {
+		return err
+	}
+
+	// A leftover WAL from the old database would be replayed on top of the
+	// restored file, so drop it before swapping files. The last connection
+	// to close checkpoints and deletes the WAL, so one that is still there
+	// after opening and closing the database belongs to another process.
+	if _, err := os.Stat(livePath + "-wal"); err == nil {
+		conn, err := sql.Open("sqlite", livePath)
+		if err != nil {
+			return err
+		}
+		var version int
+		err = conn.QueryRow(`PRAGMA schema_version`).Scan(&version)
+		conn.Close()
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(livePath + "-wal"); err == nil {
+			return fmt.Errorf("%s is open in another process", livePath)
+		}
+	}
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(livePath + suffix); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return os.Rename(tmpPath, livePath)
+}
+
+// SameFile reports whether a and b name the same existing file.
+func SameFile(a, b string) bool {
+	ai, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bi, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(ai, bi)
+}
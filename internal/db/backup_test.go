@@ -0,0 +1,151 @@
+package db
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestDB(t *testing.T, path string, snapshots int) *DB {
+	t.Helper()
+	database, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	renews := time.Now().Add(time.Hour)
+	for i := range snapshots {
+		if err := database.InsertSnapshot(100, 10*(i+1), &renews); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return database
+}
+
+func TestBackupRestore_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	live := filepath.Join(dir, "usage.db")
+	database := newTestDB(t, live, 3)
+
+	dest := filepath.Join(dir, "backups", "usage-1.db")
+	if err := database.Backup(dest); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.Backup(dest); err == nil {
+		t.Fatal("backup overwrote an existing file")
+	}
+
+	info, err := Inspect(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Snapshots != 3 || info.FirstSnapshot == nil || info.LatestSnapshot == nil {
+		t.Fatalf("backup info = %+v", info)
+	}
+
+	// Diverge the live database, then restore the backup over it
+	renews := time.Now().Add(time.Hour)
+	if err := database.InsertSnapshot(100, 90, &renews); err != nil {
+		t.Fatal(err)
+	}
+	database.Close()
+	if err := Restore(live, dest); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := Inspect(live)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Snapshots != 3 {
+		t.Fatalf("restored %d snapshots, want 3", restored.Snapshots)
+	}
+	reopened := newTestDB(t, live, 0)
+	latest, err := reopened.GetLatestSnapshot()
+	if err != nil || latest == nil || latest.RequestsUsed != 30 {
+		t.Fatalf("latest after restore = %+v, %v", latest, err)
+	}
+}
+
+func TestRestore_RejectsLiveDatabase(t *testing.T) {
+	live := filepath.Join(t.TempDir(), "usage.db")
+	newTestDB(t, live, 1)
+
+	// The same file under another name is still the live database
+	alias := filepath.Join(filepath.Dir(live), "alias.db")
+	if err := os.Symlink(live, alias); err != nil {
+		t.Fatal(err)
+	}
+	if err := Restore(live, alias); err == nil || !strings.Contains(err.Error(), "live database") {
+		t.Fatalf("restoring the live database onto itself: err = %v", err)
+	}
+	if info, err := Inspect(live); err != nil || info.Snapshots != 1 {
+		t.Fatalf("live database after refused restore = %+v, %v", info, err)
+	}
+}
+
+func TestInspect_ForeignSchema(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		want   string
+	}{
+		{"no snapshots table", `CREATE TABLE notes (id INTEGER PRIMARY KEY, body TEXT)`, "table not found"},
+		{"missing column", `CREATE TABLE usage_snapshots (id INTEGER PRIMARY KEY, collected_at DATETIME, requests_used INTEGER)`, `missing column "subscription_limit"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "foreign.db")
+			conn, err := sql.Open("sqlite", path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = conn.Exec(tt.schema)
+			conn.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := Inspect(path); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Inspect error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestore_RefusesDatabaseOpenElsewhere(t *testing.T) {
+	dir := t.TempDir()
+	live := filepath.Join(dir, "usage.db")
+	src := filepath.Join(dir, "backup.db")
+	newTestDB(t, src, 3)
+
+	// Another process keeps the live database open in WAL mode
+	open := newTestDB(t, live, 1)
+	if _, err := open.Exec(`PRAGMA journal_mode=WAL`); err != nil {
+		t.Fatal(err)
+	}
+	if err := open.InsertSnapshot(100, 50, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(live + "-wal"); err != nil {
+		t.Fatalf("no WAL while the database is open: %v", err)
+	}
+
+	if err := Restore(live, src); err == nil || !strings.Contains(err.Error(), "open in another process") {
+		t.Fatalf("restoring over an open database: err = %v", err)
+	}
+	if _, err := os.Stat(live + "-wal"); err != nil {
+		t.Fatalf("WAL of the open database was removed: %v", err)
+	}
+
+	open.Close()
+	if err := Restore(live, src); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := Inspect(live); err != nil || info.Snapshots != 3 {
+		t.Fatalf("restored database = %+v, %v", info, err)
+	}
+}
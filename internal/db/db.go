@@ -83,6 +83,49 @@ SELECT
 FROM usage_snapshots
 GROUP BY strftime('%Y-W%W', collected_at)
 ORDER BY week DESC;
+
+CREATE TABLE IF NOT EXISTS anomalies (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    detected_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    interval_start TIMESTAMP NOT NULL,
+    interval_end TIMESTAMP NOT NULL UNIQUE,
+    consumed INTEGER NOT NULL,
+    rate REAL NOT NULL,
+    baseline_rate REAL NOT NULL,
+    score REAL NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS digest_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    channel TEXT NOT NULL,
+    period TEXT NOT NULL,
+    slot TIMESTAMP NOT NULL,
+    sent_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (channel, period, slot)
+);
+
+CREATE TABLE IF NOT EXISTS annotations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    at TIMESTAMP NOT NULL,
+    text TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_annotations_at ON annotations(at);
+
+CREATE TABLE IF NOT EXISTS proxy_requests (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    at TIMESTAMP NOT NULL,
+    client TEXT NOT NULL,
+    key_id TEXT NOT NULL DEFAULT '',
+    method TEXT NOT NULL,
+    path TEXT NOT NULL,
+    model TEXT NOT NULL DEFAULT '',
+    status INTEGER NOT NULL,
+    latency_ms INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_proxy_requests_at ON proxy_requests(at);
 	`)
 	if err != nil {
 		return err
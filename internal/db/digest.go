@@ -0,0 +1,17 @@
+package db
+
+import "time"
+
+// DigestSent reports whether the digest for slot was already delivered
+// through channel.
+func (db *DB) DigestSent(channel, period string, slot time.Time) (bool, error) {
+	var n int
+	err := db.QueryRow(`SELECT COUNT(*) FROM digest_log WHERE channel = ? AND period = ? AND slot = ?`, channel, period, slot.UTC()).Scan(&n)
+	return n > 0, err
+}
+
+// RecordDigest marks the digest for slot as delivered through channel.
+func (db *DB) RecordDigest(channel, period string, slot time.Time) error {
+	_, err := db.Exec(`INSERT OR IGNORE INTO digest_log (channel, period, slot) VALUES (?, ?, ?)`, channel, period, slot.UTC())
+	return err
+}
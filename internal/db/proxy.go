@@ -0,0 +1,65 @@
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+// ProxyRequest is a request forwarded by 'syntrack proxy'.
+type ProxyRequest struct {
+	At        time.Time
+	Client    string
+	KeyID     string
+	Method    string
+	Path      string
+	Model     string
+	Status    int
+	LatencyMs int64
+}
+
+// ProxyUsage aggregates proxied requests sharing a client or model.
+type ProxyUsage struct {
+	Name     string
+	Requests int
+	// Errors counts responses with status 400 or above.
+	Errors       int
+	AvgLatencyMs float64
+}
+
+// InsertProxyRequest records a proxied request.
+func (db *DB) InsertProxyRequest(r ProxyRequest) error {
+	_, err := db.Exec(
+		`INSERT INTO proxy_requests (at, client, key_id, method, path, model, status, latency_ms) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.At.UTC(), r.Client, r.KeyID, r.Method, r.Path, r.Model, r.Status, r.LatencyMs,
+	)
+	return err
+}
+
+// GetProxyUsage groups the requests proxied since by "client" or "model",
+// busiest first.
+func (db *DB) GetProxyUsage(since time.Time, by string) ([]ProxyUsage, error) {
+	if by != "client" && by != "model" {
+		return nil, fmt.Errorf("cannot group proxy requests by %q", by)
+	}
+	rows, err := db.Query(`
+		SELECT `+by+`, COUNT(*), SUM(CASE WHEN status >= 400 THEN 1 ELSE 0 END), AVG(latency_ms)
+		FROM proxy_requests
+		WHERE at >= ?
+		GROUP BY `+by+`
+		ORDER BY COUNT(*) DESC, `+by+` ASC
+	`, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var usage []ProxyUsage
+	for rows.Next() {
+		var u ProxyUsage
+		if err := rows.Scan(&u.Name, &u.Requests, &u.Errors, &u.AvgLatencyMs); err != nil {
+			return nil, err
+		}
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
@@ -136,3 +136,126 @@ func (db *DB) GetBurnRate(hours int) (float64, error) {
 	}
 	return float64(requestsDiff) / timeDiff, nil
 }
+
+// Interval is the consumption between two consecutive snapshots.
+type Interval struct {
+	Start    time.Time
+	End      time.Time
+	Consumed int
+}
+
+// Intervals derives per-interval consumption from snapshots in collection
+// order. A drop in requests used means the quota renewed in between; the
+// interval then counts the usage since the renewal.
+func Intervals(snapshots []UsageSnapshot) []Interval {
+	if len(snapshots) < 2 {
+		return nil
+	}
+	intervals := make([]Interval, 0, len(snapshots)-1)
+	for i := 1; i < len(snapshots); i++ {
+		prev, cur := snapshots[i-1], snapshots[i]
+		consumed := cur.RequestsUsed - prev.RequestsUsed
+		if consumed < 0 {
+			consumed = cur.RequestsUsed
+		}
+		intervals = append(intervals, Interval{Start: prev.CollectedAt, End: cur.CollectedAt, Consumed: consumed})
+	}
+	return intervals
+}
+
+// GetIntervals returns the consumption intervals between snapshots
+// collected since the given time.
+func (db *DB) GetIntervals(since time.Time) ([]Interval, error) {
+	snapshots, err := db.GetSnapshots(since)
+	if err != nil {
+		return nil, err
+	}
+	return Intervals(snapshots), nil
+}
+
+// LatestSnapshotID returns the highest snapshot ID, or 0 for an empty
+// database. It is a cheap way to notice new rows.
+func (db *DB) LatestSnapshotID() (int64, error) {
+	var id sql.NullInt64
+	if err := db.QueryRow(`SELECT MAX(id) FROM usage_snapshots`).Scan(&id); err != nil {
+		return 0, err
+	}
+	return id.Int64, nil
+}
+
+// Cycle describes the quota cycle that ends at RenewsAt.
+type Cycle struct {
+	RenewsAt time.Time
+	// Start is when the cycle began: the renewal time recorded by the
+	// previous cycle's snapshots if there are any, otherwise the first
+	// snapshot of this cycle.
+	Start time.Time
+	// StartKnown reports whether Start is an actual renewal time rather
+	// than the first snapshot.
+	StartKnown    bool
+	FirstSnapshot time.Time
+	// FirstUsed is the request count of the first snapshot.
+	FirstUsed int
+}
+
+// cycleTolerance treats renewal times this close together as one cycle,
+// since the API may report them with slight jitter.
+const cycleTolerance = time.Minute
+
+// GetCurrentCycle returns the cycle of the latest snapshot, or nil if no
+// renewal time has been recorded.
+func (db *DB) GetCurrentCycle() (*Cycle, error) {
+	cycles, err := db.GetCycles(1)
+	if err != nil || len(cycles) == 0 {
+		return nil, err
+	}
+	return &cycles[0], nil
+}
+
+// GetCycles returns up to n cycles, newest first. It stops at the first
+// snapshot without a renewal time.
+func (db *DB) GetCycles(n int) ([]Cycle, error) {
+	rows, err := db.Query(`SELECT collected_at, requests_used, renews_at FROM usage_snapshots ORDER BY collected_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cycles []Cycle
+	var cycle *Cycle
+	for rows.Next() && len(cycles) < n {
+		var collectedAt time.Time
+		var used int
+		var renewsAt sql.NullTime
+		if err := rows.Scan(&collectedAt, &used, &renewsAt); err != nil {
+			return nil, err
+		}
+		if !renewsAt.Valid {
+			break
+		}
+
+		if cycle != nil {
+			diff := renewsAt.Time.Sub(cycle.RenewsAt)
+			if diff >= -cycleTolerance && diff <= cycleTolerance {
+				cycle.Start = collectedAt
+				cycle.FirstSnapshot = collectedAt
+				cycle.FirstUsed = used
+				continue
+			}
+			// The previous cycle renewed when this one started
+			if renewsAt.Time.Before(cycle.RenewsAt) && !renewsAt.Time.After(cycle.FirstSnapshot) {
+				cycle.Start = renewsAt.Time
+				cycle.StartKnown = true
+			}
+			cycles = append(cycles, *cycle)
+		}
+		cycle = &Cycle{RenewsAt: renewsAt.Time, Start: collectedAt, FirstSnapshot: collectedAt, FirstUsed: used}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if cycle != nil && len(cycles) < n {
+		cycles = append(cycles, *cycle)
+	}
+	return cycles, nil
+}
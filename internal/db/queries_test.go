@@ -0,0 +1,20 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIntervals_Renewal(t *testing.T) {
+	now := time.Date(2025, 1, 13, 10, 0, 0, 0, time.UTC)
+	snapshots := []UsageSnapshot{
+		{CollectedAt: now, RequestsUsed: 90},
+		{CollectedAt: now.Add(time.Hour), RequestsUsed: 100},
+		{CollectedAt: now.Add(2 * time.Hour), RequestsUsed: 5},
+	}
+
+	intervals := Intervals(snapshots)
+	if len(intervals) != 2 || intervals[0].Consumed != 10 || intervals[1].Consumed != 5 {
+		t.Fatalf("unexpected intervals: %+v", intervals)
+	}
+}
@@ -0,0 +1,82 @@
+// Package digest builds the periodic usage summary and delivers it by
+// email or webhook.
+package digest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Digest summarizes consumption over a period ending at To.
+type Digest struct {
+	Period   string    `json:"period"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Consumed int       `json:"consumed"`
+	Used     int       `json:"used"`
+	Limit    int       `json:"limit"`
+	Leftover int       `json:"leftover"`
+	// BurnRate is the 24h rate in requests per hour.
+	BurnRate float64    `json:"burn_rate_per_hour"`
+	RenewsAt *time.Time `json:"renews_at,omitempty"`
+	// ExhaustsAt is when the quota runs out at BurnRate, if that happens
+	// before the renewal.
+	ExhaustsAt *time.Time `json:"exhausts_at,omitempty"`
+	Pace       string     `json:"pace,omitempty"`
+	Events     []Event    `json:"events"`
+}
+
+// Event is something notable that happened during the period.
+type Event struct {
+	At      time.Time `json:"at"`
+	Kind    string    `json:"kind"`
+	Message string    `json:"message"`
+}
+
+// Sender delivers a digest through one channel.
+type Sender interface {
+	// Name identifies the channel in the digest log.
+	Name() string
+	Send(ctx context.Context, d Digest) error
+}
+
+// Subject is the one-line title used for emails.
+func (d Digest) Subject() string {
+	return fmt.Sprintf("Syntrack %s digest: %d requests consumed, %d left", d.Period, d.Consumed, d.Leftover)
+}
+
+// Text renders the digest as plain text.
+func (d Digest) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Usage from %s to %s\n\n", d.From.Local().Format("2006-01-02 15:04"), d.To.Local().Format("2006-01-02 15:04"))
+	fmt.Fprintf(&b, "Consumed:   %d requests\n", d.Consumed)
+	pct := 0.0
+	if d.Limit > 0 {
+		pct = float64(d.Used) / float64(d.Limit) * 100
+	}
+	fmt.Fprintf(&b, "Quota:      %d / %d used (%.1f%%), %d left\n", d.Used, d.Limit, pct, d.Leftover)
+	fmt.Fprintf(&b, "Burn rate:  %.2f requests/hour (24h)\n", d.BurnRate)
+	if d.Pace != "" {
+		fmt.Fprintf(&b, "Pace:       %s\n", d.Pace)
+	}
+	if d.RenewsAt != nil {
+		fmt.Fprintf(&b, "Renews:     %s\n", d.RenewsAt.Local().Format("2006-01-02 15:04"))
+	}
+	switch {
+	case d.ExhaustsAt != nil:
+		fmt.Fprintf(&b, "Projection: runs out %s, before renewal\n", d.ExhaustsAt.Local().Format("2006-01-02 15:04"))
+	case d.BurnRate > 0:
+		b.WriteString("Projection: lasts until renewal\n")
+	}
+
+	b.WriteString("\nEvents:\n")
+	if len(d.Events) == 0 {
+		b.WriteString("  none\n")
+	}
+	for _, e := range d.Events {
+		fmt.Fprintf(&b, "  %s  %s\n", e.At.Local().Format("2006-01-02 15:04"), e.Message)
+	}
+	return b.String()
+}
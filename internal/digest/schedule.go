@@ -0,0 +1,66 @@
+package digest
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	Daily  = "daily"
+	Weekly = "weekly"
+)
+
+// Schedule sends a digest every day, or every week on Weekday, at
+// Hour:Minute in Location.
+type Schedule struct {
+	Period   string
+	Hour     int
+	Minute   int
+	Weekday  time.Weekday
+	Location *time.Location
+}
+
+// NewSchedule parses a time of day given as HH:MM.
+func NewSchedule(period, at string, weekday time.Weekday, loc *time.Location) (Schedule, error) {
+	if period != Daily && period != Weekly {
+		return Schedule{}, fmt.Errorf("unknown digest period %q (valid: daily, weekly)", period)
+	}
+	t, err := time.Parse("15:04", at)
+	if err != nil {
+		return Schedule{}, fmt.Errorf("digest time %q is not HH:MM", at)
+	}
+	return Schedule{Period: period, Hour: t.Hour(), Minute: t.Minute(), Weekday: weekday, Location: loc}, nil
+}
+
+// Slot returns the latest scheduled time at or before now. The digest
+// for a slot covers the period ending at it.
+func (s Schedule) Slot(now time.Time) time.Time {
+	now = now.In(s.Location)
+	y, m, d := now.Date()
+	slot := time.Date(y, m, d, s.Hour, s.Minute, 0, 0, s.Location)
+	if slot.After(now) {
+		slot = slot.AddDate(0, 0, -1)
+	}
+	if s.Period == Weekly {
+		back := (int(slot.Weekday()) - int(s.Weekday) + 7) % 7
+		slot = slot.AddDate(0, 0, -back)
+	}
+	return slot
+}
+
+// Next returns the first scheduled time after now.
+func (s Schedule) Next(now time.Time) time.Time {
+	slot := s.Slot(now)
+	if s.Period == Weekly {
+		return slot.AddDate(0, 0, 7)
+	}
+	return slot.AddDate(0, 0, 1)
+}
+
+// Start returns the beginning of the period ending at slot.
+func (s Schedule) Start(slot time.Time) time.Time {
+	if s.Period == Weekly {
+		return slot.AddDate(0, 0, -7)
+	}
+	return slot.AddDate(0, 0, -1)
+}
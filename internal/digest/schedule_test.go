@@ -0,0 +1,57 @@
+package digest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleSlot(t *testing.T) {
+	loc := time.FixedZone("test", 2*3600)
+	at := func(day, hour, min int) time.Time { return time.Date(2025, 1, day, hour, min, 0, 0, loc) }
+
+	daily, err := NewSchedule(Daily, "08:30", time.Monday, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Wednesday 2025-01-15
+	for now, want := range map[time.Time]time.Time{
+		at(15, 8, 30): at(15, 8, 30),
+		at(15, 8, 29): at(14, 8, 30),
+		at(15, 23, 0): at(15, 8, 30),
+	} {
+		if got := daily.Slot(now); !got.Equal(want) {
+			t.Errorf("daily slot at %v = %v, want %v", now, got, want)
+		}
+	}
+	if got := daily.Next(at(15, 9, 0)); !got.Equal(at(16, 8, 30)) {
+		t.Errorf("daily next = %v", got)
+	}
+	if got := daily.Start(at(15, 8, 30)); !got.Equal(at(14, 8, 30)) {
+		t.Errorf("daily start = %v", got)
+	}
+
+	weekly, err := NewSchedule(Weekly, "08:00", time.Monday, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for now, want := range map[time.Time]time.Time{
+		at(15, 12, 0): at(13, 8, 0),
+		at(13, 8, 0):  at(13, 8, 0),
+		at(13, 7, 59): at(6, 8, 0),
+		at(19, 23, 0): at(13, 8, 0),
+	} {
+		if got := weekly.Slot(now); !got.Equal(want) {
+			t.Errorf("weekly slot at %v = %v, want %v", now, got, want)
+		}
+	}
+	if got := weekly.Next(at(15, 12, 0)); !got.Equal(at(20, 8, 0)) {
+		t.Errorf("weekly next = %v", got)
+	}
+
+	if _, err := NewSchedule("hourly", "08:00", time.Monday, loc); err == nil {
+		t.Error("expected an error for an unknown period")
+	}
+	if _, err := NewSchedule(Daily, "8am", time.Monday, loc); err == nil {
+		t.Error("expected an error for a malformed time")
+	}
+}
@@ -0,0 +1,198 @@
+package digest
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testDigest() Digest {
+	renews := time.Date(2025, 1, 16, 0, 0, 0, 0, time.UTC)
+	return Digest{
+		Period:   Daily,
+		From:     time.Date(2025, 1, 14, 8, 0, 0, 0, time.UTC),
+		To:       time.Date(2025, 1, 15, 8, 0, 0, 0, time.UTC),
+		Consumed: 42,
+		Used:     90,
+		Limit:    135,
+		Leftover: 45,
+		BurnRate: 1.75,
+		RenewsAt: &renews,
+		Events:   []Event{{At: time.Date(2025, 1, 14, 20, 0, 0, 0, time.UTC), Kind: "anomaly", Message: "Spike: 30 requests"}},
+	}
+}
+
+func TestWebhookSender(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type %q", ct)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decoding body: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	s, err := NewWebhookSender(srv.URL, `{"text": {{json .Subject}}, "left": {{.Leftover}}, "events": {{len .Events}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Send(context.Background(), testDigest()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if got["text"] != "Syntrack daily digest: 42 requests consumed, 45 left" || got["left"] != 45.0 || got["events"] != 1.0 {
+		t.Fatalf("unexpected body %v", got)
+	}
+
+	// The default template posts the digest itself
+	s, _ = NewWebhookSender(srv.URL, "")
+	if err := s.Send(context.Background(), testDigest()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if got["consumed"] != 42.0 || got["period"] != "daily" {
+		t.Fatalf("unexpected default body %v", got)
+	}
+}
+
+func TestWebhookSender_Errors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "rate limited", http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	s, _ := NewWebhookSender(srv.URL, "")
+	if err := s.Send(context.Background(), testDigest()); err == nil || !strings.Contains(err.Error(), "429") {
+		t.Fatalf("expected a 429 error, got %v", err)
+	}
+
+	// Unquoted text breaks the JSON and must not be sent
+	s, _ = NewWebhookSender(srv.URL, `{"text": "{{.Text}}"}`)
+	if _, err := s.Body(testDigest()); err == nil {
+		t.Fatal("expected invalid JSON to be rejected")
+	}
+	if _, err := NewWebhookSender(srv.URL, "{{"); err == nil {
+		t.Fatal("expected a template parse error")
+	}
+}
+
+// fakeSMTP accepts one session and returns the envelope and message.
+func fakeSMTP(t *testing.T) (host string, port int, result chan []string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	result = make(chan []string, 1)
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { io.WriteString(conn, s+"\r\n") }
+
+		var got []string
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			switch cmd {
+			case "EHLO":
+				reply("250-localhost")
+				reply("250 AUTH PLAIN")
+			case "AUTH":
+				got = append(got, line)
+				reply("235 ok")
+			case "MAIL", "RCPT":
+				got = append(got, line)
+				reply("250 ok")
+			case "DATA":
+				reply("354 go ahead")
+				var msg strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+					msg.WriteString(l)
+				}
+				got = append(got, msg.String())
+				reply("250 queued")
+			case "QUIT":
+				reply("221 bye")
+				result <- got
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	return "localhost", addr.Port, result
+}
+
+func TestSMTPSender(t *testing.T) {
+	host, port, result := fakeSMTP(t)
+	s := &SMTPSender{
+		Host:     host,
+		Port:     port,
+		Username: "syntrack",
+		Password: "secret",
+		From:     "syntrack@example.com",
+		To:       []string{"a@example.com", "b@example.com"},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Send(ctx, testDigest()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	got := <-result
+	if len(got) != 5 {
+		t.Fatalf("unexpected session %q", got)
+	}
+	if !strings.HasPrefix(got[0], "AUTH PLAIN") || got[1] != "MAIL FROM:<syntrack@example.com>" || got[3] != "RCPT TO:<b@example.com>" {
+		t.Fatalf("unexpected envelope %q", got[:4])
+	}
+	msg := got[4]
+	for _, want := range []string{
+		"Subject: Syntrack daily digest: 42 requests consumed, 45 left\r\n",
+		"To: a@example.com, b@example.com\r\n",
+		"Consumed:   42 requests\r\n",
+		"Spike: 30 requests",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("message lacks %q:\n%s", want, msg)
+		}
+	}
+}
+
+func TestSMTPSender_ConnectionRefused(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	s := &SMTPSender{Host: "127.0.0.1", Port: port, From: "a@example.com", To: []string{"b@example.com"}}
+	if err := s.Send(context.Background(), testDigest()); err == nil {
+		t.Fatalf("expected an error connecting to port %s", strconv.Itoa(port))
+	}
+}
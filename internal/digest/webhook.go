@@ -0,0 +1,78 @@
+package digest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"text/template"
+)
+
+// DefaultTemplate posts the digest itself as JSON.
+const DefaultTemplate = `{{json .}}`
+
+// WebhookSender posts the digest as JSON rendered from a template.
+type WebhookSender struct {
+	URL      string
+	Template *template.Template
+	Client   *http.Client
+}
+
+// NewWebhookSender parses tmpl, a text/template producing the request
+// body. Besides the digest's fields it can use .Subject and .Text, and
+// the json function to quote any value, e.g. {"text": {{json .Text}}}.
+func NewWebhookSender(url, tmpl string) (*WebhookSender, error) {
+	if tmpl == "" {
+		tmpl = DefaultTemplate
+	}
+	t, err := template.New("webhook").Funcs(template.FuncMap{"json": toJSON}).Parse(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("parsing webhook template: %w", err)
+	}
+	return &WebhookSender{URL: url, Template: t, Client: http.DefaultClient}, nil
+}
+
+func toJSON(v any) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+func (s *WebhookSender) Name() string { return "webhook" }
+
+// Body renders the request body and checks that it is valid JSON.
+func (s *WebhookSender) Body(d Digest) ([]byte, error) {
+	var body bytes.Buffer
+	if err := s.Template.Execute(&body, d); err != nil {
+		return nil, fmt.Errorf("rendering webhook template: %w", err)
+	}
+	if !json.Valid(body.Bytes()) {
+		return nil, fmt.Errorf("webhook template did not produce valid JSON")
+	}
+	return body.Bytes(), nil
+}
+
+func (s *WebhookSender) Send(ctx context.Context, d Digest) error {
+	body, err := s.Body(d)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "syntrack")
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting digest: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
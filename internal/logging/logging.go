@@ -0,0 +1,72 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+type Options struct {
+	Level      string
+	Format     string
+	File       string
+	MaxSizeMB  int
+	MaxBackups int
+}
+
+// Setup installs the default slog logger described by opts. Logs go to
+// stderr unless a file is given, in which case they go to a size-rotated
+// file instead. The returned closer releases the file, if any.
+func Setup(opts Options) (io.Closer, error) {
+	level, err := ParseLevel(opts.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	var out io.Writer = os.Stderr
+	var closer io.Closer = nopCloser{}
+	if opts.File != "" {
+		f, err := OpenRotatingFile(opts.File, int64(opts.MaxSizeMB)*1024*1024, opts.MaxBackups)
+		if err != nil {
+			return nil, fmt.Errorf("opening log file: %w", err)
+		}
+		out = f
+		closer = f
+	}
+
+	handlerOpts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch strings.ToLower(opts.Format) {
+	case "", "text":
+		handler = slog.NewTextHandler(out, handlerOpts)
+	case "json":
+		handler = slog.NewJSONHandler(out, handlerOpts)
+	default:
+		closer.Close()
+		return nil, fmt.Errorf("unknown log format %q (valid: text, json)", opts.Format)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return closer, nil
+}
+
+// ParseLevel accepts debug, info, warn and error.
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q (valid: debug, info, warn, error)", s)
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,62 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRotatingFile_RotatesAndKeepsBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "syntrack.log")
+
+	f, err := OpenRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatalf("OpenRotatingFile: %v", err)
+	}
+	defer f.Close()
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+
+	want := map[string]string{
+		path:        "fourth\n",
+		path + ".1": "third\n",
+		path + ".2": "second\n",
+	}
+	for file, content := range want {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("reading %s: %v", file, err)
+		}
+		if string(data) != content {
+			t.Fatalf("%s: expected %q, got %q", file, content, data)
+		}
+	}
+
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Fatal("expected only 2 backups to be kept")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Fatalf("expected log file mode 0600, got %o", perm)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for _, level := range []string{"debug", "INFO", "warn", "error", ""} {
+		if _, err := ParseLevel(level); err != nil {
+			t.Fatalf("ParseLevel(%q): %v", level, err)
+		}
+	}
+	if _, err := ParseLevel("verbose"); err == nil || !strings.Contains(err.Error(), "unknown log level") {
+		t.Fatalf("expected error for unknown level, got %v", err)
+	}
+}
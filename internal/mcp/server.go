@@ -0,0 +1,222 @@
+// Package mcp implements the tools part of the Model Context Protocol:
+// JSON-RPC 2.0 messages, one per line, over a pair of streams.
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+)
+
+// ProtocolVersion is the latest protocol revision the server speaks.
+const ProtocolVersion = "2025-06-18"
+
+// supportedVersions are accepted from clients; others get ProtocolVersion.
+var supportedVersions = []string{"2024-11-05", "2025-03-26", ProtocolVersion}
+
+// JSON-RPC error codes.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+// maxMessageSize bounds a single incoming message.
+const maxMessageSize = 4 << 20
+
+// Tool is a function the client may call.
+type Tool struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// InputSchema is the JSON Schema of the arguments.
+	InputSchema json.RawMessage `json:"inputSchema"`
+	// Call returns the result, sent to the client as JSON text. An error
+	// is reported to the model as a failed tool call.
+	Call func(ctx context.Context, args json.RawMessage) (any, error) `json:"-"`
+}
+
+// Server answers requests for a fixed set of tools.
+type Server struct {
+	Name    string
+	Version string
+	Tools   []Tool
+}
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string { return e.Message }
+
+type textContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type callResult struct {
+	Content []textContent `json:"content"`
+	IsError bool          `json:"isError,omitempty"`
+}
+
+// Serve reads requests from in and writes responses to out until in is
+// closed or ctx is done. Requests are handled one at a time. Reading
+// happens in the background, so Serve returns as soon as ctx is done even
+// while a read from in is blocked; that read is abandoned.
+func (s *Server) Serve(ctx context.Context, in io.Reader, out io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	lines := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(in)
+		scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+		for scanner.Scan() {
+			select {
+			case lines <- bytes.Clone(scanner.Bytes()):
+			case <-ctx.Done():
+				return
+			}
+		}
+		readErr <- scanner.Err()
+	}()
+
+	for {
+		var line []byte
+		select {
+		case <-ctx.Done():
+			return nil
+		case l, ok := <-lines:
+			if !ok {
+				select {
+				case err := <-readErr:
+					return err
+				default:
+					return nil
+				}
+			}
+			line = l
+		}
+		if len(line) == 0 {
+			continue
+		}
+		resp := s.handle(ctx, line)
+		if resp == nil {
+			continue
+		}
+		resp.JSONRPC = "2.0"
+		data, err := json.Marshal(resp)
+		if err != nil {
+			return fmt.Errorf("encoding response: %w", err)
+		}
+		if _, err := out.Write(append(data, '\n')); err != nil {
+			return err
+		}
+	}
+}
+
+// handle answers one message, or returns nil for notifications.
+func (s *Server) handle(ctx context.Context, line []byte) *response {
+	var req request
+	if err := json.Unmarshal(line, &req); err != nil {
+		return &response{ID: json.RawMessage("null"), Error: &rpcError{codeParseError, "parse error"}}
+	}
+	// Notifications have no ID and get no response
+	notification := len(req.ID) == 0 || string(req.ID) == "null"
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		if notification {
+			return nil
+		}
+		return &response{ID: req.ID, Error: &rpcError{codeInvalidRequest, "invalid request"}}
+	}
+
+	result, err := s.dispatch(ctx, req)
+	if notification {
+		return nil
+	}
+	if err != nil {
+		var rerr *rpcError
+		if !errors.As(err, &rerr) {
+			rerr = &rpcError{codeInvalidParams, err.Error()}
+		}
+		return &response{ID: req.ID, Error: rerr}
+	}
+	return &response{ID: req.ID, Result: result}
+}
+
+func (s *Server) dispatch(ctx context.Context, req request) (any, error) {
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		json.Unmarshal(req.Params, &params)
+		version := ProtocolVersion
+		if slices.Contains(supportedVersions, params.ProtocolVersion) {
+			version = params.ProtocolVersion
+		}
+		return map[string]any{
+			"protocolVersion": version,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]string{"name": s.Name, "version": s.Version},
+		}, nil
+	case "ping", "notifications/initialized", "notifications/cancelled":
+		return map[string]any{}, nil
+	case "tools/list":
+		return map[string]any{"tools": s.Tools}, nil
+	case "tools/call":
+		return s.callTool(ctx, req.Params)
+	}
+	return nil, &rpcError{codeMethodNotFound, fmt.Sprintf("method not found: %s", req.Method)}
+}
+
+func (s *Server) callTool(ctx context.Context, raw json.RawMessage) (any, error) {
+	var params struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+	i := slices.IndexFunc(s.Tools, func(t Tool) bool { return t.Name == params.Name })
+	if i < 0 {
+		return nil, fmt.Errorf("unknown tool: %s", params.Name)
+	}
+	if len(params.Arguments) == 0 || string(params.Arguments) == "null" {
+		params.Arguments = json.RawMessage("{}")
+	}
+
+	result, err := s.Tools[i].Call(ctx, params.Arguments)
+	if err != nil {
+		slog.Debug("tool failed", "tool", params.Name, "err", err)
+		return callResult{Content: []textContent{{"text", err.Error()}}, IsError: true}, nil
+	}
+	text, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encoding result: %w", err)
+	}
+	return callResult{Content: []textContent{{"text", string(text)}}}, nil
+}
@@ -0,0 +1,123 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer(t *testing.T) {
+	s := &Server{Name: "syntrack", Version: "test", Tools: []Tool{
+		{
+			Name:        "add",
+			InputSchema: json.RawMessage(`{"type": "object"}`),
+			Call: func(ctx context.Context, args json.RawMessage) (any, error) {
+				var p struct{ A, B int }
+				if err := json.Unmarshal(args, &p); err != nil {
+					return nil, err
+				}
+				return map[string]int{"sum": p.A + p.B}, nil
+			},
+		},
+		{
+			Name:        "fail",
+			InputSchema: json.RawMessage(`{"type": "object"}`),
+			Call: func(ctx context.Context, args json.RawMessage) (any, error) {
+				return nil, errors.New("no data")
+			},
+		},
+	}}
+
+	in := strings.Join([]string{
+		`{"jsonrpc": "2.0", "id": 1, "method": "initialize", "params": {"protocolVersion": "2024-11-05"}}`,
+		`{"jsonrpc": "2.0", "method": "notifications/initialized"}`,
+		`{"jsonrpc": "2.0", "id": "two", "method": "tools/list"}`,
+		`{"jsonrpc": "2.0", "id": 3, "method": "tools/call", "params": {"name": "add", "arguments": {"a": 2, "b": 3}}}`,
+		`{"jsonrpc": "2.0", "id": 4, "method": "tools/call", "params": {"name": "fail"}}`,
+		`{"jsonrpc": "2.0", "id": 5, "method": "tools/call", "params": {"name": "missing"}}`,
+		`{"jsonrpc": "2.0", "id": 6, "method": "prompts/list"}`,
+		`{not json`,
+	}, "\n")
+	var out bytes.Buffer
+	if err := s.Serve(context.Background(), strings.NewReader(in), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	type resp struct {
+		ID     json.RawMessage `json:"id"`
+		Result struct {
+			ProtocolVersion string `json:"protocolVersion"`
+			Tools           []Tool `json:"tools"`
+			Content         []struct {
+				Text string `json:"text"`
+			} `json:"content"`
+			IsError bool `json:"isError"`
+		} `json:"result"`
+		Error *rpcError `json:"error"`
+	}
+	var resps []resp
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var r resp
+		if err := dec.Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+		resps = append(resps, r)
+	}
+
+	// The notification gets no response
+	if len(resps) != 7 {
+		t.Fatalf("got %d responses, want 7", len(resps))
+	}
+	if r := resps[0]; r.Result.ProtocolVersion != "2024-11-05" {
+		t.Fatalf("initialize negotiated %q", r.Result.ProtocolVersion)
+	}
+	if r := resps[1]; string(r.ID) != `"two"` || len(r.Result.Tools) != 2 || r.Result.Tools[0].Name != "add" {
+		t.Fatalf("tools/list = %s %+v", r.ID, r.Result.Tools)
+	}
+	if r := resps[2]; r.Result.IsError || len(r.Result.Content) != 1 || !strings.Contains(r.Result.Content[0].Text, `"sum": 5`) {
+		t.Fatalf("tools/call add = %+v", r.Result)
+	}
+	if r := resps[3]; !r.Result.IsError || r.Result.Content[0].Text != "no data" {
+		t.Fatalf("tools/call fail = %+v", r.Result)
+	}
+	for i, code := range map[int]int{4: codeInvalidParams, 5: codeMethodNotFound, 6: codeParseError} {
+		if r := resps[i]; r.Error == nil || r.Error.Code != code {
+			t.Fatalf("response %d error = %+v, want code %d", i, r.Error, code)
+		}
+	}
+}
+
+func TestServe_ReturnsWhenContextDoneWhileReading(t *testing.T) {
+	s := &Server{Name: "syntrack", Version: "test"}
+	inR, inW := io.Pipe()
+	defer inW.Close()
+	outR, outW := io.Pipe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(ctx, inR, outW) }()
+
+	// A request is answered while the input stays open
+	go inW.Write([]byte(`{"jsonrpc": "2.0", "id": 1, "method": "ping"}` + "\n"))
+	line, err := bufio.NewReader(outR).ReadString('\n')
+	if err != nil || !strings.Contains(line, `"id":1`) {
+		t.Fatalf("response = %q, %v", line, err)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Serve still blocked reading after ctx was cancelled")
+	}
+}
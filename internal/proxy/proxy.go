@@ -0,0 +1,191 @@
+// Package proxy forwards requests to the Synthetic API and reports each
+// one, so consumption can be attributed to the clients making them.
+package proxy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ClientHeader lets a client name itself, e.g. "nightly-eval". It is
+// removed before the request is forwarded.
+const ClientHeader = "X-Syntrack-Client"
+
+// maxModelPeek is how much of a request body is searched for the model.
+const maxModelPeek = 1 << 20
+
+// Request describes a forwarded request.
+type Request struct {
+	At time.Time
+	// Client is named by ClientName.
+	Client string
+	// KeyID identifies the API key without revealing it; empty when the
+	// request had none.
+	KeyID   string
+	Method  string
+	Path    string
+	Model   string
+	Status  int
+	Latency time.Duration
+}
+
+// Proxy is an http.Handler forwarding to an upstream API.
+type Proxy struct {
+	// Labels maps key IDs to client names.
+	Labels map[string]string
+	// Record is called after each request has been answered.
+	Record func(Request)
+
+	rp *httputil.ReverseProxy
+}
+
+// New returns a proxy forwarding to upstream, e.g.
+// https://api.synthetic.new.
+func New(upstream *url.URL) *Proxy {
+	p := &Proxy{}
+	p.rp = &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(upstream)
+			r.Out.Header.Del(ClientHeader)
+		},
+		// Stream completions as they arrive
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			slog.Warn("proxying request", "path", r.URL.Path, "err", err)
+			w.WriteHeader(http.StatusBadGateway)
+		},
+	}
+	return p
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	req := Request{
+		At:     start,
+		KeyID:  KeyID(bearerToken(r)),
+		Method: r.Method,
+		Path:   r.URL.Path,
+	}
+	req.Client = ClientName(r, p.Labels)
+	if r.Body != nil && strings.Contains(r.Header.Get("Content-Type"), "json") {
+		peek, err := io.ReadAll(io.LimitReader(r.Body, maxModelPeek))
+		if err == nil {
+			req.Model = modelField(peek)
+		}
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(peek), r.Body), r.Body}
+	}
+
+	rec := &statusRecorder{ResponseWriter: w}
+	p.rp.ServeHTTP(rec, r)
+	req.Status = rec.status
+	if req.Status == 0 {
+		req.Status = http.StatusOK
+	}
+	req.Latency = time.Since(start)
+	if p.Record != nil {
+		p.Record(req)
+	}
+}
+
+// ClientName names the client making r: its ClientHeader, the label for
+// its API key in labels (by key ID), "key:" followed by the key ID, or
+// "anonymous".
+func ClientName(r *http.Request, labels map[string]string) string {
+	if name := strings.TrimSpace(r.Header.Get(ClientHeader)); name != "" {
+		return name
+	}
+	if label := KeyLabel(r, labels); label != "" {
+		return label
+	}
+	if keyID := KeyID(bearerToken(r)); keyID != "" {
+		return "key:" + keyID
+	}
+	return "anonymous"
+}
+
+// KeyLabel returns the label for the API key of r in labels (by key ID),
+// or "". Unlike ClientHeader, the client cannot pick it without holding
+// the key.
+func KeyLabel(r *http.Request, labels map[string]string) string {
+	return labels[KeyID(bearerToken(r))]
+}
+
+// KeyID returns a short fingerprint of an API key, or "" for none.
+func KeyID(key string) string {
+	if key == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:6])
+}
+
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
+
+// modelField returns the top-level "model" string of a JSON object. data
+// may be truncated after the field.
+func modelField(data []byte) string {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return ""
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return ""
+		}
+		if key, _ := tok.(string); key == "model" {
+			var model string
+			if err := dec.Decode(&model); err != nil {
+				return ""
+			}
+			return model
+		}
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return ""
+		}
+	}
+	return ""
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController flush streamed responses.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,96 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestProxy_ForwardsAndRecords(t *testing.T) {
+	var gotBody, gotAuth, gotClient, gotPath string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		gotBody, gotAuth, gotClient, gotPath = string(b), r.Header.Get("Authorization"), r.Header.Get(ClientHeader), r.URL.RequestURI()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTeapot)
+		io.WriteString(w, `{"ok":true}`)
+	}))
+	defer upstream.Close()
+
+	u, _ := url.Parse(upstream.URL)
+	p := New(u)
+	p.Labels = map[string]string{KeyID("sk-alice"): "alice"}
+	var records []Request
+	p.Record = func(r Request) { records = append(records, r) }
+
+	body := `{"messages": [{"role": "user", "content": "hi"}], "model": "hf:deepseek-ai/DeepSeek-V3"}`
+	req := httptest.NewRequest(http.MethodPost, "/openai/v1/chat/completions?x=1", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer sk-alice")
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusTeapot || rec.Body.String() != `{"ok":true}` {
+		t.Fatalf("response = %d %q", rec.Code, rec.Body)
+	}
+	if gotBody != body || gotAuth != "Bearer sk-alice" || gotPath != "/openai/v1/chat/completions?x=1" {
+		t.Fatalf("upstream got body %q, auth %q, path %q", gotBody, gotAuth, gotPath)
+	}
+	if len(records) != 1 {
+		t.Fatalf("recorded %d requests, want 1", len(records))
+	}
+	r := records[0]
+	if r.Client != "alice" || r.KeyID != KeyID("sk-alice") || r.Model != "hf:deepseek-ai/DeepSeek-V3" ||
+		r.Status != http.StatusTeapot || r.Path != "/openai/v1/chat/completions" || r.Method != http.MethodPost {
+		t.Fatalf("record = %+v", r)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v2/quotas", nil)
+	req.Header.Set(ClientHeader, "nightly-eval")
+	p.ServeHTTP(httptest.NewRecorder(), req)
+	if gotClient != "" {
+		t.Fatalf("upstream got %s %q, want it removed", ClientHeader, gotClient)
+	}
+	if r := records[1]; r.Client != "nightly-eval" || r.KeyID != "" || r.Model != "" {
+		t.Fatalf("record = %+v", r)
+	}
+}
+
+func TestProxy_UpstreamDown(t *testing.T) {
+	upstream := httptest.NewServer(http.NotFoundHandler())
+	u, _ := url.Parse(upstream.URL)
+	upstream.Close()
+
+	p := New(u)
+	var got Request
+	p.Record = func(r Request) { got = r }
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v2/quotas", nil))
+
+	if rec.Code != http.StatusBadGateway || got.Status != http.StatusBadGateway || got.Client != "anonymous" {
+		t.Fatalf("response %d, record %+v", rec.Code, got)
+	}
+}
+
+func TestModelField(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{`{"model": "a"}`, "a"},
+		{`{"messages": [{"model": "nested"}], "model": "b", "stream": true}`, "b"},
+		{`{"model": "c", "messages": [{"content": "trunc`, "c"},
+		{`{"messages": [{"content": "trunc`, ""},
+		{`{"model": 3}`, ""},
+		{`[1, 2]`, ""},
+		{``, ""},
+	}
+	for _, tt := range tests {
+		if got := modelField([]byte(tt.body)); got != tt.want {
+			t.Errorf("modelField(%q) = %q, want %q", tt.body, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,572 @@
+package tokens
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Prefix is prepended to every generated token so they are easy to
+	// recognise in configs and secret scanners.
+	Prefix = "syntrack_token_"
+
+	fileVersion = 1
+
+	// lastUsedSuffix names the file next to the token file that records
+	// when each token was last used. Servers write only that file, so
+	// recording use never races 'syntrack token' editing the token file.
+	lastUsedSuffix = ".last-used"
+)
+
+// Token is a stored authentication token. Only the SHA-256 hash of the
+// secret is kept; tokens are 32 random bytes, so a plain hash is enough to
+// make the file useless to an attacker who reads it.
+type Token struct {
+	ID        string     `json:"id"`
+	Label     string     `json:"label,omitempty"`
+	Hash      string     `json:"hash"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	LastUsed  *time.Time `json:"last_used,omitempty"`
+
+	// ephemeral tokens come from the environment and are never written to
+	// the token file.
+	ephemeral bool
+}
+
+// Expired reports whether the token has an expiry date in the past.
+func (t Token) Expired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
+
+// Ephemeral reports whether the token was supplied through the environment
+// rather than the token file.
+func (t Token) Ephemeral() bool {
+	return t.ephemeral
+}
+
+type tokenFile struct {
+	Version int      `json:"version"`
+	Tokens  []*Token `json:"tokens"`
+}
+
+// Store holds the set of accepted tokens and persists the file-backed ones.
+type Store struct {
+	mu     sync.Mutex
+	path   string
+	tokens []*Token
+	// dirty is set when a file-backed token was used since the last Flush.
+	dirty bool
+
+	// flushMu serialises Flush calls, which run without mu.
+	flushMu sync.Mutex
+}
+
+// New returns an empty store backed by path. An empty path gives a purely
+// in-memory store.
+func New(path string) *Store {
+	return &Store{path: path}
+}
+
+// FromPlaintext returns an in-memory store accepting the given secrets.
+func FromPlaintext(secrets ...string) *Store {
+	s := New("")
+	for _, secret := range secrets {
+		s.AddPlaintext(secret, "")
+	}
+	return s
+}
+
+// Load reads the token file at path. A missing file yields an empty store.
+// Files in the legacy format (one plaintext token per line) are converted
+// to hashed entries and rewritten in place.
+func Load(path string) (*Store, error) {
+	s := New(path)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return s, nil
+	}
+
+	if trimmed[0] != '{' {
+		if err := s.migrateLegacy(path, data); err != nil {
+			return nil, fmt.Errorf("migrating plaintext token file: %w", err)
+		}
+		return s, nil
+	}
+
+	var f tokenFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if f.Version != fileVersion {
+		return nil, fmt.Errorf("unsupported token file version %d", f.Version)
+	}
+	s.tokens = f.Tokens
+
+	used, err := readLastUsed(s.lastUsedPath())
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range s.tokens {
+		if at, ok := used[t.ID]; ok && at.After(t.CreatedAt) && (t.LastUsed == nil || at.After(*t.LastUsed)) {
+			t.LastUsed = &at
+		}
+	}
+	return s, nil
+}
+
+func (s *Store) migrateLegacy(path string, data []byte) error {
+	createdAt := time.Now().UTC()
+	if info, err := os.Stat(path); err == nil {
+		createdAt = info.ModTime().UTC()
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		secret := strings.TrimSpace(scanner.Text())
+		if secret == "" || strings.HasPrefix(secret, "#") {
+			continue
+		}
+		id, err := newID()
+		if err != nil {
+			return err
+		}
+		s.tokens = append(s.tokens, &Token{
+			ID:        id,
+			Label:     "migrated",
+			Hash:      hashSecret(secret),
+			CreatedAt: createdAt,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	return s.Save()
+}
+
+// Save writes the file-backed tokens to disk atomically.
+func (s *Store) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.saveLocked()
+}
+
+func (s *Store) saveLocked() error {
+	if s.path == "" {
+		return nil
+	}
+
+	f := tokenFile{Version: fileVersion, Tokens: []*Token{}}
+	for _, t := range s.tokens {
+		if !t.ephemeral {
+			f.Tokens = append(f.Tokens, t)
+		}
+	}
+
+	return writeJSON(s.path, f)
+}
+
+// writeJSON writes v to path atomically, readable only by the owner.
+func writeJSON(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Path returns the file backing the store.
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Len returns the number of tokens, including expired ones.
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.tokens)
+}
+
+// List returns a copy of all tokens.
+func (s *Store) List() []Token {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]Token, len(s.tokens))
+	for i, t := range s.tokens {
+		list[i] = *t
+	}
+	return list
+}
+
+// AddPlaintext accepts secret without persisting it. It is used for tokens
+// supplied through SYNTRACK_AUTH_TOKENS.
+func (s *Store) AddPlaintext(secret, label string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens = append(s.tokens, &Token{
+		ID:        "env",
+		Label:     label,
+		Hash:      hashSecret(secret),
+		CreatedAt: time.Now().UTC(),
+		ephemeral: true,
+	})
+}
+
+// Generate creates a new token, stores its hash and returns the plaintext
+// secret. The secret cannot be recovered afterwards. A zero ttl means the
+// token never expires.
+func (s *Store) Generate(label string, ttl time.Duration) (string, *Token, error) {
+	secret, err := NewSecret()
+	if err != nil {
+		return "", nil, err
+	}
+	id, err := newID()
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now().UTC()
+	t := &Token{
+		ID:        id,
+		Label:     label,
+		Hash:      hashSecret(secret),
+		CreatedAt: now,
+	}
+	if ttl > 0 {
+		expires := now.Add(ttl)
+		t.ExpiresAt = &expires
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = append(s.tokens, t)
+	if err := s.saveLocked(); err != nil {
+		s.tokens = s.tokens[:len(s.tokens)-1]
+		return "", nil, err
+	}
+	return secret, t, nil
+}
+
+// Revoke removes the token identified by ref (an ID or a unique label).
+func (s *Store) Revoke(ref string) (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idx, err := s.findLocked(ref)
+	if err != nil {
+		return nil, err
+	}
+	t := s.tokens[idx]
+	s.tokens = append(s.tokens[:idx:idx], s.tokens[idx+1:]...)
+	if err := s.saveLocked(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Rotate replaces the secret of the token identified by ref, keeping its ID
+// and label. A token that had an expiry gets the same lifetime again,
+// counted from now.
+func (s *Store) Rotate(ref string) (string, *Token, error) {
+	secret, err := NewSecret()
+	if err != nil {
+		return "", nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idx, err := s.findLocked(ref)
+	if err != nil {
+		return "", nil, err
+	}
+	old := s.tokens[idx]
+
+	now := time.Now().UTC()
+	t := &Token{
+		ID:        old.ID,
+		Label:     old.Label,
+		Hash:      hashSecret(secret),
+		CreatedAt: now,
+	}
+	if old.ExpiresAt != nil {
+		expires := now.Add(old.ExpiresAt.Sub(old.CreatedAt))
+		t.ExpiresAt = &expires
+	}
+
+	s.tokens[idx] = t
+	if err := s.saveLocked(); err != nil {
+		s.tokens[idx] = old
+		return "", nil, err
+	}
+	return secret, t, nil
+}
+
+func (s *Store) findLocked(ref string) (int, error) {
+	for i, t := range s.tokens {
+		if !t.ephemeral && t.ID == ref {
+			return i, nil
+		}
+	}
+
+	found := -1
+	for i, t := range s.tokens {
+		if !t.ephemeral && t.Label != "" && t.Label == ref {
+			if found >= 0 {
+				return -1, fmt.Errorf("label %q matches more than one token; use the token ID", ref)
+			}
+			found = i
+		}
+	}
+	if found < 0 {
+		return -1, fmt.Errorf("no token with ID or label %q", ref)
+	}
+	return found, nil
+}
+
+// Active reports whether the token with the given hash is still present
+// and not expired. Rotating or revoking a token makes its old hash inactive.
+func (s *Store) Active(hash string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for _, t := range s.tokens {
+		if t.Hash == hash && !t.Expired(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// Verify checks secret against every stored hash in constant time and
+// returns the matching token. Expired tokens never match. A successful
+// match updates the token's last-used timestamp in memory; Flush writes
+// it to disk.
+func (s *Store) Verify(secret string) (*Token, bool) {
+	if secret == "" {
+		return nil, false
+	}
+	candidate := []byte(hashSecret(secret))
+	now := time.Now().UTC()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var match *Token
+	for _, t := range s.tokens {
+		if subtle.ConstantTimeCompare([]byte(t.Hash), candidate) == 1 && match == nil {
+			match = t
+		}
+	}
+	if match == nil || match.Expired(now) {
+		return nil, false
+	}
+
+	match.LastUsed = &now
+	if !match.ephemeral {
+		s.dirty = true
+	}
+
+	t := *match
+	return &t, true
+}
+
+// Flush records the last-used timestamps of the file-backed tokens in the
+// last-used file. The file is written without holding the store lock, so
+// authentication never waits on disk. Newer timestamps already in the file,
+// e.g. from another server, are kept; entries of tokens no longer in the
+// store are dropped.
+func (s *Store) Flush() error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	s.mu.Lock()
+	if s.path == "" || !s.dirty {
+		s.mu.Unlock()
+		return nil
+	}
+	used := make(map[string]*time.Time)
+	for _, t := range s.tokens {
+		if !t.ephemeral {
+			used[t.ID] = t.LastUsed
+		}
+	}
+	s.dirty = false
+	s.mu.Unlock()
+
+	err := s.writeLastUsed(used)
+	if err != nil {
+		s.mu.Lock()
+		s.dirty = true
+		s.mu.Unlock()
+	}
+	return err
+}
+
+func (s *Store) writeLastUsed(used map[string]*time.Time) error {
+	path := s.lastUsedPath()
+	merged, err := readLastUsed(path)
+	if err != nil {
+		return err
+	}
+	for id := range merged {
+		if _, ok := used[id]; !ok {
+			delete(merged, id)
+		}
+	}
+	for id, at := range used {
+		if at != nil && at.After(merged[id]) {
+			merged[id] = *at
+		}
+	}
+	return writeJSON(path, merged)
+}
+
+func (s *Store) lastUsedPath() string {
+	return s.path + lastUsedSuffix
+}
+
+// readLastUsed reads a last-used file. A missing file yields an empty map.
+func readLastUsed(path string) (map[string]time.Time, error) {
+	used := make(map[string]time.Time)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return used, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &used); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return used, nil
+}
+
+// NewSecret returns a fresh random token secret.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return Prefix + hex.EncodeToString(b), nil
+}
+
+func newID() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Change describes how a token differs between two stores.
+type Change struct {
+	Kind  string // "added", "removed", "rotated" or "updated"
+	Token Token
+}
+
+// Diff compares two token sets. File tokens are matched by ID, so a new
+// hash under the same ID is reported as a rotation; environment tokens
+// have no stable ID and are matched by hash.
+func Diff(old, new *Store) []Change {
+	key := func(t Token) string {
+		if t.ephemeral {
+			return "env:" + t.Hash
+		}
+		return t.ID
+	}
+	index := func(s *Store) (map[string]Token, []string) {
+		m := make(map[string]Token)
+		var order []string
+		if s == nil {
+			return m, nil
+		}
+		for _, t := range s.List() {
+			k := key(t)
+			m[k] = t
+			order = append(order, k)
+		}
+		return m, order
+	}
+
+	oldTokens, oldOrder := index(old)
+	newTokens, newOrder := index(new)
+
+	var changes []Change
+	for _, k := range oldOrder {
+		if _, ok := newTokens[k]; !ok {
+			changes = append(changes, Change{Kind: "removed", Token: oldTokens[k]})
+		}
+	}
+	for _, k := range newOrder {
+		t := newTokens[k]
+		prev, ok := oldTokens[k]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Kind: "added", Token: t})
+		case prev.Hash != t.Hash:
+			changes = append(changes, Change{Kind: "rotated", Token: t})
+		case prev.Label != t.Label || !equalTime(prev.ExpiresAt, t.ExpiresAt):
+			changes = append(changes, Change{Kind: "updated", Token: t})
+		}
+	}
+	return changes
+}
+
+func equalTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
@@ -0,0 +1,186 @@
+package tokens
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad_MigratesPlaintextFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens")
+	legacy := "syntrack_token_one\n# comment\n\nsyntrack_token_two\n"
+	if err := os.WriteFile(path, []byte(legacy), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if store.Len() != 2 {
+		t.Fatalf("expected 2 migrated tokens, got %d", store.Len())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "syntrack_token_one") {
+		t.Fatal("migrated file still contains a plaintext token")
+	}
+
+	for _, secret := range []string{"syntrack_token_one", "syntrack_token_two"} {
+		if _, ok := store.Verify(secret); !ok {
+			t.Fatalf("expected %s to verify after migration", secret)
+		}
+	}
+
+	reloaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("reloading migrated file: %v", err)
+	}
+	if _, ok := reloaded.Verify("syntrack_token_one"); !ok {
+		t.Fatal("expected token to verify after reload")
+	}
+}
+
+func TestVerify_RejectsUnknownAndExpired(t *testing.T) {
+	store := New(filepath.Join(t.TempDir(), "tokens"))
+
+	secret, _, err := store.Generate("short", time.Nanosecond)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+
+	if _, ok := store.Verify(secret); ok {
+		t.Fatal("expected expired token to be rejected")
+	}
+	if _, ok := store.Verify("syntrack_token_unknown"); ok {
+		t.Fatal("expected unknown token to be rejected")
+	}
+	if _, ok := store.Verify(""); ok {
+		t.Fatal("expected empty token to be rejected")
+	}
+}
+
+func TestRevokeAndRotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens")
+	store := New(path)
+
+	laptop, laptopToken, err := store.Generate("laptop", 0)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	ci, _, err := store.Generate("ci", 0)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	rotated, rotatedToken, err := store.Rotate("laptop")
+	if err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if rotatedToken.ID != laptopToken.ID {
+		t.Fatalf("expected rotation to keep ID %s, got %s", laptopToken.ID, rotatedToken.ID)
+	}
+	if _, ok := store.Verify(laptop); ok {
+		t.Fatal("expected old secret to be rejected after rotation")
+	}
+	if _, ok := store.Verify(rotated); !ok {
+		t.Fatal("expected rotated secret to verify")
+	}
+
+	if _, err := store.Revoke("ci"); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+
+	reloaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if _, ok := reloaded.Verify(ci); ok {
+		t.Fatal("expected revoked token to be rejected after reload")
+	}
+	if _, ok := reloaded.Verify(rotated); !ok {
+		t.Fatal("expected rotated token to verify after reload")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens")
+	old := New(path)
+	_, kept, _ := old.Generate("kept", 0)
+	_, rotated, _ := old.Generate("rotated", 0)
+	_, revoked, _ := old.Generate("revoked", 0)
+	old.AddPlaintext("env-secret", "env")
+
+	updated, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	updated.Rotate(rotated.ID)
+	updated.Revoke(revoked.ID)
+	updated.Generate("added", 0)
+
+	kinds := map[string]string{}
+	for _, c := range Diff(old, updated) {
+		kinds[c.Token.Label] = c.Kind
+	}
+
+	want := map[string]string{"rotated": "rotated", "revoked": "removed", "added": "added", "env": "removed"}
+	for label, kind := range want {
+		if kinds[label] != kind {
+			t.Fatalf("%s: expected %q, got %q (all: %v)", label, kind, kinds[label], kinds)
+		}
+	}
+	if _, ok := kinds[kept.Label]; ok {
+		t.Fatalf("unchanged token reported: %v", kinds)
+	}
+}
+
+func TestFlush_RecordsLastUsedBesideTokenFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens")
+	cli := New(path)
+	laptop, laptopToken, err := cli.Generate("laptop", 0)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	ci, _, err := cli.Generate("ci", 0)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	server, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if _, ok := server.Verify(laptop); !ok {
+		t.Fatal("expected laptop token to verify")
+	}
+	if _, err := os.Stat(path + lastUsedSuffix); !os.IsNotExist(err) {
+		t.Fatalf("Verify wrote to disk: %v", err)
+	}
+
+	// The CLI revokes a token while the server still has it in memory
+	if _, err := cli.Revoke("ci"); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if err := server.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	reloaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if _, ok := reloaded.Verify(ci); ok {
+		t.Fatal("flushing last use brought back a revoked token")
+	}
+	list := reloaded.List()
+	if len(list) != 1 || list[0].ID != laptopToken.ID || list[0].LastUsed == nil {
+		t.Fatalf("expected laptop with a last-used time, got %+v", list)
+	}
+}